@@ -0,0 +1,346 @@
+// Command loot-simulator rolls the drop table of a game configuration file offline, through the same code the
+// roll_loot RPC decides rolls with, and reports how the items drop.
+//
+// Usage:
+//
+//	go run ./cmd/loot-simulator -config rpc/config/game_config.yaml -players 1000 -rolls 1000 -format json
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"oak/rpc"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+const (
+	// Significance level under which the observed drops are reported inconsistent with the declared odds
+	chiSquareSignificance = 0.01
+)
+
+type (
+	// Percentiles of the rolls a player needs to get an item, nil when more players than the percentile never got it.
+	Percentiles struct {
+		P50 *int `json:"p50"`
+		P90 *int `json:"p90"`
+		P99 *int `json:"p99"`
+	}
+
+	ItemReport struct {
+		Tier      string  `json:"tier"`
+		Item      string  `json:"item"`
+		Declared  float64 `json:"declared"`
+		Drops     int     `json:"drops"`
+		Frequency float64 `json:"frequency"`
+		// Mean rolls to the first drop of the item, counting the players who never got it at the rolls per player. It
+		// is then only a lower bound of the true mean.
+		ExpectedRolls           float64     `json:"expected_rolls"`
+		ExpectedRollsLowerBound bool        `json:"expected_rolls_lower_bound"`
+		Missed                  int         `json:"missed"`
+		Percentiles             Percentiles `json:"percentiles"`
+	}
+
+	ChiSquareReport struct {
+		Statistic        float64 `json:"statistic"`
+		DegreesOfFreedom int     `json:"degrees_of_freedom"`
+		PValue           float64 `json:"p_value"`
+		Consistent       bool    `json:"consistent"`
+	}
+
+	Report struct {
+		Config         string          `json:"config"`
+		Seed           string          `json:"seed"`
+		Pity           bool            `json:"pity"`
+		Players        int             `json:"players"`
+		RollsPerPlayer int             `json:"rolls_per_player"`
+		Items          []*ItemReport   `json:"items"`
+		ChiSquare      ChiSquareReport `json:"chi_square"`
+	}
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run parses the command line, simulates the rolls and writes the report.
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("loot-simulator", flag.ContinueOnError)
+	configFile := flags.String("config", "rpc/config/game_config.yaml", "game configuration file, JSON or YAML")
+	players := flags.Int("players", 1000, "number of simulated players, each starting without pity progress")
+	rolls := flags.Int("rolls", 1000, "number of rolls of every player")
+	pity := flags.Bool("pity", true, "apply the pity rules of the drop table")
+	seedHex := flags.String("seed", "", "hex encoded seed of the draws, random if empty")
+	format := flags.String("format", "text", "output format, text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *players <= 0 || *rolls <= 0 {
+		return errors.New("players and rolls must be positive")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	seed, err := simulationSeed(*seedHex)
+	if err != nil {
+		return err
+	}
+
+	config, err := rpc.LoadGameConfigFile(*configFile)
+	if err != nil {
+		return err
+	}
+	simulation, err := rpc.NewLootSimulation(&config.Rarity, seed, *pity)
+	if err != nil {
+		return err
+	}
+
+	report := simulate(simulation, *players, *rolls)
+	report.Config = *configFile
+	report.Seed = hex.EncodeToString(seed)
+	report.Pity = *pity
+
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return writeTextReport(stdout, report)
+}
+
+// simulationSeed decodes the seed of the simulation, or draws one from the cryptographic random number generator.
+func simulationSeed(seedHex string) ([]byte, error) {
+	if seedHex != "" {
+		seed, err := hex.DecodeString(seedHex)
+		if err != nil || len(seed) == 0 {
+			return nil, fmt.Errorf("seed must be a non-empty hex string")
+		}
+		return seed, nil
+	}
+
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// simulate rolls the drop table for every player and reports the drops of every item.
+func simulate(simulation *rpc.LootSimulation, players, rolls int) *Report {
+	rates := simulation.DropRates()
+	index := make(map[string]int, len(rates))
+	report := &Report{Players: players, RollsPerPlayer: rolls, Items: make([]*ItemReport, len(rates))}
+	for i, rate := range rates {
+		index[rate.Tier+"/"+rate.Item] = i
+		report.Items[i] = &ItemReport{Tier: rate.Tier, Item: rate.Item, Declared: rate.Probability}
+	}
+
+	// Rolls to the first drop of every item, for the players who got it
+	firstDrops := make([][]int, len(rates))
+	for player := 0; player < players; player++ {
+		simulation.Reset()
+		first := make([]int, len(rates))
+		for roll := 1; roll <= rolls; roll++ {
+			tier, item := simulation.Roll()
+			i := index[tier+"/"+item]
+			report.Items[i].Drops++
+			if first[i] == 0 {
+				first[i] = roll
+			}
+		}
+		for i, roll := range first {
+			if roll > 0 {
+				firstDrops[i] = append(firstDrops[i], roll)
+			}
+		}
+	}
+
+	total := float64(players * rolls)
+	observed := make([]int, len(rates))
+	expected := make([]float64, len(rates))
+	for i, item := range report.Items {
+		item.Frequency = float64(item.Drops) / total
+		item.Missed = players - len(firstDrops[i])
+		item.ExpectedRolls = censoredMean(firstDrops[i], item.Missed, rolls)
+		item.ExpectedRollsLowerBound = item.Missed > 0
+
+		slices.Sort(firstDrops[i])
+		item.Percentiles = Percentiles{
+			P50: percentile(firstDrops[i], players, 0.5),
+			P90: percentile(firstDrops[i], players, 0.9),
+			P99: percentile(firstDrops[i], players, 0.99),
+		}
+
+		observed[i] = item.Drops
+		expected[i] = item.Declared * total
+	}
+
+	statistic := chiSquare(observed, expected)
+	degrees := len(rates) - 1
+	pValue := chiSquarePValue(statistic, degrees)
+	report.ChiSquare = ChiSquareReport{
+		Statistic:        statistic,
+		DegreesOfFreedom: degrees,
+		PValue:           pValue,
+		Consistent:       pValue >= chiSquareSignificance,
+	}
+
+	return report
+}
+
+// censoredMean returns the mean of the values and of the censored members, which count at the censoring value. It is
+// 0 if there is none.
+func censoredMean(values []int, censored, censoring int) float64 {
+	count := len(values) + censored
+	if count == 0 {
+		return 0
+	}
+	sum := censored * censoring
+	for _, value := range values {
+		sum += value
+	}
+	return float64(sum) / float64(count)
+}
+
+// percentile returns the nearest-rank percentile of the sorted values among the population, whose members without a
+// value rank last. It is nil when the percentile falls among them.
+func percentile(sorted []int, population int, q float64) *int {
+	rank := int(math.Ceil(q * float64(population)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		return nil
+	}
+	return &sorted[rank-1]
+}
+
+// chiSquare returns Pearson's chi-square statistic of the observed counts against the expected ones.
+func chiSquare(observed []int, expected []float64) float64 {
+	statistic := 0.0
+	for i, count := range observed {
+		if expected[i] > 0 {
+			diff := float64(count) - expected[i]
+			statistic += diff * diff / expected[i]
+		}
+	}
+	return statistic
+}
+
+// chiSquarePValue returns the probability of a chi-square statistic at least as large under the declared odds.
+func chiSquarePValue(statistic float64, degrees int) float64 {
+	if degrees <= 0 {
+		return 1
+	}
+	return upperRegularizedGamma(float64(degrees)/2, statistic/2)
+}
+
+// upperRegularizedGamma returns Q(a, x), computed with its series below a + 1 and its continued fraction above.
+func upperRegularizedGamma(a, x float64) float64 {
+	const (
+		epsilon    = 1e-14
+		iterations = 1000
+	)
+	if x <= 0 {
+		return 1
+	}
+	lgamma, _ := math.Lgamma(a)
+	prefix := math.Exp(a*math.Log(x) - x - lgamma)
+
+	if x < a+1 {
+		term := 1 / a
+		sum := term
+		for n := 1; n < iterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return 1 - sum*prefix
+	}
+
+	// Modified Lentz's method
+	tiny := 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < iterations; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return prefix * h
+}
+
+// writeTextReport writes the report as a table.
+func writeTextReport(w io.Writer, report *Report) error {
+	fmt.Fprintf(w, "Config: %s\nSeed: %s\nPity: %t\nPlayers: %d, rolls per player: %d\n\n",
+		report.Config, report.Seed, report.Pity, report.Players, report.RollsPerPlayer)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TIER\tITEM\tDECLARED\tOBSERVED\tDROPS\tEXPECTED ROLLS\tP50\tP90\tP99\tMISSED")
+	for _, item := range report.Items {
+		fmt.Fprintf(table, "%s\t%s\t%.4f%%\t%.4f%%\t%d\t%s\t%s\t%s\t%s\t%d\n",
+			item.Tier, item.Item, item.Declared*100, item.Frequency*100, item.Drops, formatExpectedRolls(item),
+			formatPercentile(item.Percentiles.P50, report.RollsPerPlayer),
+			formatPercentile(item.Percentiles.P90, report.RollsPerPlayer),
+			formatPercentile(item.Percentiles.P99, report.RollsPerPlayer),
+			item.Missed)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	verdict := "consistent with the declared odds"
+	if !report.ChiSquare.Consistent {
+		verdict = "NOT consistent with the declared odds"
+		if report.Pity {
+			verdict += " (pity rules raise the odds above the declared ones)"
+		}
+	}
+	_, err := fmt.Fprintf(w, "\nChi-square: %.3f, %d degrees of freedom, p-value %.4f, %s at the %.2f level\n",
+		report.ChiSquare.Statistic, report.ChiSquare.DegreesOfFreedom, report.ChiSquare.PValue, verdict, chiSquareSignificance)
+	return err
+}
+
+// formatExpectedRolls formats the mean rolls to a first drop, marked as a lower bound when players never got the item.
+func formatExpectedRolls(item *ItemReport) string {
+	if item.ExpectedRollsLowerBound {
+		return fmt.Sprintf(">=%.1f", item.ExpectedRolls)
+	}
+	return fmt.Sprintf("%.1f", item.ExpectedRolls)
+}
+
+// formatPercentile formats a percentile of the rolls to a first drop, which may be beyond the simulated rolls.
+func formatPercentile(rolls *int, rollsPerPlayer int) string {
+	if rolls == nil {
+		return fmt.Sprintf(">%d", rollsPerPlayer)
+	}
+	return fmt.Sprint(*rolls)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChiSquarePValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		statistic float64
+		degrees   int
+		pValue    float64
+	}{
+		{name: "series", statistic: 3.841, degrees: 1, pValue: 0.05},
+		{name: "continued fraction", statistic: 18.307, degrees: 10, pValue: 0.05},
+		{name: "critical at 1%", statistic: 18.475, degrees: 7, pValue: 0.01},
+		{name: "no deviation", statistic: 0, degrees: 3, pValue: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the function and assert
+			assert.InDelta(t, tt.pValue, chiSquarePValue(tt.statistic, tt.degrees), 1e-4)
+		})
+	}
+}
+
+func TestPercentile_PlayersWithoutDropRankLast(t *testing.T) {
+	// Setup, 4 of 5 players got the item
+	sorted := []int{1, 2, 5, 9}
+
+	// Call the function and assert
+	assert.Equal(t, 5, *percentile(sorted, 5, 0.5))
+	assert.Equal(t, 9, *percentile(sorted, 5, 0.8))
+	assert.Nil(t, percentile(sorted, 5, 0.9))
+}
+
+func TestCensoredMean_PlayersWithoutDropCountAtRunLength(t *testing.T) {
+	// Setup, 4 of 5 players got the item within 10 rolls
+	values := []int{1, 2, 5, 9}
+
+	// Call the function and assert
+	assert.Equal(t, 5.4, censoredMean(values, 1, 10))
+	assert.Equal(t, 4.25, censoredMean(values, 0, 10))
+	assert.Equal(t, 0.0, censoredMean(nil, 0, 10))
+}
+
+func TestRun_JSONReport(t *testing.T) {
+	// Setup
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configFile, []byte(`
+welcome_message: Welcome
+xp_rate: 1
+rarity:
+  common:
+    chance: 0.9
+    items:
+      - {name: Wooden Sword, damage: 1, durability: 1}
+  legendary:
+    chance: 0.1
+    pity: {hard_pity: 5}
+    items:
+      - {name: Excalibur, damage: 1, durability: 1}
+`), 0o600)
+	assert.NoError(t, err)
+	var out bytes.Buffer
+
+	// Call the function
+	err = run([]string{"-config", configFile, "-players", "200", "-rolls", "10", "-seed", "0a0b", "-format", "json"}, &out)
+
+	// Assertions
+	assert.NoError(t, err)
+	var report Report
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, "0a0b", report.Seed)
+	assert.Len(t, report.Items, 2)
+
+	excalibur := report.Items[1]
+	assert.Equal(t, "Excalibur", excalibur.Item)
+	assert.Equal(t, 0.1, excalibur.Declared)
+	assert.Equal(t, 0, excalibur.Missed, "hard pity guarantees a legendary within 5 rolls")
+	assert.False(t, excalibur.ExpectedRollsLowerBound)
+	assert.LessOrEqual(t, *excalibur.Percentiles.P99, 5)
+	assert.Greater(t, excalibur.Frequency, 0.2)
+	assert.False(t, report.ChiSquare.Consistent, "pity raises the legendary odds above the declared ones")
+}
+
+func TestRun_InvalidDropTable(t *testing.T) {
+	// Setup
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{"rarity":{"common":{"chance":0.5,"items":[{"name":"Wooden Sword","durability":1}]}}}`), 0o600)
+	assert.NoError(t, err)
+
+	// Call the function
+	err = run([]string{"-config", configFile}, &bytes.Buffer{})
+
+	// Assertions
+	assert.ErrorContains(t, err, "invalid drop table: rarity: chances must sum to 1.0, got 0.5")
+}
@@ -0,0 +1,10 @@
+package common
+
+// ConfigChangeOp type for defining how a field differs between two game configurations
+type ConfigChangeOp string
+
+const (
+	ConfigChangeAdded   ConfigChangeOp = "added"
+	ConfigChangeRemoved ConfigChangeOp = "removed"
+	ConfigChangeChanged ConfigChangeOp = "changed"
+)
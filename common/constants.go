@@ -4,6 +4,59 @@ const (
 	EmptyString          = ""
 	StorageConfiguration = "configuration"
 	StorageGameConfigKey = "game_configuration"
+
+	StorageGameConfigVersions  = "game_configuration_versions"
+	StorageGameConfigActiveKey = "game_configuration_active"
+	StorageLiveOpsOverridesKey = "live_ops_overrides"
+	StorageLiveOpsArchive      = "live_ops_archive"
+
+	StorageExperiments              = "experiments"
+	StorageExperimentAssignmentsKey = "assignments"
+
+	StorageInventory         = "inventory"
+	StorageInventoryItemsKey = "items"
+
+	StorageLoot        = "loot"
+	StorageLootPityKey = "pity"
+	StorageLootAudit   = "loot_audit"
+
+	StorageTrades          = "trades"
+	StorageAuctionListings = "auction_listings"
+)
+
+const (
+	// GameConfigSchemaVersion is the structural version of GameConfig, bumped along with a migration step on breaking changes.
+	GameConfigSchemaVersion = 1
+
+	// ExperimentControlVariant is the variant of the players not allocated to any experiment variant.
+	ExperimentControlVariant = "control"
+
+	// ItemSourceLootRoll is the source of the item instances granted by loot rolls.
+	ItemSourceLootRoll = "loot_roll"
+
+	// ItemSourceCraft is the source of the item instances crafted from recipes, and the wallet ledger source of
+	// their costs.
+	ItemSourceCraft = "craft"
+
+	// WalletSourceRepair is the wallet ledger source of the item repair costs.
+	WalletSourceRepair = "repair"
+
+	// WalletSourceSalvage is the wallet ledger source of the yields of salvaged items.
+	WalletSourceSalvage = "salvage"
+
+	// WalletSourceTrade is the wallet ledger source of the currency escrowed, refunded and exchanged by trades.
+	WalletSourceTrade = "trade"
+
+	// WalletSourceAuction is the wallet ledger source of the fees of auction listings, the prices paid for them and
+	// the proceeds of their sales.
+	WalletSourceAuction = "auction"
+
+	// NotificationCodeTrade is the code of the notifications sent to both parties of a trade when its state changes.
+	NotificationCodeTrade = 100
+
+	// NotificationCodeAuction is the code of the notifications sent to sellers when their auction listings sell or
+	// expire.
+	NotificationCodeAuction = 101
 )
 
 const (
@@ -10,4 +10,25 @@ var (
 	ErrUnMarshallingError  = runtime.NewError("unmarshalling error", RpcCodeInternal)
 	ErrInternalError       = runtime.NewError("internal error", RpcCodeInternal)
 	ErrS2SPermissionDenied = runtime.NewError("rpc is only callable via server to server", RpcCodePermissionDenied)
+	ErrInvalidArgument     = runtime.NewError("invalid argument", RpcCodeInvalidArgument)
+	ErrAlreadyExists       = runtime.NewError("already exists", RpcCodeAlreadyExists)
+	ErrVersionConflict     = runtime.NewError("game configuration was modified concurrently, retry", RpcCodeAborted)
+	ErrPlayerDataConflict  = runtime.NewError("player data was modified concurrently, retry", RpcCodeAborted)
+	ErrInvalidLootTable    = runtime.NewError("loot table is invalid", RpcCodeFailedPrecondition)
+	ErrInventoryFull       = runtime.NewError("inventory is full", RpcCodeResourceExhausted)
+	ErrInsufficientFunds   = runtime.NewError("insufficient funds", RpcCodeFailedPrecondition)
+	ErrRepairUnavailable   = runtime.NewError("repairs are not available", RpcCodeFailedPrecondition)
+	ErrItemNotDamaged      = runtime.NewError("item is not damaged", RpcCodeFailedPrecondition)
+	ErrItemEquipped        = runtime.NewError("item is equipped", RpcCodeFailedPrecondition)
+	ErrLoadoutLimit        = runtime.NewError("loadout limit reached", RpcCodeResourceExhausted)
+	ErrAbilityUnavailable  = runtime.NewError("ability is not available", RpcCodeFailedPrecondition)
+	ErrCraftInputsMissing  = runtime.NewError("missing crafting inputs", RpcCodeFailedPrecondition)
+	ErrUpgradeLimit        = runtime.NewError("item is at its maximum level", RpcCodeFailedPrecondition)
+	ErrItemLocked          = runtime.NewError("item is locked", RpcCodeFailedPrecondition)
+	ErrItemUntradable      = runtime.NewError("item cannot be traded", RpcCodeFailedPrecondition)
+	ErrTradingUnavailable  = runtime.NewError("trading is not available", RpcCodeFailedPrecondition)
+	ErrTradeExpired        = runtime.NewError("trade offer expired", RpcCodeFailedPrecondition)
+	ErrAuctionUnavailable  = runtime.NewError("auction house is not available", RpcCodeFailedPrecondition)
+	ErrListingExpired      = runtime.NewError("auction listing expired", RpcCodeFailedPrecondition)
+	ErrListingLimit        = runtime.NewError("auction listing limit reached", RpcCodeResourceExhausted)
 )
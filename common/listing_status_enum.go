@@ -0,0 +1,11 @@
+package common
+
+// ListingStatus type for defining the state of an auction listing
+type ListingStatus string
+
+const (
+	ListingActive    ListingStatus = "active"
+	ListingSold      ListingStatus = "sold"
+	ListingCancelled ListingStatus = "cancelled"
+	ListingExpired   ListingStatus = "expired"
+)
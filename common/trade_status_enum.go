@@ -0,0 +1,12 @@
+package common
+
+// TradeStatus type for defining the state of a trade offer between two players
+type TradeStatus string
+
+const (
+	TradeProposed  TradeStatus = "proposed"
+	TradeAccepted  TradeStatus = "accepted"
+	TradeDeclined  TradeStatus = "declined"
+	TradeCancelled TradeStatus = "cancelled"
+	TradeExpired   TradeStatus = "expired"
+)
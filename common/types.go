@@ -2,9 +2,21 @@ package common
 
 type (
 	GameConfig struct {
-		WelcomeMessage string  `json:"welcome_message"`
-		XpRate         float64 `json:"xp_rate"`
-		Rarity         Rarity  `json:"rarity"`
+		WelcomeMessage   string                `json:"welcome_message"`
+		WelcomeMessageID string                `json:"welcome_message_id,omitempty"`
+		XpRate           float64               `json:"xp_rate"`
+		Rarity           Rarity                `json:"rarity"`
+		LootTables       map[string]LootTable  `json:"loot_tables,omitempty"`
+		AffixPools       map[string][]Affix    `json:"affix_pools,omitempty"`
+		Inventory        *InventorySettings    `json:"inventory,omitempty"`
+		Repair           *RepairSettings       `json:"repair,omitempty"`
+		EquipmentSlots   []EquipmentSlot       `json:"equipment_slots,omitempty"`
+		Abilities        map[string]Ability    `json:"abilities,omitempty"`
+		Recipes          map[string]Recipe     `json:"recipes,omitempty"`
+		Trading          *TradingSettings      `json:"trading,omitempty"`
+		AuctionHouse     *AuctionHouseSettings `json:"auction_house,omitempty"`
+		Localization     *Localization         `json:"localization,omitempty"`
+		Experiments      []Experiment          `json:"experiments,omitempty"`
 	}
 
 	Rarity struct {
@@ -12,18 +24,391 @@ type (
 		Uncommon  RarityItems `json:"uncommon"`
 		Rare      RarityItems `json:"rare"`
 		Legendary RarityItems `json:"legendary"`
+		// Price of a roll of the tiers, per currency. Players cannot roll the tiers without one
+		Cost map[string]int64 `json:"cost,omitempty"`
 	}
 
 	RarityItems struct {
-		Chance float64 `json:"chance"`
-		Items  []Item  `json:"items"`
+		Chance float64   `json:"chance"`
+		Items  []Item    `json:"items"`
+		Pity   *PityRule `json:"pity,omitempty"`
+		// Number of affixes rolled for the items of the tier, none if unset
+		Affixes *IntRange `json:"affixes,omitempty"`
+		// What salvaging an instance of an item of the tier yields, the tier cannot be salvaged if unset
+		Salvage *SalvageYield `json:"salvage,omitempty"`
+	}
+
+	// InventorySettings configures the inventories of the players.
+	InventorySettings struct {
+		// Maximum number of item instances a player can own, unlimited if 0
+		Capacity int `json:"capacity,omitempty"`
+		// Number of loadouts a player can save, 1 if unset
+		Loadouts int `json:"loadouts,omitempty"`
+	}
+
+	// EquipmentSlot is a slot players equip an item instance in, accepting the items of the listed types.
+	EquipmentSlot struct {
+		ID      string   `json:"id"`
+		Name    string   `json:"name"`
+		NameID  string   `json:"name_id,omitempty"`
+		Accepts []string `json:"accepts"`
+	}
+
+	// RepairSettings price the repair of an item instance in a wallet currency:
+	// ceil((base_cost + cost_per_point * missing durability) * rarity multiplier * broken multiplier if broken).
+	RepairSettings struct {
+		Currency     string  `json:"currency"`
+		BaseCost     float64 `json:"base_cost,omitempty"`
+		CostPerPoint float64 `json:"cost_per_point"`
+		// Multipliers of the cost by rarity tier, 1 for the tiers without one
+		RarityMultipliers map[string]float64 `json:"rarity_multipliers,omitempty"`
+		// Multiplier of the cost of broken items, 1 if unset
+		BrokenMultiplier float64 `json:"broken_multiplier,omitempty"`
+	}
+
+	// Ability is a special ability of the items referencing it by ID: an effect applied with its parameters when its
+	// trigger fires, at most once per cooldown and as many times per match as it has charges.
+	Ability struct {
+		Trigger string `json:"trigger"`
+		Effect  string `json:"effect"`
+		// Seconds before the ability can fire again, none if 0
+		Cooldown float64 `json:"cooldown,omitempty"`
+		// Number of times the ability can fire per match, unlimited if 0
+		Charges int                `json:"charges,omitempty"`
+		Params  map[string]float64 `json:"params,omitempty"`
+	}
+
+	// Recipe consumes item instances and wallet currencies to craft an instance of the output item, or to upgrade an
+	// instance of an item by one level.
+	Recipe struct {
+		Inputs  []RecipeInput    `json:"inputs,omitempty"`
+		Cost    map[string]int64 `json:"cost,omitempty"`
+		Output  string           `json:"output,omitempty"`
+		Upgrade *RecipeUpgrade   `json:"upgrade,omitempty"`
+	}
+
+	// RecipeInput is a number of instances of an item, named by its name, consumed by a recipe.
+	RecipeInput struct {
+		Item  string `json:"item"`
+		Count int    `json:"count"`
+	}
+
+	// RecipeUpgrade raises the level of an instance of the item by one, adding the stats to its own, until the
+	// maximum level.
+	RecipeUpgrade struct {
+		Item     string `json:"item"`
+		Damage   int    `json:"damage,omitempty"`
+		Defense  int    `json:"defense,omitempty"`
+		MaxLevel int    `json:"max_level"`
+	}
+
+	// SalvageYield is what salvaging an item instance yields in wallet currencies and crafting materials, by name.
+	// The amounts are scaled by the durability left: from the minimum factor of them when broken up to the full
+	// amounts at full durability, rounded down.
+	SalvageYield struct {
+		Amounts   map[string]int64 `json:"amounts"`
+		MinFactor float64          `json:"min_factor,omitempty"`
+	}
+
+	// TradingSettings configure the trades between players.
+	TradingSettings struct {
+		// Seconds a trade offer stays pending before it expires
+		OfferTTL int64 `json:"offer_ttl"`
+		// Maximum number of item instances on each side of a trade, unlimited if 0
+		MaxItems int `json:"max_items,omitempty"`
+	}
+
+	// AuctionHouseSettings configure the marketplace where players sell item instances for a currency price.
+	AuctionHouseSettings struct {
+		// Seconds a listing stays on sale before it expires and its instance returns to the seller
+		ListingTTL int64 `json:"listing_ttl"`
+		// Wallet currencies listings can be priced in
+		Currencies []string `json:"currencies"`
+		// Percentage of the price charged to the seller when listing, rounded down and kept whether the listing sells
+		ListingFeePercent float64 `json:"listing_fee_percent,omitempty"`
+		// Number of listings a seller can have at once, unlimited if unset
+		MaxListingsPerSeller int `json:"max_listings_per_seller,omitempty"`
+	}
+
+	// IntRange is an inclusive range of integers.
+	IntRange struct {
+		Min int `json:"min"`
+		Max int `json:"max"`
+	}
+
+	// Affix is an affix an item instance can roll from the pool of its item, with a value in the inclusive range.
+	// Its text describes it with {value} standing for the rolled value, e.g. "+{value}% fire damage".
+	Affix struct {
+		ID     string  `json:"id"`
+		Text   string  `json:"text,omitempty"`
+		TextID string  `json:"text_id,omitempty"`
+		Stat   string  `json:"stat"`
+		Min    int     `json:"min"`
+		Max    int     `json:"max"`
+		Weight float64 `json:"weight,omitempty"`
+	}
+
+	// ItemAffix is an affix rolled for an item instance.
+	ItemAffix struct {
+		ID    string `json:"id"`
+		Stat  string `json:"stat"`
+		Value int    `json:"value"`
+	}
+
+	// LootTable is a named drop source. A roll picks one of its tiers weighted by their chance, then one entry of the
+	// tier weighted by the entry weights.
+	LootTable struct {
+		Tiers []LootTableTier `json:"tiers"`
+		// Wallet currencies players pay to roll the table, which only the server can roll for them if unset
+		Cost map[string]int64 `json:"cost,omitempty"`
+	}
+
+	LootTableTier struct {
+		Name    string      `json:"name"`
+		Chance  float64     `json:"chance"`
+		Entries []LootEntry `json:"entries"`
+	}
+
+	// LootEntry drops exactly one of an item of the rarity tiers, named by its name, a roll of another loot table, an
+	// amount of a wallet currency, or nothing. Its weight is 1 if unset.
+	LootEntry struct {
+		Item     string  `json:"item,omitempty"`
+		Table    string  `json:"table,omitempty"`
+		Currency string  `json:"currency,omitempty"`
+		Amount   int64   `json:"amount,omitempty"`
+		Nothing  bool    `json:"nothing,omitempty"`
+		Weight   float64 `json:"weight,omitempty"`
+	}
+
+	// PityRule protects players from bad luck on a tier. Its counter is the number of rolls since the tier or a rarer
+	// one last dropped: from the soft pity roll on, every roll adds the step to the chance of the tier, and the hard
+	// pity roll is guaranteed to drop the tier or a rarer one.
+	PityRule struct {
+		SoftPity     int     `json:"soft_pity,omitempty"`
+		SoftPityStep float64 `json:"soft_pity_step,omitempty"`
+		HardPity     int     `json:"hard_pity,omitempty"`
+	}
+
+	// PityCounters are the rolls since each tier or a rarer one last dropped for a player, keyed by tier.
+	PityCounters struct {
+		Counters map[string]int `json:"counters"`
+	}
+
+	// LootRollRecord is the audit record of a loot roll. It holds the drop table and pity counters the roll was decided
+	// with and the seed of its draws, so the roll can be replayed. Rolls of a named loot table also hold the loot
+	// tables it can reach, the rarity tiers being the catalog of the items they drop.
+	LootRollRecord struct {
+		ID            string               `json:"id"`
+		UserID        string               `json:"user_id"`
+		Timestamp     int64                `json:"timestamp"`
+		ConfigVersion int                  `json:"config_version"`
+		Table         string               `json:"table,omitempty"`
+		Rarity        Rarity               `json:"rarity"`
+		LootTables    map[string]LootTable `json:"loot_tables,omitempty"`
+		Pity          map[string]int       `json:"pity"`
+		Chances       map[string]float64   `json:"chances"`
+		Seed          string               `json:"seed"`
+		Draws         []float64            `json:"draws"`
+		Tier          string               `json:"tier,omitempty"`
+		Item          *Item                `json:"item,omitempty"`
+		Currency      string               `json:"currency,omitempty"`
+		Amount        int64                `json:"amount,omitempty"`
+		InstanceID    string               `json:"instance_id,omitempty"`
 	}
 
 	Item struct {
-		Name           string `json:"name"`
-		Damage         int    `json:"damage,omitempty"`
-		Defense        int    `json:"defense,omitempty"`
-		Durability     int    `json:"durability"`
-		SpecialAbility string `json:"special_ability,omitempty"`
+		Name             string `json:"name"`
+		NameID           string `json:"name_id,omitempty"`
+		Damage           int    `json:"damage,omitempty"`
+		Defense          int    `json:"defense,omitempty"`
+		Durability       int    `json:"durability"`
+		SpecialAbility   string `json:"special_ability,omitempty"`
+		SpecialAbilityID string `json:"special_ability_id,omitempty"`
+		// Relative weight of the item within its tier, 1 if unset
+		Weight float64 `json:"weight,omitempty"`
+		// Ranges the stats of the instances are rolled in, in place of the fixed stats
+		DamageRange     *IntRange `json:"damage_range,omitempty"`
+		DefenseRange    *IntRange `json:"defense_range,omitempty"`
+		DurabilityRange *IntRange `json:"durability_range,omitempty"`
+		// Affix pool the affixes of the instances are rolled from
+		AffixPool string `json:"affix_pool,omitempty"`
+		// Type of the item, matched against the types the equipment slots accept
+		Type string `json:"type,omitempty"`
+		// ID of the special ability of the item, whose description is generated into SpecialAbility
+		Ability string `json:"ability,omitempty"`
+		// Whether the instances of the item can be traded between players, true if unset
+		Tradable *bool `json:"tradable,omitempty"`
+	}
+
+	// Localization holds the string tables of the game configuration, keyed by language tag and then by string ID.
+	// Texts referenced by a string ID are resolved in the player's language, the literal is only served without tables.
+	Localization struct {
+		DefaultLanguage string                       `json:"default_language"`
+		Strings         map[string]map[string]string `json:"strings"`
+	}
+
+	// ItemInstance is an item owned by a player. Its stats are rolled from its seed and the template of its item in
+	// the configuration version it dropped with.
+	ItemInstance struct {
+		ID            string      `json:"id"`
+		Item          string      `json:"item"`
+		Rarity        string      `json:"rarity"`
+		Durability    int         `json:"durability"`
+		Source        string      `json:"source"`
+		AcquiredAt    int64       `json:"acquired_at"`
+		ConfigVersion int         `json:"config_version,omitempty"`
+		Seed          string      `json:"seed,omitempty"`
+		Damage        int         `json:"damage,omitempty"`
+		Defense       int         `json:"defense,omitempty"`
+		MaxDurability int         `json:"max_durability,omitempty"`
+		Affixes       []ItemAffix `json:"affixes,omitempty"`
+		// Experiment variants and live-ops overrides the configuration the stats were rolled from was derived with
+		Variants         map[string]string `json:"variants,omitempty"`
+		LiveOpsOverrides []string          `json:"live_ops_overrides,omitempty"`
+		// Broken instances have no durability left and add nothing to the combat stats until repaired
+		Broken bool `json:"broken,omitempty"`
+		// Upgrade level of the instance and the stats its upgrades added to the rolled ones
+		Level          int `json:"level,omitempty"`
+		UpgradeDamage  int `json:"upgrade_damage,omitempty"`
+		UpgradeDefense int `json:"upgrade_defense,omitempty"`
+		// Locked instances cannot be discarded, salvaged or consumed by recipes until unlocked
+		Locked bool `json:"locked,omitempty"`
+	}
+
+	// Inventory holds the item instances owned by a player and their saved loadouts, by name, along with the name of
+	// the equipped one.
+	Inventory struct {
+		Items         []*ItemInstance    `json:"items"`
+		Loadouts      map[string]Loadout `json:"loadouts,omitempty"`
+		ActiveLoadout string             `json:"active_loadout,omitempty"`
+	}
+
+	// Trade is an offer of a player to trade item instances and currency with another player. The offered instances
+	// and currency are held in escrow, out of the proposer's inventory and wallet, until the offer is accepted,
+	// declined, cancelled or expires. The requested instances are instances of the recipient's inventory.
+	Trade struct {
+		ID                string           `json:"id"`
+		ProposerID        string           `json:"proposer_id"`
+		RecipientID       string           `json:"recipient_id"`
+		Offered           []*ItemInstance  `json:"offered"`
+		OfferedCurrency   map[string]int64 `json:"offered_currency,omitempty"`
+		Requested         []string         `json:"requested"`
+		RequestedCurrency map[string]int64 `json:"requested_currency,omitempty"`
+		Status            TradeStatus      `json:"status"`
+		CreatedAt         int64            `json:"created_at"`
+		ExpiresAt         int64            `json:"expires_at"`
+	}
+
+	// AuctionListing is an item instance a player sells on the auction house. The instance is held in escrow, out of
+	// the seller's inventory, until the listing sells, is cancelled or expires. The item and rarity of the instance are
+	// repeated at the top level for the storage index the listings are searched with.
+	AuctionListing struct {
+		ID       string        `json:"id"`
+		SellerID string        `json:"seller_id"`
+		Item     string        `json:"item"`
+		Rarity   string        `json:"rarity"`
+		Instance *ItemInstance `json:"instance"`
+		Price    int64         `json:"price"`
+		Currency string        `json:"currency"`
+		// Fee charged to the seller when listed
+		Fee       int64         `json:"fee"`
+		Status    ListingStatus `json:"status"`
+		CreatedAt int64         `json:"created_at"`
+		ExpiresAt int64         `json:"expires_at"`
+	}
+
+	// Loadout maps equipment slot IDs to the IDs of the item instances equipped in them.
+	Loadout struct {
+		Slots map[string]string `json:"slots"`
+	}
+
+	// Experiment splits the players into named variants of the game configuration.
+	// Players outside of every variant allocation are in the control group and see the base configuration.
+	Experiment struct {
+		ID       string              `json:"id"`
+		Variants []ExperimentVariant `json:"variants"`
+	}
+
+	// ExperimentVariant is a JSON merge patch of the game configuration allocated to a percentage of the players.
+	ExperimentVariant struct {
+		Name       string         `json:"name"`
+		Allocation float64        `json:"allocation"`
+		Overrides  map[string]any `json:"overrides"`
+	}
+
+	// ExperimentAssignment is the variant a player was bucketed or force assigned into.
+	ExperimentAssignment struct {
+		Variant    string `json:"variant"`
+		Forced     bool   `json:"forced,omitempty"`
+		AssignedAt int64  `json:"assigned_at"`
+	}
+
+	// ExperimentAssignments are the persisted variant assignments of a player, keyed by experiment ID.
+	ExperimentAssignments struct {
+		Assignments map[string]*ExperimentAssignment `json:"assignments"`
+	}
+
+	// LiveOpsOverride is a JSON merge patch of the game configuration applied between two Unix timestamps.
+	// Overlapping overrides are applied in ascending priority order, so the highest priority wins.
+	LiveOpsOverride struct {
+		ID        string         `json:"id"`
+		Name      string         `json:"name,omitempty"`
+		Priority  int            `json:"priority"`
+		StartAt   int64          `json:"start_at"`
+		EndAt     int64          `json:"end_at"`
+		Overrides map[string]any `json:"overrides"`
+		CreatedAt int64          `json:"created_at"`
+	}
+
+	// LiveOpsOverrides are the scheduled live-ops overrides of the server.
+	LiveOpsOverrides struct {
+		Overrides []*LiveOpsOverride `json:"overrides"`
+	}
+
+	// LiveOpsStatus reports the live-ops overrides merged into an effective game configuration.
+	LiveOpsStatus struct {
+		ActiveOverrides []string `json:"active_overrides"`
+		NextChangeAt    int64    `json:"next_change_at,omitempty"`
+	}
+
+	// ConfigViolation is a semantic problem found in a game configuration, qualified by the path of the offending field.
+	ConfigViolation struct {
+		Path    string `json:"path"`
+		Message string `json:"message"`
+	}
+
+	// ConfigChange is a field-level difference between two game configurations. Items are addressed by their key,
+	// e.g. rarity.common.items[name=Wooden Sword].damage, rather than by their index.
+	ConfigChange struct {
+		Path string         `json:"path"`
+		Op   ConfigChangeOp `json:"op"`
+		From any            `json:"from,omitempty"`
+		To   any            `json:"to,omitempty"`
+	}
+
+	// GameConfigVersion is a published, immutable revision of the server-wide game configuration.
+	GameConfigVersion struct {
+		Version       int        `json:"version"`
+		SchemaVersion int        `json:"schema_version"`
+		Config        GameConfig `json:"config"`
+		Note          string     `json:"note,omitempty"`
+		PublishedAt   int64      `json:"published_at"`
+	}
+
+	// GameConfigSnapshot is a player's copy of the game configuration, tagged with the version it was derived from.
+	GameConfigSnapshot struct {
+		SchemaVersion int               `json:"schema_version"`
+		ConfigVersion int               `json:"config_version"`
+		Variants      map[string]string `json:"variants,omitempty"`
+		LiveOps       *LiveOpsStatus    `json:"live_ops,omitempty"`
+		Config        GameConfig        `json:"config"`
+		UpdatedAt     int64             `json:"updated_at"`
+	}
+
+	// GameConfigPointer tracks which published version is served and the highest version published so far.
+	GameConfigPointer struct {
+		ActiveVersion int   `json:"active_version"`
+		LatestVersion int   `json:"latest_version"`
+		UpdatedAt     int64 `json:"updated_at"`
 	}
 )
@@ -9,35 +9,20 @@ import (
 	"oak/rpc"
 )
 
-// InitializeUser is invoked when user is created for the first time to initialize the user's data.
+// InitializeUser is invoked after every device authentication to initialize the user's data when the user is created
+// and to migrate the user's copy of the game configuration when the active version has changed since.
 func InitializeUser(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, out *api.Session, _ *api.AuthenticateDeviceRequest) error {
-	if out.Created {
-		// Get the user ID from the context
-		userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
-		if !ok {
-			logger.Error("Context did not contain user ID.")
-			return common.ErrUserNotFound
-		}
-
-		// Load the game configuration from the file
-		gameConfiguration, err := rpc.LoadGameConfig(logger)
-		if err != nil {
-			return err
-		}
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.ErrUserNotFound
+	}
 
-		// Write the game configuration to the storage
-		_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
-			Collection:      common.StorageConfiguration,
-			Key:             common.StorageGameConfigKey,
-			PermissionRead:  1,
-			PermissionWrite: 1,
-			Value:           gameConfiguration,
-			UserID:          userID,
-		}})
-		if err != nil {
-			logger.Error("StorageWrite error: %+v", err)
-			return common.ErrInternalError
-		}
+	// Write or refresh the user's copy of the game configuration
+	if _, err := rpc.SyncUserGameConfig(ctx, logger, nk, userID); err != nil {
+		logger.Error("Cannot sync game configuration of user %s: %v", userID, err)
+		return err
 	}
 
 	return nil
@@ -13,11 +13,78 @@ const (
 	rpcReadGameConfigurationFromFile    = "read_game_config_from_file"
 	rpcReadGameConfigurationFromStorage = "read_game_config_from_storage"
 	rpcS2SReadGameStats                 = "read_game_stats"
+	rpcS2SPublishGameConfig             = "publish_game_config"
+	rpcS2SListGameConfigVersions        = "list_game_config_versions"
+	rpcS2SRollbackGameConfig            = "rollback_game_config"
+	rpcS2SDiffGameConfig                = "diff_game_config"
+	rpcReadExperimentAssignments        = "read_experiment_assignments"
+	rpcS2SAssignExperimentVariant       = "assign_experiment_variant"
+	rpcS2SCreateLiveOpsOverride         = "create_live_ops_override"
+	rpcS2SDeleteLiveOpsOverride         = "delete_live_ops_override"
+	rpcS2SListLiveOpsOverrides          = "list_live_ops_overrides"
+	rpcRollLoot                         = "roll_loot"
+	rpcS2SRollLootTable                 = "roll_loot_table"
+	rpcS2SListLootRolls                 = "list_loot_rolls"
+	rpcS2SReplayLootRoll                = "replay_loot_roll"
+	rpcReadDropRates                    = "read_drop_rates"
+	rpcS2SRederiveItemStats             = "rederive_item_stats"
+	rpcListInventory                    = "list_inventory"
+	rpcInspectItem                      = "inspect_item"
+	rpcDiscardItem                      = "discard_item"
+	rpcRepairItem                       = "repair_item"
+	rpcS2SApplyDurabilityLoss           = "apply_durability_loss"
+	rpcEquipItem                        = "equip_item"
+	rpcSelectLoadout                    = "select_loadout"
+	rpcDeleteLoadout                    = "delete_loadout"
+	rpcReadCombatStats                  = "read_combat_stats"
+	rpcS2SReadCombatStats               = "read_player_combat_stats"
+	rpcCraft                            = "craft"
+	rpcLockItem                         = "lock_item"
+	rpcSalvageItems                     = "salvage_items"
+	rpcProposeTrade                     = "propose_trade"
+	rpcAcceptTrade                      = "accept_trade"
+	rpcDeclineTrade                     = "decline_trade"
+	rpcS2SExpireTrades                  = "expire_trades"
+	rpcCreateAuctionListing             = "create_auction_listing"
+	rpcSearchAuctionListings            = "search_auction_listings"
+	rpcBuyAuctionListing                = "buy_auction_listing"
+	rpcCancelAuctionListing             = "cancel_auction_listing"
+	rpcListAuctionListings              = "list_auction_listings"
+	rpcS2SExpireAuctionListings         = "expire_auction_listings"
 )
 
-func InitModule(_ context.Context, logger runtime.Logger, _ *sql.DB, _ runtime.NakamaModule, initializer runtime.Initializer) error {
+func InitModule(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, initializer runtime.Initializer) error {
+	// Publish the embedded game configuration when no version exists yet
+	if err := rpc.BootstrapGameConfig(ctx, logger, nk); err != nil {
+		logger.Error("Unable to bootstrap game configuration: %v", err)
+		return err
+	}
+
+	// Refuse to load with a broken active game configuration
+	activeConfig, err := rpc.LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		logger.Error("Unable to load game configuration: %v", err)
+		return err
+	}
+	if err = rpc.ValidateGameConfig(logger, &activeConfig.Config); err != nil {
+		logger.Error("Active game configuration version %d is invalid: %v", activeConfig.Version, err)
+		return err
+	}
+
+	// Index the auction listings for search
+	if err = rpc.RegisterAuctionHouseIndex(initializer); err != nil {
+		logger.Error("Unable to register the auction house storage index: %v", err)
+		return err
+	}
+
+	// Index the loot roll audit records by player
+	if err = rpc.RegisterLootAuditIndex(initializer); err != nil {
+		logger.Error("Unable to register the loot audit storage index: %v", err)
+		return err
+	}
+
 	// Register RPCs
-	err := initializer.RegisterRpc(rpcUpdateAccountMetaData, rpc.UpdateAccountMetaData)
+	err = initializer.RegisterRpc(rpcUpdateAccountMetaData, rpc.UpdateAccountMetaData)
 	if err != nil {
 		logger.Error("Failed to register RPC %+v", err)
 	}
@@ -38,6 +105,212 @@ func InitModule(_ context.Context, logger runtime.Logger, _ *sql.DB, _ runtime.N
 		return err
 	}
 
+	err = initializer.RegisterRpc(rpcS2SPublishGameConfig, rpc.PublishGameConfig)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SListGameConfigVersions, rpc.ListGameConfigVersions)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SRollbackGameConfig, rpc.RollbackGameConfig)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SDiffGameConfig, rpc.DiffGameConfig)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcReadExperimentAssignments, rpc.ReadExperimentAssignments)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcS2SAssignExperimentVariant, rpc.S2SAssignExperimentVariant)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SCreateLiveOpsOverride, rpc.CreateLiveOpsOverride)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SDeleteLiveOpsOverride, rpc.DeleteLiveOpsOverride)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SListLiveOpsOverrides, rpc.ListLiveOpsOverrides)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcRollLoot, rpc.RollLoot)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcReadDropRates, rpc.ReadDropRates)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcListInventory, rpc.ListInventory)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcInspectItem, rpc.InspectItem)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcDiscardItem, rpc.DiscardItem)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcRepairItem, rpc.RepairItem)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcEquipItem, rpc.EquipItem)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcSelectLoadout, rpc.SelectLoadout)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcDeleteLoadout, rpc.DeleteLoadout)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcReadCombatStats, rpc.ReadCombatStats)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcCraft, rpc.Craft)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcLockItem, rpc.LockItem)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcSalvageItems, rpc.SalvageItems)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcProposeTrade, rpc.ProposeTrade)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcAcceptTrade, rpc.AcceptTrade)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcDeclineTrade, rpc.DeclineTrade)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcCreateAuctionListing, rpc.CreateAuctionListing)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcSearchAuctionListings, rpc.SearchAuctionListings)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcBuyAuctionListing, rpc.BuyAuctionListing)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcCancelAuctionListing, rpc.CancelAuctionListing)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcListAuctionListings, rpc.ListAuctionListings)
+	if err != nil {
+		logger.Error("Failed to register RPC %+v", err)
+	}
+
+	err = initializer.RegisterRpc(rpcS2SRollLootTable, rpc.S2SRollLootTable)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SListLootRolls, rpc.ListLootRolls)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SReplayLootRoll, rpc.ReplayLootRoll)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SRederiveItemStats, rpc.RederiveItemStats)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SApplyDurabilityLoss, rpc.ApplyDurabilityLoss)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SReadCombatStats, rpc.S2SReadCombatStats)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SExpireTrades, rpc.ExpireTrades)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
+	err = initializer.RegisterRpc(rpcS2SExpireAuctionListings, rpc.ExpireAuctionListings)
+	if err != nil {
+		logger.Error("Error in registering RPC %+v", err)
+		return err
+	}
+
 	// Register after hooks.
 	if err := initializer.RegisterAfterAuthenticateDevice(hook.InitializeUser); err != nil {
 		logger.Error("Unable to register: %v", err)
@@ -0,0 +1,253 @@
+package rpc
+
+import (
+	"fmt"
+	"math"
+	"oak/common"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// abilityEffect is an effect the server knows how to apply, along with the parameters it takes and the template
+	// of its description, with {param} standing for the value of a parameter.
+	abilityEffect struct {
+		Params      []string
+		Description string
+	}
+
+	// AbilityState tracks the abilities fired by the item instances of a player during a match. The match handler
+	// keeps it in its state, starting from NewAbilityState.
+	AbilityState struct {
+		// Charges used by instance ID
+		Used map[string]int `json:"used"`
+		// Time the ability of the instance is off cooldown, in Unix milliseconds, by instance ID
+		ReadyAt map[string]int64 `json:"ready_at"`
+	}
+
+	// AbilityActivation is an ability fired by an equipped item instance, whose effect the match applies.
+	AbilityActivation struct {
+		Slot       string             `json:"slot"`
+		InstanceID string             `json:"instance_id"`
+		Ability    string             `json:"ability"`
+		Effect     string             `json:"effect"`
+		Params     map[string]float64 `json:"params,omitempty"`
+	}
+)
+
+// abilityEffects are the effects abilities can apply, by name.
+var abilityEffects = map[string]abilityEffect{
+	"shockwave":    {Params: []string{"damage", "radius"}, Description: "Fires a shockwave dealing {damage} damage within {radius} m"},
+	"revive":       {Params: []string{"health"}, Description: "Revives the player with {health}% health"},
+	"heal":         {Params: []string{"health"}, Description: "Heals {health} health"},
+	"damage_boost": {Params: []string{"percent", "duration"}, Description: "Raises damage by {percent}% for {duration} s"},
+	"barrier":      {Params: []string{"absorb", "duration"}, Description: "Absorbs {absorb} damage for {duration} s"},
+}
+
+// abilityTriggers are the match events abilities fire on, along with the text describing them.
+var abilityTriggers = map[string]string{
+	"on_attack":      "when attacking",
+	"on_hit":         "when hit",
+	"on_kill":        "on a kill",
+	"on_death":       "on death",
+	"on_activate":    "when activated",
+	"on_match_start": "at the start of the match",
+}
+
+// NewAbilityState returns the ability state of a player at the start of a match.
+func NewAbilityState() *AbilityState {
+	return &AbilityState{Used: make(map[string]int), ReadyAt: make(map[string]int64)}
+}
+
+// TriggerAbilities fires the abilities of the equipped item instances that fire on the trigger, have charges left
+// and are off cooldown, and records their use in the state. Broken instances fire nothing. The activations are
+// ordered by slot ID.
+func TriggerAbilities(config *common.GameConfig, equipped *EquippedStats, state *AbilityState, trigger string, now time.Time) []*AbilityActivation {
+	slots := make([]string, 0, len(equipped.Equipped))
+	for slot := range equipped.Equipped {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+
+	var activations []*AbilityActivation
+	for _, slot := range slots {
+		instance := equipped.Equipped[slot]
+		id, ability := instanceAbility(config, instance)
+		if ability == nil || ability.Trigger != trigger {
+			continue
+		}
+		if activation := fireAbility(state, slot, instance, id, ability, now); activation != nil {
+			activations = append(activations, activation)
+		}
+	}
+	return activations
+}
+
+// ActivateAbility fires the ability of the item instance equipped in the slot on the player's request. The ability
+// must fire on activation, have charges left and be off cooldown, otherwise ErrAbilityUnavailable is returned.
+func ActivateAbility(config *common.GameConfig, equipped *EquippedStats, state *AbilityState, slot string, now time.Time) (*AbilityActivation, error) {
+	instance, ok := equipped.Equipped[slot]
+	if !ok {
+		return nil, common.ErrAbilityUnavailable
+	}
+	id, ability := instanceAbility(config, instance)
+	if ability == nil || ability.Trigger != "on_activate" {
+		return nil, common.ErrAbilityUnavailable
+	}
+
+	activation := fireAbility(state, slot, instance, id, ability, now)
+	if activation == nil {
+		return nil, common.ErrAbilityUnavailable
+	}
+	return activation, nil
+}
+
+// instanceAbility returns the ID and the definition of the ability of the item of the instance, none if the item has
+// no ability or the instance is broken.
+func instanceAbility(config *common.GameConfig, instance *common.ItemInstance) (string, *common.Ability) {
+	if instance.Broken {
+		return common.EmptyString, nil
+	}
+	item, _ := lootItem(&config.Rarity, instance.Item)
+	if item == nil || item.Ability == common.EmptyString {
+		return common.EmptyString, nil
+	}
+	ability, ok := config.Abilities[item.Ability]
+	if !ok {
+		return common.EmptyString, nil
+	}
+	return item.Ability, &ability
+}
+
+// fireAbility fires the ability of the instance if it has charges left and is off cooldown, recording its use.
+func fireAbility(state *AbilityState, slot string, instance *common.ItemInstance, id string, ability *common.Ability, now time.Time) *AbilityActivation {
+	if ability.Charges > 0 && state.Used[instance.ID] >= ability.Charges {
+		return nil
+	}
+	if now.UnixMilli() < state.ReadyAt[instance.ID] {
+		return nil
+	}
+
+	state.Used[instance.ID]++
+	if ability.Cooldown > 0 {
+		state.ReadyAt[instance.ID] = now.UnixMilli() + int64(ability.Cooldown*1000)
+	}
+
+	return &AbilityActivation{
+		Slot:       slot,
+		InstanceID: instance.ID,
+		Ability:    id,
+		Effect:     ability.Effect,
+		Params:     ability.Params,
+	}
+}
+
+// abilityDescription generates the description of the ability from its definition, e.g. "Fires a shockwave dealing
+// 50 damage within 5 m when attacking. Cooldown: 10 s.". The texts are looked up by the string IDs
+// ability.effect.<effect>, ability.trigger.<trigger>, ability.cooldown and ability.charges, in English by default.
+func abilityDescription(ability *common.Ability, translate func(id, literal string) string) string {
+	description := translate("ability.effect."+ability.Effect, abilityEffects[ability.Effect].Description)
+	for param, value := range ability.Params {
+		description = strings.ReplaceAll(description, "{"+param+"}", formatAbilityNumber(value))
+	}
+	description += " " + translate("ability.trigger."+ability.Trigger, abilityTriggers[ability.Trigger]) + "."
+
+	if ability.Cooldown > 0 {
+		cooldown := translate("ability.cooldown", "Cooldown: {seconds} s.")
+		description += " " + strings.ReplaceAll(cooldown, "{seconds}", formatAbilityNumber(ability.Cooldown))
+	}
+	if ability.Charges > 0 {
+		charges := translate("ability.charges", "Charges per match: {charges}.")
+		description += " " + strings.ReplaceAll(charges, "{charges}", strconv.Itoa(ability.Charges))
+	}
+
+	return description
+}
+
+// abilityStringIDs returns the string IDs ability descriptions are generated from, which the default language of a
+// configuration with abilities must translate.
+func abilityStringIDs() []string {
+	var ids []string
+	for _, effect := range sortedKeys(abilityEffects) {
+		ids = append(ids, "ability.effect."+effect)
+	}
+	for _, trigger := range sortedKeys(abilityTriggers) {
+		ids = append(ids, "ability.trigger."+trigger)
+	}
+	return append(ids, "ability.cooldown", "ability.charges")
+}
+
+// formatAbilityNumber formats a parameter of an ability without trailing zeros.
+func formatAbilityNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// abilityViolations returns the semantic violations of the ability registry and of the abilities of the items.
+func abilityViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+
+	for _, id := range sortedKeys(config.Abilities) {
+		ability := config.Abilities[id]
+		abilityPath := "abilities." + id
+
+		if _, ok := abilityTriggers[ability.Trigger]; !ok {
+			violations.add(abilityPath+".trigger", "must be one of %s, got %q", strings.Join(sortedKeys(abilityTriggers), ", "), ability.Trigger)
+		}
+		if ability.Cooldown < 0 || math.IsNaN(ability.Cooldown) || math.IsInf(ability.Cooldown, 0) {
+			violations.add(abilityPath+".cooldown", "must be a non-negative number, got %v", ability.Cooldown)
+		}
+		if ability.Charges < 0 {
+			violations.add(abilityPath+".charges", "must not be negative, got %d", ability.Charges)
+		}
+
+		effect, ok := abilityEffects[ability.Effect]
+		if !ok {
+			violations.add(abilityPath+".effect", "must be one of %s, got %q", strings.Join(sortedKeys(abilityEffects), ", "), ability.Effect)
+			continue
+		}
+		for _, param := range effect.Params {
+			if _, ok := ability.Params[param]; !ok {
+				violations.add(abilityPath+".params."+param, "must be set for the %s effect", ability.Effect)
+			}
+		}
+		for _, param := range sortedKeys(ability.Params) {
+			value := ability.Params[param]
+			switch {
+			case !slices.Contains(effect.Params, param):
+				violations.add(abilityPath+".params."+param, "is not a parameter of the %s effect", ability.Effect)
+			case math.IsNaN(value) || math.IsInf(value, 0):
+				violations.add(abilityPath+".params."+param, "must be a number, got %v", value)
+			}
+		}
+	}
+
+	for _, tier := range rarityTiers(&config.Rarity) {
+		for i, item := range tier.Items.Items {
+			if item.Ability == common.EmptyString {
+				continue
+			}
+			itemPath := fmt.Sprintf("rarity.%s.items[%d]", tier.Name, i)
+			if _, ok := config.Abilities[item.Ability]; !ok {
+				violations.add(itemPath+".ability", "references the unknown ability %q", item.Ability)
+			}
+			if item.SpecialAbility != common.EmptyString || item.SpecialAbilityID != common.EmptyString {
+				violations.add(itemPath+".special_ability", "is generated from the ability and must not be set")
+			}
+		}
+	}
+
+	return violations
+}
+
+// sortedKeys returns the keys of the map in increasing order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"testing"
+	"time"
+)
+
+// abilityEquipment returns the equipped stats of a player wearing Excalibur and the Phoenix Armor.
+func abilityEquipment() *EquippedStats {
+	return &EquippedStats{
+		Loadout: defaultLoadout,
+		Equipped: map[string]*common.ItemInstance{
+			"armor":     {ID: "phoenix", Item: "Phoenix Armor", Rarity: "legendary"},
+			"main_hand": {ID: "excalibur", Item: "Excalibur", Rarity: "legendary"},
+		},
+	}
+}
+
+func TestAbilityViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Abilities = map[string]common.Ability{
+		"blast": {Trigger: "on_sneeze", Effect: "shockwave", Cooldown: -1, Params: map[string]float64{"damage": 10, "speed": 2}},
+		"curse": {Trigger: "on_hit", Effect: "curse", Charges: -1},
+	}
+	config.Rarity.Legendary.Items[0].Ability = "smite"
+	config.Rarity.Legendary.Items[1].SpecialAbility = "Revives the player once per match"
+
+	// Call the function
+	violations := abilityViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "abilities.blast.trigger", Message: `must be one of on_activate, on_attack, on_death, on_hit, on_kill, on_match_start, got "on_sneeze"`},
+		{Path: "abilities.blast.cooldown", Message: "must be a non-negative number, got -1"},
+		{Path: "abilities.blast.params.radius", Message: "must be set for the shockwave effect"},
+		{Path: "abilities.blast.params.speed", Message: "is not a parameter of the shockwave effect"},
+		{Path: "abilities.curse.charges", Message: "must not be negative, got -1"},
+		{Path: "abilities.curse.effect", Message: `must be one of barrier, damage_boost, heal, revive, shockwave, got "curse"`},
+		{Path: "rarity.legendary.items[0].ability", Message: `references the unknown ability "smite"`},
+		{Path: "rarity.legendary.items[1].ability", Message: `references the unknown ability "phoenix_rebirth"`},
+		{Path: "rarity.legendary.items[1].special_ability", Message: "is generated from the ability and must not be set"},
+	}, violations)
+}
+
+func TestValidateGameConfig_MissingAbilityStrings(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	delete(config.Localization.Strings["en"], "ability.trigger.on_hit")
+	delete(config.Localization.Strings["en"], "ability.effect.heal")
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "localization.strings.en", Message: `missing translation of "ability.effect.heal" describing the abilities`},
+		{Path: "localization.strings.en", Message: `missing translation of "ability.trigger.on_hit" describing the abilities`},
+	}, violations)
+}
+
+func TestLocalizeGameConfig_DescribesEveryTrigger(t *testing.T) {
+	for _, trigger := range sortedKeys(abilityTriggers) {
+		t.Run(trigger, func(t *testing.T) {
+			// Setup
+			config := embeddedGameConfig(t)
+			ability := config.Abilities["shockwave"]
+			ability.Trigger = trigger
+			config.Abilities["shockwave"] = ability
+			text, ok := config.Localization.Strings["es"]["ability.trigger."+trigger]
+
+			// Call the function
+			localized, _ := localizeGameConfig(config, "es")
+
+			// Assertions
+			assert.True(t, ok, "the trigger must be translated")
+			assert.Equal(t, "Lanza una onda expansiva que inflige 50 de daño en 5 m "+text+". Enfriamiento: 10 s.", localized.Rarity.Legendary.Items[0].SpecialAbility)
+		})
+	}
+}
+
+func TestLocalizeGameConfig_AbilityDescriptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		langTag   string
+		excalibur string
+		phoenix   string
+	}{
+		{
+			name:      "english",
+			langTag:   "en",
+			excalibur: "Fires a shockwave dealing 50 damage within 5 m when attacking. Cooldown: 10 s.",
+			phoenix:   "Revives the player with 50% health on death. Charges per match: 1.",
+		},
+		{
+			name:      "spanish",
+			langTag:   "es",
+			excalibur: "Lanza una onda expansiva que inflige 50 de daño en 5 m al atacar. Enfriamiento: 10 s.",
+			phoenix:   "Revive al jugador con un 50% de salud al morir. Cargas por partida: 1.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			config := embeddedGameConfig(t)
+
+			// Call the function
+			localized, _ := localizeGameConfig(config, tt.langTag)
+
+			// Assertions
+			assert.Equal(t, tt.excalibur, localized.Rarity.Legendary.Items[0].SpecialAbility)
+			assert.Equal(t, tt.phoenix, localized.Rarity.Legendary.Items[1].SpecialAbility)
+		})
+	}
+}
+
+func TestTriggerAbilities_CooldownAndCharges(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	equipped := abilityEquipment()
+	state := NewAbilityState()
+	start := time.Unix(1000, 0)
+
+	// Call the function and assert
+	activations := TriggerAbilities(config, equipped, state, "on_attack", start)
+	assert.Equal(t, []*AbilityActivation{{
+		Slot:       "main_hand",
+		InstanceID: "excalibur",
+		Ability:    "shockwave",
+		Effect:     "shockwave",
+		Params:     map[string]float64{"damage": 50, "radius": 5},
+	}}, activations)
+
+	assert.Empty(t, TriggerAbilities(config, equipped, state, "on_attack", start.Add(9*time.Second)))
+	assert.Len(t, TriggerAbilities(config, equipped, state, "on_attack", start.Add(10*time.Second)), 1)
+
+	assert.Len(t, TriggerAbilities(config, equipped, state, "on_death", start), 1)
+	assert.Empty(t, TriggerAbilities(config, equipped, state, "on_death", start.Add(time.Hour)))
+	assert.Equal(t, map[string]int{"excalibur": 2, "phoenix": 1}, state.Used)
+}
+
+func TestTriggerAbilities_SkipsBrokenItems(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	equipped := abilityEquipment()
+	equipped.Equipped["main_hand"].Broken = true
+
+	// Call the function
+	activations := TriggerAbilities(config, equipped, NewAbilityState(), "on_attack", time.Unix(1000, 0))
+
+	// Assertions
+	assert.Empty(t, activations)
+}
+
+func TestActivateAbility(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Abilities["second_wind"] = common.Ability{Trigger: "on_activate", Effect: "heal", Charges: 1, Params: map[string]float64{"health": 30}}
+	config.Rarity.Legendary.Items[1].Ability = "second_wind"
+	equipped := abilityEquipment()
+	state := NewAbilityState()
+	now := time.Unix(1000, 0)
+
+	// Call the function and assert
+	activation, err := ActivateAbility(config, equipped, state, "armor", now)
+	assert.NoError(t, err)
+	assert.Equal(t, &AbilityActivation{
+		Slot:       "armor",
+		InstanceID: "phoenix",
+		Ability:    "second_wind",
+		Effect:     "heal",
+		Params:     map[string]float64{"health": 30},
+	}, activation)
+
+	_, err = ActivateAbility(config, equipped, state, "armor", now)
+	assert.Equal(t, common.ErrAbilityUnavailable, err)
+
+	_, err = ActivateAbility(config, equipped, state, "main_hand", now)
+	assert.Equal(t, common.ErrAbilityUnavailable, err)
+
+	_, err = ActivateAbility(config, equipped, state, "off_hand", now)
+	assert.Equal(t, common.ErrAbilityUnavailable, err)
+}
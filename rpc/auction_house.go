@@ -0,0 +1,614 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	CreateAuctionListingRequest struct {
+		InstanceID string `json:"instance_id"`
+		Price      int64  `json:"price"`
+		Currency   string `json:"currency"`
+	}
+
+	// SearchAuctionListingsRequest filters the listings on sale, every filter being optional. The listings are sorted
+	// by ascending price.
+	SearchAuctionListingsRequest struct {
+		Item     string `json:"item,omitempty"`
+		Rarity   string `json:"rarity,omitempty"`
+		Currency string `json:"currency,omitempty"`
+		MinPrice int64  `json:"min_price,omitempty"`
+		MaxPrice int64  `json:"max_price,omitempty"`
+		Limit    int    `json:"limit,omitempty"`
+		Cursor   string `json:"cursor,omitempty"`
+	}
+
+	SearchAuctionListingsResponse struct {
+		Listings []*common.AuctionListing `json:"listings"`
+		Cursor   string                   `json:"cursor,omitempty"`
+	}
+
+	AuctionListingRequest struct {
+		ListingID string `json:"listing_id"`
+	}
+
+	// BuyAuctionListingRequest addresses a listing by its ID and the ID of its seller, both part of the search results.
+	BuyAuctionListingRequest struct {
+		ListingID string `json:"listing_id"`
+		SellerID  string `json:"seller_id"`
+	}
+
+	ListAuctionListingsRequest struct {
+		Limit  int    `json:"limit,omitempty"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+
+	ListAuctionListingsResponse struct {
+		Listings []*common.AuctionListing `json:"listings"`
+		Cursor   string                   `json:"cursor,omitempty"`
+	}
+
+	AuctionListingResponse struct {
+		Listing *common.AuctionListing `json:"listing"`
+	}
+)
+
+const (
+	auctionListingsIndex = "auction_listings_index"
+	// maxAuctionListings is the number of listings the storage index holds at most, the oldest being evicted first.
+	// Evicted listings are no longer found by the search, but their sellers still list them and the expiry sweep
+	// still returns them, both reading the listings from storage.
+	maxAuctionListings = 1000000
+
+	defaultSearchAuctionListingsLimit = 20
+	maxSearchAuctionListingsLimit     = 100
+	maxListAuctionListingsLimit       = 100
+)
+
+var (
+	auctionIndexFields         = []string{"seller_id", "item", "rarity", "price", "currency", "expires_at"}
+	auctionIndexSortableFields = []string{"price", "expires_at"}
+)
+
+// RegisterAuctionHouseIndex registers the storage index the auction listings are searched with. Nakama keeps it up to
+// date as listings are written and deleted.
+func RegisterAuctionHouseIndex(initializer runtime.Initializer) error {
+	return initializer.RegisterStorageIndex(auctionListingsIndex, common.StorageAuctionListings, common.EmptyString,
+		auctionIndexFields, auctionIndexSortableFields, maxAuctionListings, false)
+}
+
+// CreateAuctionListing puts an item instance of the caller on sale for a currency price. The instance moves from the
+// caller's inventory into escrow, so it cannot be used, discarded or sold twice while listed, and the listing fee
+// leaves the caller's wallet in the same transaction.
+func CreateAuctionListing(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("CreateAuctionListing RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req CreateAuctionListingRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.InstanceID == common.EmptyString {
+		logger.Error("Payload did not contain an instance ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if req.Price <= 0 {
+		logger.Error("Payload did not contain a positive price")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	now := time.Now().Unix()
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if config.AuctionHouse == nil {
+		logger.Error("Auction house is not configured")
+		return common.EmptyString, common.ErrAuctionUnavailable
+	}
+	if !slices.Contains(config.AuctionHouse.Currencies, req.Currency) {
+		logger.Error("Auction listings cannot be priced in currency %s", req.Currency)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	// Every listing is created in a write of the seller's inventory guarded by its version, so the listings counted
+	// after reading the inventory cannot grow before the listing is created
+	if limit := config.AuctionHouse.MaxListingsPerSeller; limit > 0 {
+		objects, _, err := nk.StorageList(ctx, common.EmptyString, userID, common.StorageAuctionListings, limit, common.EmptyString)
+		if err != nil {
+			logger.Error("StorageList error: %+v", err)
+			return common.EmptyString, common.ErrInternalError
+		}
+		if len(objects) >= limit {
+			logger.Error("User %s reached the limit of %d auction listings", userID, limit)
+			return common.EmptyString, common.ErrListingLimit
+		}
+	}
+	taken, err := takeEscrowedItems(logger, config, userID, inventory, []string{req.InstanceID})
+	if err != nil {
+		return common.EmptyString, err
+	}
+	instance := taken[0]
+
+	listingID, err := newRandomID()
+	if err != nil {
+		logger.Error("Cannot create auction listing ID: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+	listing := &common.AuctionListing{
+		ID:        listingID,
+		SellerID:  userID,
+		Item:      instance.Item,
+		Rarity:    instance.Rarity,
+		Instance:  instance,
+		Price:     req.Price,
+		Currency:  req.Currency,
+		Fee:       auctionFee(config.AuctionHouse, req.Price),
+		Status:    common.ListingActive,
+		CreatedAt: now,
+		ExpiresAt: now + config.AuctionHouse.ListingTTL,
+	}
+
+	writeListing, err := auctionListingWrite(logger, listing, "*")
+	if err != nil {
+		return common.EmptyString, err
+	}
+	var walletUpdates []*runtime.WalletUpdate
+	if listing.Fee > 0 {
+		walletUpdates = append(walletUpdates, auctionWalletUpdate(userID, listing, -listing.Fee))
+	}
+	if err = holdEscrow(ctx, logger, nk, userID, inventory, inventoryVersion, writeListing, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s listed item instance %s in auction listing %s", userID, instance.ID, listing.ID)
+
+	return marshalResponse(logger, &AuctionListingResponse{Listing: listing})
+}
+
+// SearchAuctionListings pages through the listings on sale matching the filters of the request, cheapest first. The
+// search runs on the storage index of the listings rather than scanning them.
+func SearchAuctionListings(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("SearchAuctionListings RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req SearchAuctionListingsRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+	if req.MinPrice < 0 || req.MaxPrice < 0 || (req.MaxPrice > 0 && req.MaxPrice < req.MinPrice) {
+		logger.Error("Payload contained an invalid price range %d to %d", req.MinPrice, req.MaxPrice)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultSearchAuctionListingsLimit
+	}
+	if req.Limit > maxSearchAuctionListingsLimit {
+		req.Limit = maxSearchAuctionListingsLimit
+	}
+
+	now := time.Now().Unix()
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if config.AuctionHouse == nil {
+		logger.Error("Auction house is not configured")
+		return common.EmptyString, common.ErrAuctionUnavailable
+	}
+
+	// The filters are checked against the configuration, so only known values end up in the query
+	if req.Item != common.EmptyString {
+		if item, _ := lootItem(&config.Rarity, req.Item); item == nil {
+			logger.Error("Item %s not found", req.Item)
+			return common.EmptyString, common.ErrNotFound
+		}
+	}
+	if req.Rarity != common.EmptyString && !slices.ContainsFunc(rarityTiers(&config.Rarity), func(tier rarityTier) bool {
+		return tier.Name == req.Rarity
+	}) {
+		logger.Error("Rarity tier %s not found", req.Rarity)
+		return common.EmptyString, common.ErrNotFound
+	}
+	if req.Currency != common.EmptyString && !slices.Contains(config.AuctionHouse.Currencies, req.Currency) {
+		logger.Error("Auction listings cannot be priced in currency %s", req.Currency)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	objects, cursor, err := nk.StorageIndexList(ctx, common.EmptyString, auctionListingsIndex, auctionSearchQuery(&req, now),
+		req.Limit, []string{"value.price"}, req.Cursor)
+	if err != nil {
+		logger.Error("StorageIndexList error: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	resp := &SearchAuctionListingsResponse{Listings: make([]*common.AuctionListing, 0, len(objects.GetObjects())), Cursor: cursor}
+	for _, obj := range objects.GetObjects() {
+		listing := &common.AuctionListing{}
+		if err := json.Unmarshal([]byte(obj.GetValue()), listing); err != nil {
+			logger.Error("Cannot unmarshal auction listing %s: %+v", obj.GetKey(), err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+		resp.Listings = append(resp.Listings, listing)
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// BuyAuctionListing buys a listing on sale for its price: the instance goes to the caller's inventory and the price
+// moves from the caller's wallet to the seller's, in one transaction deleting the listing.
+func BuyAuctionListing(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("BuyAuctionListing RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req BuyAuctionListingRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.ListingID == common.EmptyString || req.SellerID == common.EmptyString {
+		logger.Error("Payload did not contain a listing ID and a seller ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	listing, listingVersion, err := readAuctionListing(ctx, logger, nk, req.SellerID, req.ListingID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if listing.SellerID == userID {
+		logger.Error("User %s cannot buy their own auction listing %s", userID, listing.ID)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	now := time.Now().Unix()
+	if now >= listing.ExpiresAt {
+		if err = closeAuctionListing(ctx, logger, nk, listing, listingVersion, common.ListingExpired); err != nil {
+			return common.EmptyString, err
+		}
+		return common.EmptyString, common.ErrListingExpired
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	listing.Instance.AcquiredAt = now
+	if err = addInventoryItem(logger, config, userID, inventory, listing.Instance); err != nil {
+		return common.EmptyString, err
+	}
+
+	writeInventory, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	walletUpdates := []*runtime.WalletUpdate{
+		auctionWalletUpdate(userID, listing, -listing.Price),
+		auctionWalletUpdate(listing.SellerID, listing, listing.Price),
+	}
+
+	// The inventory, both wallets and the listing change in one transaction guarded by the versions they were read
+	// at, so a listing sells once and only when the buyer can afford it
+	deletes := []*runtime.StorageDelete{auctionListingDelete(listing, listingVersion)}
+	if err = updatePlayerData(ctx, logger, nk, []*runtime.StorageWrite{writeInventory}, deletes, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	listing.Status = common.ListingSold
+	logger.Info("User %s bought auction listing %s of user %s", userID, listing.ID, listing.SellerID)
+	notifyAuctionSeller(ctx, logger, nk, listing)
+
+	return marshalResponse(logger, &AuctionListingResponse{Listing: listing})
+}
+
+// CancelAuctionListing takes a listing of the caller off sale and returns its instance to their inventory.
+func CancelAuctionListing(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("CancelAuctionListing RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req AuctionListingRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.ListingID == common.EmptyString {
+		logger.Error("Payload did not contain a listing ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	// Listings are owned by their seller, so the listings of other players are not found
+	listing, listingVersion, err := readAuctionListing(ctx, logger, nk, userID, req.ListingID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	status := common.ListingCancelled
+	if time.Now().Unix() >= listing.ExpiresAt {
+		status = common.ListingExpired
+	}
+	if err = closeAuctionListing(ctx, logger, nk, listing, listingVersion, status); err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, &AuctionListingResponse{Listing: listing})
+}
+
+// ListAuctionListings pages through the listings of the caller, read from storage. Expired listings stay listed until
+// they are closed.
+func ListAuctionListings(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ListAuctionListings RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req ListAuctionListingsRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+	if req.Limit <= 0 || req.Limit > maxListAuctionListingsLimit {
+		req.Limit = maxListAuctionListingsLimit
+	}
+
+	objects, cursor, err := nk.StorageList(ctx, common.EmptyString, userID, common.StorageAuctionListings, req.Limit, req.Cursor)
+	if err != nil {
+		logger.Error("StorageList error: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	resp := &ListAuctionListingsResponse{Listings: make([]*common.AuctionListing, 0, len(objects)), Cursor: cursor}
+	for _, obj := range objects {
+		listing := &common.AuctionListing{}
+		if err := json.Unmarshal([]byte(obj.GetValue()), listing); err != nil {
+			logger.Error("Cannot unmarshal auction listing %s: %+v", obj.GetKey(), err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+		resp.Listings = append(resp.Listings, listing)
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// ExpireAuctionListings closes the expired listings of a page of listings, returning their instances to the sellers.
+func ExpireAuctionListings(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ExpireAuctionListings RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	return expireEscrows(ctx, logger, nk, payload, common.StorageAuctionListings, "auction listing",
+		func(listing *common.AuctionListing) int64 { return listing.ExpiresAt },
+		func(listing *common.AuctionListing, storageVersion string) error {
+			return closeAuctionListing(ctx, logger, nk, listing, storageVersion, common.ListingExpired)
+		})
+}
+
+// closeAuctionListing takes a listing off sale without selling it, releasing the escrowed instance back to the seller.
+// The seller is notified of expired listings.
+func closeAuctionListing(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, listing *common.AuctionListing, listingVersion string, status common.ListingStatus) error {
+	instances := []*common.ItemInstance{listing.Instance}
+	if err := releaseEscrow(ctx, logger, nk, listing.SellerID, instances, auctionListingDelete(listing, listingVersion), nil); err != nil {
+		return err
+	}
+
+	listing.Status = status
+	logger.Info("Auction listing %s of user %s %s", listing.ID, listing.SellerID, status)
+	if status == common.ListingExpired {
+		notifyAuctionSeller(ctx, logger, nk, listing)
+	}
+
+	return nil
+}
+
+// auctionFee returns the fee charged to the seller for listing at the price, rounded down.
+func auctionFee(settings *common.AuctionHouseSettings, price int64) int64 {
+	return int64(math.Floor(float64(price) * settings.ListingFeePercent / 100))
+}
+
+// auctionSearchQuery builds the storage index query of the listings on sale matching the filters. The filter values
+// are expected to be checked against the configuration already.
+func auctionSearchQuery(req *SearchAuctionListingsRequest, now int64) string {
+	clauses := []string{fmt.Sprintf("+value.expires_at:>%d", now)}
+	if req.Item != common.EmptyString {
+		clauses = append(clauses, fmt.Sprintf("+value.item:%q", req.Item))
+	}
+	if req.Rarity != common.EmptyString {
+		clauses = append(clauses, fmt.Sprintf("+value.rarity:%q", req.Rarity))
+	}
+	if req.Currency != common.EmptyString {
+		clauses = append(clauses, fmt.Sprintf("+value.currency:%q", req.Currency))
+	}
+	if req.MinPrice > 0 {
+		clauses = append(clauses, fmt.Sprintf("+value.price:>=%d", req.MinPrice))
+	}
+	if req.MaxPrice > 0 {
+		clauses = append(clauses, fmt.Sprintf("+value.price:<=%d", req.MaxPrice))
+	}
+	return strings.Join(clauses, " ")
+}
+
+// readAuctionListing reads a listing on sale of the seller along with its storage version.
+func readAuctionListing(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, sellerID, listingID string) (*common.AuctionListing, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageAuctionListings,
+		Key:        listingID,
+		UserID:     sellerID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+	if len(objects) == 0 {
+		logger.Error("Auction listing %s not found", listingID)
+		return nil, common.EmptyString, common.ErrNotFound
+	}
+
+	listing := &common.AuctionListing{}
+	if err = json.Unmarshal([]byte(objects[0].GetValue()), listing); err != nil {
+		logger.Error("Cannot unmarshal auction listing: %+v", err)
+		return nil, common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	return listing, objects[0].GetVersion(), nil
+}
+
+// auctionListingWrite builds the conditional write of a listing. Listings are owned by their seller but hidden from
+// direct storage reads and writes, players find them through the search and list their own.
+func auctionListingWrite(logger runtime.Logger, listing *common.AuctionListing, storageVersion string) (*runtime.StorageWrite, error) {
+	listingJSON, err := json.Marshal(listing)
+	if err != nil {
+		logger.Error("Cannot marshal auction listing: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageAuctionListings,
+		Key:             listing.ID,
+		UserID:          listing.SellerID,
+		Value:           string(listingJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// auctionListingDelete builds the deletion of a listing, guarded by the storage version it was read at so that a
+// listing is sold or closed only once.
+func auctionListingDelete(listing *common.AuctionListing, storageVersion string) *runtime.StorageDelete {
+	return &runtime.StorageDelete{
+		Collection: common.StorageAuctionListings,
+		Key:        listing.ID,
+		UserID:     listing.SellerID,
+		Version:    storageVersion,
+	}
+}
+
+// auctionWalletUpdate builds the wallet update of the buyer or the seller of a listing, recorded in the ledger with
+// the listing ID.
+func auctionWalletUpdate(userID string, listing *common.AuctionListing, amount int64) *runtime.WalletUpdate {
+	return &runtime.WalletUpdate{
+		UserID:    userID,
+		Changeset: map[string]int64{listing.Currency: amount},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceAuction, "listing_id": listing.ID},
+	}
+}
+
+// notifyAuctionSeller tells the seller that their listing sold or expired. The listing has already changed, so a
+// failure to notify is only logged.
+func notifyAuctionSeller(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, listing *common.AuctionListing) {
+	notifications := []*runtime.NotificationSend{{
+		UserID:  listing.SellerID,
+		Subject: fmt.Sprintf("Auction listing %s", listing.Status),
+		Content: map[string]interface{}{
+			"listing_id": listing.ID,
+			"status":     string(listing.Status),
+			"item":       listing.Item,
+			"price":      listing.Price,
+			"currency":   listing.Currency,
+			"fee":        listing.Fee,
+		},
+		Code:       common.NotificationCodeAuction,
+		Persistent: true,
+	}}
+	if err := nk.NotificationsSend(ctx, notifications); err != nil {
+		logger.Error("Cannot notify the seller of auction listing %s: %+v", listing.ID, err)
+	}
+}
+
+// auctionHouseViolations returns the semantic violations of the auction house settings.
+func auctionHouseViolations(config *common.GameConfig) []*common.ConfigViolation {
+	if config.AuctionHouse == nil {
+		return nil
+	}
+
+	var violations configViolations
+
+	settings := config.AuctionHouse
+	if settings.ListingTTL <= 0 {
+		violations.add("auction_house.listing_ttl", "must be positive, got %d", settings.ListingTTL)
+	}
+	if len(settings.Currencies) == 0 {
+		violations.add("auction_house.currencies", "must not be empty")
+	}
+	for i, currency := range settings.Currencies {
+		switch {
+		case strings.TrimSpace(currency) == common.EmptyString:
+			violations.add(fmt.Sprintf("auction_house.currencies[%d]", i), "must not be empty")
+		case slices.Contains(settings.Currencies[:i], currency):
+			violations.add(fmt.Sprintf("auction_house.currencies[%d]", i), "duplicates the currency %q", currency)
+		}
+	}
+	if settings.ListingFeePercent < 0 || settings.ListingFeePercent > 100 || math.IsNaN(settings.ListingFeePercent) {
+		violations.add("auction_house.listing_fee_percent", "must be between 0 and 100, got %v", settings.ListingFeePercent)
+	}
+	if settings.MaxListingsPerSeller < 0 {
+		violations.add("auction_house.max_listings_per_seller", "must not be negative, got %d", settings.MaxListingsPerSeller)
+	}
+
+	return violations
+}
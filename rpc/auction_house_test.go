@@ -0,0 +1,508 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"strings"
+	"testing"
+	"time"
+)
+
+func auctionListingRead(sellerID, listingID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageAuctionListings,
+		Key:        listingID,
+		UserID:     sellerID,
+	}}
+}
+
+// auctionListing returns a listing of user123 selling an Iron Sword for 100 gold with a fee of 5, expiring at the
+// given time.
+func auctionListing(id string, expiresAt int64) *common.AuctionListing {
+	return &common.AuctionListing{
+		ID:        id,
+		SellerID:  "user123",
+		Item:      "Iron Sword",
+		Rarity:    "uncommon",
+		Instance:  escrowedIronSword(),
+		Price:     100,
+		Currency:  "gold",
+		Fee:       5,
+		Status:    common.ListingActive,
+		CreatedAt: 1,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// storedAuctionListing returns the stored listing1, expiring at the given time.
+func storedAuctionListing(t *testing.T, expiresAt int64) *api.StorageObject {
+	return storedEscrow(t, "listing1", "l1", auctionListing("listing1", expiresAt))
+}
+
+// sellerNotified matches the notification of the listing status sent to its seller.
+func sellerNotified(status common.ListingStatus) interface{} {
+	return mock.MatchedBy(func(notifications []*runtime.NotificationSend) bool {
+		return len(notifications) == 1 && notifications[0].UserID == "user123" &&
+			notifications[0].Content["status"] == string(status) &&
+			notifications[0].Code == common.NotificationCodeAuction && notifications[0].Persistent
+	})
+}
+
+func TestAuctionSearchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   *SearchAuctionListingsRequest
+		query string
+	}{
+		{name: "no filter", req: &SearchAuctionListingsRequest{}, query: "+value.expires_at:>1000"},
+		{
+			name:  "item and rarity",
+			req:   &SearchAuctionListingsRequest{Item: "Iron Sword", Rarity: "uncommon"},
+			query: `+value.expires_at:>1000 +value.item:"Iron Sword" +value.rarity:"uncommon"`,
+		},
+		{
+			name:  "price range",
+			req:   &SearchAuctionListingsRequest{Currency: "gold", MinPrice: 10, MaxPrice: 50},
+			query: `+value.expires_at:>1000 +value.currency:"gold" +value.price:>=10 +value.price:<=50`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the function and assert
+			assert.Equal(t, tt.query, auctionSearchQuery(tt.req, 1000))
+		})
+	}
+}
+
+func TestAuctionHouseViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.AuctionHouse = &common.AuctionHouseSettings{Currencies: []string{"gold", " ", "gold"}, ListingFeePercent: 150, MaxListingsPerSeller: -1}
+
+	// Call the function
+	violations := auctionHouseViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "auction_house.listing_ttl", Message: "must be positive, got 0"},
+		{Path: "auction_house.currencies[1]", Message: "must not be empty"},
+		{Path: "auction_house.currencies[2]", Message: `duplicates the currency "gold"`},
+		{Path: "auction_house.listing_fee_percent", Message: "must be between 0 and 100, got 150"},
+		{Path: "auction_house.max_listings_per_seller", Message: "must not be negative, got -1"},
+	}, violations)
+}
+
+func TestCreateAuctionListing_EscrowsInstance(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CreateAuctionListing RPC called").Once()
+	mockLogger.On("Info", "User %s listed item instance %s in auction listing %s", "user123", "iron", mock.Anything).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "iron", Item: "Iron Sword", Rarity: "uncommon"},
+		&common.ItemInstance{ID: "wood", Item: "Wooden Sword", Rarity: "common"},
+	), nil)
+	nk.On("StorageList", ctx, common.EmptyString, "user123", common.StorageAuctionListings, 20, common.EmptyString).
+		Return([]*api.StorageObject{storedAuctionListing(t, time.Now().Add(time.Hour).Unix())}, common.EmptyString, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		var listing common.AuctionListing
+		_ = json.Unmarshal([]byte(writes[1].Value), &listing)
+		return len(writes) == 2 && writes[0].Version == "v1" && len(inventory.Items) == 1 && inventory.Items[0].ID == "wood" &&
+			writes[1].Collection == common.StorageAuctionListings && writes[1].UserID == "user123" &&
+			writes[1].Version == "*" && listing.Item == "Iron Sword" && listing.Rarity == "uncommon" &&
+			listing.Instance.ID == "iron" && listing.ExpiresAt == listing.CreatedAt+172800
+	}), []*runtime.StorageDelete(nil), mock.MatchedBy(func(updates []*runtime.WalletUpdate) bool {
+		// The fee of 5% of the price is charged when listing
+		return len(updates) == 1 && updates[0].UserID == "user123" && updates[0].Changeset["gold"] == -12 &&
+			updates[0].Metadata["source"] == common.WalletSourceAuction
+	}), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := CreateAuctionListing(ctx, mockLogger, nil, nk, `{"instance_id":"iron","price":250,"currency":"gold"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp AuctionListingResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, int64(250), resp.Listing.Price)
+	assert.Equal(t, int64(12), resp.Listing.Fee)
+	assert.Equal(t, common.ListingActive, resp.Listing.Status)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCreateAuctionListing_ListingLimit(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CreateAuctionListing RPC called").Once()
+	mockLogger.On("Error", "User %s reached the limit of %d auction listings", "user123", 2).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	config := embeddedGameConfig(t)
+	config.AuctionHouse.MaxListingsPerSeller = 2
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "iron", Item: "Iron Sword", Rarity: "uncommon"},
+	), nil)
+	nk.On("StorageList", ctx, common.EmptyString, "user123", common.StorageAuctionListings, 2, common.EmptyString).
+		Return([]*api.StorageObject{{Key: "listing1"}, {Key: "listing2"}}, "next", nil)
+
+	// Call the function
+	result, err := CreateAuctionListing(ctx, mockLogger, nil, nk, `{"instance_id":"iron","price":250,"currency":"gold"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrListingLimit, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCreateAuctionListing_CurrencyNotAllowed(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CreateAuctionListing RPC called").Once()
+	mockLogger.On("Error", "Auction listings cannot be priced in currency %s", "scrap").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := CreateAuctionListing(ctx, mockLogger, nil, nk, `{"instance_id":"iron","price":250,"currency":"scrap"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSearchAuctionListings_QueriesIndex(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SearchAuctionListings RPC called").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageIndexList", ctx, common.EmptyString, auctionListingsIndex, mock.MatchedBy(func(query string) bool {
+		return strings.HasPrefix(query, "+value.expires_at:>") && strings.HasSuffix(query, ` +value.item:"Iron Sword" +value.price:<=150`)
+	}), 10, []string{"value.price"}, "page1").Return(&api.StorageObjects{
+		Objects: []*api.StorageObject{storedAuctionListing(t, time.Now().Add(time.Hour).Unix())},
+	}, "page2", nil)
+
+	// Call the function
+	result, err := SearchAuctionListings(ctx, mockLogger, nil, nk, `{"item":"Iron Sword","max_price":150,"limit":10,"cursor":"page1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp SearchAuctionListingsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Len(t, resp.Listings, 1)
+	assert.Equal(t, "listing1", resp.Listings[0].ID)
+	assert.Equal(t, "page2", resp.Cursor)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSearchAuctionListings_UnknownItem(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SearchAuctionListings RPC called").Once()
+	mockLogger.On("Error", "Item %s not found", `Sword" OR "`).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := SearchAuctionListings(ctx, mockLogger, nil, nk, `{"item":"Sword\" OR \""}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestBuyAuctionListing_TransfersItemAndCurrencyAtomically(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "BuyAuctionListing RPC called").Once()
+	mockLogger.On("Info", "User %s bought auction listing %s of user %s", "user456", "listing1", "user123").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, auctionListingRead("user123", "listing1")).Return([]*api.StorageObject{
+		storedAuctionListing(t, time.Now().Add(time.Hour).Unix()),
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user456")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "wood", Item: "Wooden Sword", Rarity: "common"},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].UserID == "user456" && writes[0].Version == "v1" &&
+			len(inventory.Items) == 2 && inventory.Items[1].ID == "iron" && inventory.Items[1].AcquiredAt > 10
+	}), []*runtime.StorageDelete{{Collection: common.StorageAuctionListings, Key: "listing1", UserID: "user123", Version: "l1"}}, []*runtime.WalletUpdate{{
+		UserID:    "user456",
+		Changeset: map[string]int64{"gold": -100},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceAuction, "listing_id": "listing1"},
+	}, {
+		UserID:    "user123",
+		Changeset: map[string]int64{"gold": 100},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceAuction, "listing_id": "listing1"},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+	nk.On("NotificationsSend", ctx, sellerNotified(common.ListingSold)).Return(nil)
+
+	// Call the function
+	result, err := BuyAuctionListing(ctx, mockLogger, nil, nk, `{"listing_id":"listing1","seller_id":"user123"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp AuctionListingResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, common.ListingSold, resp.Listing.Status)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestBuyAuctionListing_InsufficientFunds(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "BuyAuctionListing RPC called").Once()
+	mockLogger.On("Error", "Wallet of user %s cannot afford %d %s", "user456", int64(100), "gold").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, auctionListingRead("user123", "listing1")).Return([]*api.StorageObject{
+		storedAuctionListing(t, time.Now().Add(time.Hour).Unix()),
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user456")).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.Anything, mock.Anything, mock.Anything, true).
+		Return(nil, nil, &runtime.WalletNegativeError{UserID: "user456", Path: "gold", Current: 40, Amount: -100})
+
+	// Call the function
+	result, err := BuyAuctionListing(ctx, mockLogger, nil, nk, `{"listing_id":"listing1","seller_id":"user123"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInsufficientFunds, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestBuyAuctionListing_OwnListing(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "BuyAuctionListing RPC called").Once()
+	mockLogger.On("Error", "User %s cannot buy their own auction listing %s", "user123", "listing1").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, auctionListingRead("user123", "listing1")).Return([]*api.StorageObject{
+		storedAuctionListing(t, time.Now().Add(time.Hour).Unix()),
+	}, nil)
+
+	// Call the function
+	result, err := BuyAuctionListing(ctx, mockLogger, nil, nk, `{"listing_id":"listing1","seller_id":"user123"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestBuyAuctionListing_ExpiredListingReturnsInstance(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "BuyAuctionListing RPC called").Once()
+	mockLogger.On("Info", "Auction listing %s of user %s %s", "listing1", "user123", common.ListingExpired).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, auctionListingRead("user123", "listing1")).Return([]*api.StorageObject{
+		storedAuctionListing(t, time.Now().Add(-time.Minute).Unix()),
+	}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "wood", Item: "Wooden Sword", Rarity: "common"},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].UserID == "user123" && len(inventory.Items) == 2 &&
+			inventory.Items[1].ID == "iron" && inventory.Items[1].AcquiredAt == 10
+	}), []*runtime.StorageDelete{{Collection: common.StorageAuctionListings, Key: "listing1", UserID: "user123", Version: "l1"}},
+		[]*runtime.WalletUpdate(nil), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+	nk.On("NotificationsSend", ctx, sellerNotified(common.ListingExpired)).Return(nil)
+
+	// Call the function
+	result, err := BuyAuctionListing(ctx, mockLogger, nil, nk, `{"listing_id":"listing1","seller_id":"user123"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrListingExpired, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCancelAuctionListing_ReturnsInstance(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CancelAuctionListing RPC called").Once()
+	mockLogger.On("Info", "Auction listing %s of user %s %s", "listing1", "user123", common.ListingCancelled).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, auctionListingRead("user123", "listing1")).Return([]*api.StorageObject{
+		storedAuctionListing(t, time.Now().Add(time.Hour).Unix()),
+	}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].Version == "*"
+	}), []*runtime.StorageDelete{{Collection: common.StorageAuctionListings, Key: "listing1", UserID: "user123", Version: "l1"}},
+		[]*runtime.WalletUpdate(nil), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := CancelAuctionListing(ctx, mockLogger, nil, nk, `{"listing_id":"listing1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp AuctionListingResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, common.ListingCancelled, resp.Listing.Status)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCancelAuctionListing_NotTheSeller(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CancelAuctionListing RPC called").Once()
+	mockLogger.On("Error", "Auction listing %s not found", "listing1").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+
+	// The listing of user123 is not among the objects of user456
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, auctionListingRead("user456", "listing1")).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := CancelAuctionListing(ctx, mockLogger, nil, nk, `{"listing_id":"listing1"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestListAuctionListings_ReadsListingsOfCaller(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ListAuctionListings RPC called").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageList", ctx, common.EmptyString, "user123", common.StorageAuctionListings, 10, "page1").
+		Return([]*api.StorageObject{storedAuctionListing(t, time.Now().Add(-time.Minute).Unix())}, "page2", nil)
+
+	// Call the function
+	result, err := ListAuctionListings(ctx, mockLogger, nil, nk, `{"limit":10,"cursor":"page1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp ListAuctionListingsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Len(t, resp.Listings, 1)
+	assert.Equal(t, "listing1", resp.Listings[0].ID)
+	assert.Equal(t, "page2", resp.Cursor)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestExpireAuctionListings_ReturnsInstancesOfExpiredListings(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ExpireAuctionListings RPC called").Once()
+	mockLogger.On("Info", "Auction listing %s of user %s %s", "listing1", "user123", common.ListingExpired).Once()
+
+	ctx := context.Background()
+	sold := storedEscrow(t, "listing2", "l2", auctionListing("listing2", time.Now().Add(-time.Hour).Unix()))
+	pending := storedEscrow(t, "listing3", "l3", auctionListing("listing3", time.Now().Add(time.Hour).Unix()))
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageList", ctx, common.EmptyString, common.EmptyString, common.StorageAuctionListings, 50, "page1").
+		Return([]*api.StorageObject{storedAuctionListing(t, time.Now().Add(-time.Minute).Unix()), sold, pending}, "page2", nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.Anything,
+		[]*runtime.StorageDelete{{Collection: common.StorageAuctionListings, Key: "listing1", UserID: "user123", Version: "l1"}},
+		[]*runtime.WalletUpdate(nil), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil).Once()
+	nk.On("NotificationsSend", ctx, sellerNotified(common.ListingExpired)).Return(nil).Once()
+
+	// The second listing sold after the page was listed
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.Anything,
+		[]*runtime.StorageDelete{{Collection: common.StorageAuctionListings, Key: "listing2", UserID: "user123", Version: "l2"}},
+		[]*runtime.WalletUpdate(nil), true).Return(nil, nil, runtime.ErrStorageRejectedVersion).Once()
+	mockLogger.On("Error", "Player data was modified concurrently: %+v", runtime.ErrStorageRejectedVersion).Once()
+
+	// Call the function
+	result, err := ExpireAuctionListings(ctx, mockLogger, nil, nk, `{"limit":50,"cursor":"page1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"expired":["listing1"],"cursor":"page2"}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestExpireAuctionListings_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ExpireAuctionListings RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ExpireAuctionListings(ctx, mockLogger, nil, nil, common.EmptyString)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
@@ -0,0 +1,286 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	CraftRequest struct {
+		Recipe string `json:"recipe"`
+		// IDs of the item instances to consume, the oldest instances of the input items if omitted
+		InstanceIDs []string `json:"instance_ids,omitempty"`
+		// ID of the item instance to upgrade, for upgrade recipes
+		TargetID string `json:"target_id,omitempty"`
+	}
+
+	CraftResponse struct {
+		Recipe   string                 `json:"recipe"`
+		Consumed []*common.ItemInstance `json:"consumed"`
+		Cost     map[string]int64       `json:"cost,omitempty"`
+		Crafted  *common.ItemInstance   `json:"crafted,omitempty"`
+		Upgraded *common.ItemInstance   `json:"upgraded,omitempty"`
+	}
+)
+
+// Craft consumes the inputs of a recipe from the caller's inventory and wallet to craft a new item instance or to
+// upgrade one of their instances. The inventory and the wallet change in one transaction, so nothing is consumed
+// unless the recipe completes.
+func Craft(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("Craft RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req CraftRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.Recipe == common.EmptyString {
+		logger.Error("Payload did not contain a recipe")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	now := time.Now().Unix()
+	config, derivation, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	recipe, ok := config.Recipes[req.Recipe]
+	if !ok {
+		logger.Error("Recipe %s not found", req.Recipe)
+		return common.EmptyString, common.ErrNotFound
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	var target *common.ItemInstance
+	if recipe.Upgrade != nil {
+		if target, err = upgradeTarget(logger, userID, inventory, recipe.Upgrade, req.TargetID); err != nil {
+			return common.EmptyString, err
+		}
+	}
+	consumed, err := craftInputs(logger, userID, inventory, req.Recipe, &recipe, req.InstanceIDs, target)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	inventory.Items = slices.DeleteFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return slices.Contains(consumed, instance)
+	})
+
+	resp := &CraftResponse{Recipe: req.Recipe, Consumed: consumed, Cost: recipe.Cost}
+	if recipe.Upgrade != nil {
+		target.Level++
+		target.Damage += recipe.Upgrade.Damage
+		target.Defense += recipe.Upgrade.Defense
+		target.UpgradeDamage += recipe.Upgrade.Damage
+		target.UpgradeDefense += recipe.Upgrade.Defense
+		resp.Upgraded = target
+	} else {
+		item, tier := lootItem(&config.Rarity, recipe.Output)
+		if item == nil {
+			logger.Error("Output item %s of recipe %s not found", recipe.Output, req.Recipe)
+			return common.EmptyString, common.ErrInternalError
+		}
+		if resp.Crafted, err = newItemInstance(config, active.Version, derivation, item, tier, common.ItemSourceCraft, now); err != nil {
+			logger.Error("Cannot create item instance: %+v", err)
+			return common.EmptyString, common.ErrInternalError
+		}
+		if err = addInventoryItem(logger, config, userID, inventory, resp.Crafted); err != nil {
+			return common.EmptyString, err
+		}
+	}
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	var walletUpdates []*runtime.WalletUpdate
+	if len(recipe.Cost) > 0 {
+		changeset := make(map[string]int64, len(recipe.Cost))
+		for currency, amount := range recipe.Cost {
+			changeset[currency] = -amount
+		}
+		walletUpdates = append(walletUpdates, &runtime.WalletUpdate{
+			UserID:    userID,
+			Changeset: changeset,
+			Metadata:  map[string]interface{}{"source": common.ItemSourceCraft, "recipe": req.Recipe},
+		})
+	}
+
+	// The inputs are only consumed if the wallet can afford the cost, the inventory and the wallet change in one
+	// transaction guarded by the version the inventory was read at
+	if err = updatePlayerData(ctx, logger, nk, []*runtime.StorageWrite{write}, nil, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s crafted recipe %s consuming %d item instances", userID, req.Recipe, len(consumed))
+
+	return marshalResponse(logger, resp)
+}
+
+// upgradeTarget returns the instance of the inventory the upgrade applies to, which must be an instance of the
+// upgraded item below the maximum level.
+func upgradeTarget(logger runtime.Logger, userID string, inventory *common.Inventory, upgrade *common.RecipeUpgrade, targetID string) (*common.ItemInstance, error) {
+	if targetID == common.EmptyString {
+		logger.Error("Payload did not contain the instance ID of the item to upgrade")
+		return nil, common.ErrInvalidArgument
+	}
+	index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return instance.ID == targetID
+	})
+	if index < 0 {
+		logger.Error("Item instance %s of user %s not found", targetID, userID)
+		return nil, common.ErrNotFound
+	}
+
+	target := inventory.Items[index]
+	if target.Item != upgrade.Item {
+		logger.Error("Item instance %s of user %s is a %s, not a %s", target.ID, userID, target.Item, upgrade.Item)
+		return nil, common.ErrInvalidArgument
+	}
+	if target.Level >= upgrade.MaxLevel {
+		logger.Error("Item instance %s of user %s is at the maximum level %d", target.ID, userID, upgrade.MaxLevel)
+		return nil, common.ErrUpgradeLimit
+	}
+
+	return target, nil
+}
+
+// craftInputs returns the item instances of the inventory the recipe consumes. The given instances must be inputs of
+// the recipe, otherwise the oldest instances of every input item are picked. Equipped and locked instances and the
+// upgraded one are never consumed, and ErrCraftInputsMissing is returned when the inventory does not hold enough instances.
+func craftInputs(logger runtime.Logger, userID string, inventory *common.Inventory, recipeID string, recipe *common.Recipe, instanceIDs []string, target *common.ItemInstance) ([]*common.ItemInstance, error) {
+	equipped := equippedInstanceIDs(inventory)
+	needed := make(map[string]int, len(recipe.Inputs))
+	for _, input := range recipe.Inputs {
+		needed[input.Item] += input.Count
+	}
+
+	consumed := make([]*common.ItemInstance, 0, len(instanceIDs))
+	if len(instanceIDs) > 0 {
+		for _, instanceID := range instanceIDs {
+			index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+				return instance.ID == instanceID
+			})
+			if index < 0 {
+				logger.Error("Item instance %s of user %s not found", instanceID, userID)
+				return nil, common.ErrNotFound
+			}
+			instance := inventory.Items[index]
+			switch {
+			case slices.Contains(consumed, instance):
+				logger.Error("Item instance %s of user %s is listed more than once", instance.ID, userID)
+				return nil, common.ErrInvalidArgument
+			case instance == target:
+				logger.Error("Item instance %s of user %s is the upgraded instance", instance.ID, userID)
+				return nil, common.ErrInvalidArgument
+			case equipped[instance.ID]:
+				logger.Error("Item instance %s of user %s is equipped", instance.ID, userID)
+				return nil, common.ErrItemEquipped
+			case instance.Locked:
+				logger.Error("Item instance %s of user %s is locked", instance.ID, userID)
+				return nil, common.ErrItemLocked
+			case needed[instance.Item] == 0:
+				logger.Error("Item instance %s of user %s is not an input of recipe %s", instance.ID, userID, recipeID)
+				return nil, common.ErrInvalidArgument
+			}
+			needed[instance.Item]--
+			consumed = append(consumed, instance)
+		}
+	} else {
+		for _, instance := range inventory.Items {
+			if needed[instance.Item] > 0 && instance != target && !equipped[instance.ID] && !instance.Locked {
+				needed[instance.Item]--
+				consumed = append(consumed, instance)
+			}
+		}
+	}
+
+	var missing []string
+	for _, input := range recipe.Inputs {
+		if needed[input.Item] > 0 {
+			missing = append(missing, fmt.Sprintf("%d %s", needed[input.Item], input.Item))
+			needed[input.Item] = 0
+		}
+	}
+	if len(missing) > 0 {
+		logger.Error("User %s is missing %s for recipe %s", userID, strings.Join(missing, ", "), recipeID)
+		return nil, common.ErrCraftInputsMissing
+	}
+
+	return consumed, nil
+}
+
+// recipeViolations returns the semantic violations of the recipes: inputs, outputs and upgrades referencing items
+// missing from the catalog, non-positive counts and costs, and recipes consuming or producing nothing.
+func recipeViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+	knownItem := func(path, name string) {
+		if item, _ := lootItem(&config.Rarity, name); item == nil {
+			violations.add(path, "references the unknown item %q", name)
+		}
+	}
+
+	for _, id := range sortedKeys(config.Recipes) {
+		recipe := config.Recipes[id]
+		recipePath := "recipes." + id
+
+		if len(recipe.Inputs) == 0 && len(recipe.Cost) == 0 {
+			violations.add(recipePath, "must consume items or currency")
+		}
+		for i, input := range recipe.Inputs {
+			inputPath := fmt.Sprintf("%s.inputs[%d]", recipePath, i)
+			knownItem(inputPath+".item", input.Item)
+			if input.Count <= 0 {
+				violations.add(inputPath+".count", "must be positive, got %d", input.Count)
+			}
+		}
+		for _, currency := range sortedKeys(recipe.Cost) {
+			if amount := recipe.Cost[currency]; amount <= 0 {
+				violations.add(recipePath+".cost."+currency, "must be positive, got %d", amount)
+			}
+		}
+
+		if (recipe.Output == common.EmptyString) == (recipe.Upgrade == nil) {
+			violations.add(recipePath, "must set exactly one of output or upgrade")
+			continue
+		}
+		if recipe.Output != common.EmptyString {
+			knownItem(recipePath+".output", recipe.Output)
+			continue
+		}
+
+		upgradePath := recipePath + ".upgrade"
+		knownItem(upgradePath+".item", recipe.Upgrade.Item)
+		if recipe.Upgrade.MaxLevel <= 0 {
+			violations.add(upgradePath+".max_level", "must be positive, got %d", recipe.Upgrade.MaxLevel)
+		}
+		if recipe.Upgrade.Damage < 0 || recipe.Upgrade.Defense < 0 {
+			violations.add(upgradePath, "must not lower damage or defense")
+		} else if recipe.Upgrade.Damage == 0 && recipe.Upgrade.Defense == 0 {
+			violations.add(upgradePath, "must raise damage or defense")
+		}
+	}
+
+	return violations
+}
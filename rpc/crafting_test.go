@@ -0,0 +1,263 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// craftingInventory returns a stored inventory of four Iron Swords, the first one equipped, and a Steel Sword.
+func craftingInventory(t *testing.T) []*api.StorageObject {
+	inventoryJSON, err := json.Marshal(&common.Inventory{
+		Items: []*common.ItemInstance{
+			{ID: "iron1", Item: "Iron Sword", Rarity: "uncommon", Damage: 20},
+			{ID: "iron2", Item: "Iron Sword", Rarity: "uncommon", Damage: 20},
+			{ID: "iron3", Item: "Iron Sword", Rarity: "uncommon", Damage: 20},
+			{ID: "iron4", Item: "Iron Sword", Rarity: "uncommon", Damage: 20},
+			{ID: "steel", Item: "Steel Sword", Rarity: "rare", Damage: 40},
+		},
+		Loadouts: map[string]common.Loadout{
+			"default": {Slots: map[string]string{"weapon": "iron1"}},
+		},
+		ActiveLoadout: "default",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return []*api.StorageObject{{Value: string(inventoryJSON), Version: "v1"}}
+}
+
+func TestRecipeViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Recipes = map[string]common.Recipe{
+		"alchemy": {Output: "Gold Bar"},
+		"forge": {
+			Inputs: []common.RecipeInput{{Item: "Iron Sword", Count: 0}, {Item: "Bronze Sword", Count: 2}},
+			Cost:   map[string]int64{"gold": -5},
+			Output: "Steel Sword",
+			Upgrade: &common.RecipeUpgrade{
+				Item: "Steel Sword", Damage: 5, MaxLevel: 3,
+			},
+		},
+		"polish": {Cost: map[string]int64{"gold": 10}, Upgrade: &common.RecipeUpgrade{Item: "Steel Sword"}},
+	}
+
+	// Call the function
+	violations := recipeViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "recipes.alchemy", Message: "must consume items or currency"},
+		{Path: "recipes.alchemy.output", Message: `references the unknown item "Gold Bar"`},
+		{Path: "recipes.forge.inputs[0].count", Message: "must be positive, got 0"},
+		{Path: "recipes.forge.inputs[1].item", Message: `references the unknown item "Bronze Sword"`},
+		{Path: "recipes.forge.cost.gold", Message: "must be positive, got -5"},
+		{Path: "recipes.forge", Message: "must set exactly one of output or upgrade"},
+		{Path: "recipes.polish.upgrade.max_level", Message: "must be positive, got 0"},
+		{Path: "recipes.polish.upgrade", Message: "must raise damage or defense"},
+	}, violations)
+}
+
+func TestCraft_ConsumesOldestUnequippedInputs(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Craft RPC called").Once()
+	mockLogger.On("Info", "User %s crafted recipe %s consuming %d item instances", "user123", "steel_sword", 3).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(craftingInventory(t), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].Version == "v1" && len(inventory.Items) == 3 &&
+			inventory.Items[0].ID == "iron1" && inventory.Items[1].ID == "steel" &&
+			inventory.Items[2].Item == "Steel Sword" && inventory.Items[2].Source == common.ItemSourceCraft
+	}), []*runtime.StorageDelete(nil), []*runtime.WalletUpdate{{
+		UserID:    userID,
+		Changeset: map[string]int64{"gold": -50},
+		Metadata:  map[string]interface{}{"source": common.ItemSourceCraft, "recipe": "steel_sword"},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := Craft(ctx, mockLogger, nil, nk, `{"recipe":"steel_sword"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp CraftResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	consumed := make([]string, 0, len(resp.Consumed))
+	for _, instance := range resp.Consumed {
+		consumed = append(consumed, instance.ID)
+	}
+	assert.Equal(t, []string{"iron2", "iron3", "iron4"}, consumed)
+	assert.Equal(t, "Steel Sword", resp.Crafted.Item)
+	assert.Equal(t, 4, resp.Crafted.ConfigVersion)
+	assert.Nil(t, resp.Upgraded)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCraft_MissingInputs(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Craft RPC called").Once()
+	mockLogger.On("Error", "User %s is missing %s for recipe %s", "user123", "1 Iron Sword", "steel_sword").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(craftingInventory(t), nil)
+
+	// Call the function
+	result, err := Craft(ctx, mockLogger, nil, nk, `{"recipe":"steel_sword","instance_ids":["iron2","iron3"]}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrCraftInputsMissing, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCraft_EquippedInput(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Craft RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s is equipped", "iron1", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(craftingInventory(t), nil)
+
+	// Call the function
+	result, err := Craft(ctx, mockLogger, nil, nk, `{"recipe":"steel_sword","instance_ids":["iron1","iron2","iron3"]}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrItemEquipped, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCraft_InsufficientFunds(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Craft RPC called").Once()
+	mockLogger.On("Error", "Wallet of user %s cannot afford %d %s", "user123", int64(50), "gold").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(craftingInventory(t), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.Anything, []*runtime.StorageDelete(nil), mock.Anything, true).
+		Return(nil, nil, &runtime.WalletNegativeError{UserID: userID, Path: "gold", Current: 10, Amount: -50})
+
+	// Call the function
+	result, err := Craft(ctx, mockLogger, nil, nk, `{"recipe":"steel_sword"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInsufficientFunds, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCraft_UpgradesInstance(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Craft RPC called").Once()
+	mockLogger.On("Info", "User %s crafted recipe %s consuming %d item instances", "user123", "sharpen_steel_sword", 0).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(craftingInventory(t), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(inventory.Items) == 5 && inventory.Items[4].Level == 1 && inventory.Items[4].Damage == 45
+	}), []*runtime.StorageDelete(nil), []*runtime.WalletUpdate{{
+		UserID:    userID,
+		Changeset: map[string]int64{"gold": -100},
+		Metadata:  map[string]interface{}{"source": common.ItemSourceCraft, "recipe": "sharpen_steel_sword"},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := Craft(ctx, mockLogger, nil, nk, `{"recipe":"sharpen_steel_sword","target_id":"steel"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp CraftResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, &common.ItemInstance{
+		ID: "steel", Item: "Steel Sword", Rarity: "rare", Damage: 45, Level: 1, UpgradeDamage: 5,
+	}, resp.Upgraded)
+	assert.Nil(t, resp.Crafted)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCraft_UpgradeLimit(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Craft RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s is at the maximum level %d", "steel", "user123", 5).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t,
+		&common.ItemInstance{ID: "steel", Item: "Steel Sword", Rarity: "rare", Damage: 65, Level: 5, UpgradeDamage: 25},
+	), nil)
+
+	// Call the function
+	result, err := Craft(ctx, mockLogger, nil, nk, `{"recipe":"sharpen_steel_sword","target_id":"steel"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrUpgradeLimit, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRederiveItemStats_UpgradedInstance(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	instance := rolledItemInstance(t, config, "Steel Sword")
+	instance.Level = 2
+	instance.Damage += 10
+	instance.UpgradeDamage = 10
+
+	// Call the function
+	_, mismatches := rederiveItemStats(config, instance)
+
+	// Assertions
+	assert.Empty(t, mismatches)
+}
@@ -0,0 +1,429 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	EquipItemRequest struct {
+		Slot string `json:"slot"`
+		// Instance to equip, the slot is emptied if unset
+		InstanceID string `json:"instance_id,omitempty"`
+		// Loadout to change, the active one if unset
+		Loadout string `json:"loadout,omitempty"`
+	}
+
+	SelectLoadoutRequest struct {
+		Loadout string `json:"loadout"`
+	}
+
+	DeleteLoadoutRequest struct {
+		Loadout string `json:"loadout"`
+	}
+
+	DeleteLoadoutResponse struct {
+		ActiveLoadout string                    `json:"active_loadout"`
+		Loadouts      map[string]common.Loadout `json:"loadouts"`
+	}
+
+	ReadCombatStatsRequest struct {
+		// Loadout to compute the stats of, the active one if unset
+		Loadout string `json:"loadout,omitempty"`
+	}
+
+	S2SReadCombatStatsRequest struct {
+		UserID string `json:"user_id"`
+	}
+
+	// EquippedStats are the combat stats of a loadout along with the item instances equipped in it by slot ID.
+	EquippedStats struct {
+		Loadout  string                          `json:"loadout"`
+		Equipped map[string]*common.ItemInstance `json:"equipped"`
+		Stats    *CombatStats                    `json:"stats"`
+	}
+)
+
+// defaultLoadout is the loadout of the players who never selected one.
+const defaultLoadout = "default"
+
+// LoadCombatStats returns the authoritative combat stats of the player, computed from the item instances equipped in
+// their active loadout. Match code reads them here rather than trusting stats sent by the client.
+func LoadCombatStats(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) (*EquippedStats, error) {
+	inventory, _, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+	return equippedStats(inventory, activeLoadout(inventory)), nil
+}
+
+// equippedStats returns the combat stats of the named loadout of the inventory. Slots referencing instances the player
+// no longer owns are left out.
+func equippedStats(inventory *common.Inventory, loadout string) *EquippedStats {
+	stats := &EquippedStats{Loadout: loadout, Equipped: make(map[string]*common.ItemInstance)}
+
+	var instances []*common.ItemInstance
+	for slot, instanceID := range inventory.Loadouts[loadout].Slots {
+		index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+			return instance.ID == instanceID
+		})
+		if index >= 0 {
+			stats.Equipped[slot] = inventory.Items[index]
+			instances = append(instances, inventory.Items[index])
+		}
+	}
+	stats.Stats = combatStats(instances)
+
+	return stats
+}
+
+// activeLoadout returns the name of the loadout the player has equipped.
+func activeLoadout(inventory *common.Inventory) string {
+	if inventory.ActiveLoadout == common.EmptyString {
+		return defaultLoadout
+	}
+	return inventory.ActiveLoadout
+}
+
+// ensureLoadouts saves the active loadout of the inventory, empty if the player never equipped anything.
+func ensureLoadouts(inventory *common.Inventory) {
+	if inventory.Loadouts == nil {
+		inventory.Loadouts = make(map[string]common.Loadout)
+	}
+	inventory.ActiveLoadout = activeLoadout(inventory)
+	if _, ok := inventory.Loadouts[inventory.ActiveLoadout]; !ok {
+		inventory.Loadouts[inventory.ActiveLoadout] = common.Loadout{Slots: make(map[string]string)}
+	}
+}
+
+// equippedInstanceIDs returns the IDs of the item instances equipped in any saved loadout of the inventory.
+func equippedInstanceIDs(inventory *common.Inventory) map[string]bool {
+	equipped := make(map[string]bool)
+	for _, loadout := range inventory.Loadouts {
+		for _, instanceID := range loadout.Slots {
+			equipped[instanceID] = true
+		}
+	}
+	return equipped
+}
+
+// maxLoadouts returns the number of loadouts a player can save.
+func maxLoadouts(config *common.GameConfig) int {
+	if config.Inventory == nil || config.Inventory.Loadouts == 0 {
+		return 1
+	}
+	return config.Inventory.Loadouts
+}
+
+// equipmentSlotViolations returns the semantic violations of the equipment slots and of the item types they accept.
+func equipmentSlotViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+
+	if config.Inventory != nil && config.Inventory.Loadouts < 0 {
+		violations.add("inventory.loadouts", "must not be negative, got %d", config.Inventory.Loadouts)
+	}
+
+	ids := make(map[string]bool, len(config.EquipmentSlots))
+	accepted := make(map[string]bool)
+	for i, slot := range config.EquipmentSlots {
+		slotPath := fmt.Sprintf("equipment_slots[%d]", i)
+
+		switch {
+		case strings.TrimSpace(slot.ID) == common.EmptyString:
+			violations.add(slotPath+".id", "must not be empty")
+		case ids[slot.ID]:
+			violations.add(slotPath+".id", "duplicates the equipment slot ID %q", slot.ID)
+		default:
+			ids[slot.ID] = true
+		}
+
+		if strings.TrimSpace(slot.Name) == common.EmptyString && slot.NameID == common.EmptyString {
+			violations.add(slotPath+".name", "must not be empty")
+		}
+		if len(slot.Accepts) == 0 {
+			violations.add(slotPath+".accepts", "must not be empty")
+		}
+		for _, itemType := range slot.Accepts {
+			accepted[itemType] = true
+		}
+	}
+
+	for _, tier := range rarityTiers(&config.Rarity) {
+		for i, item := range tier.Items.Items {
+			if item.Type != common.EmptyString && !accepted[item.Type] {
+				violations.add(fmt.Sprintf("rarity.%s.items[%d].type", tier.Name, i), "is not accepted by any equipment slot, got %q", item.Type)
+			}
+		}
+	}
+
+	return violations
+}
+
+// EquipItem equips an item instance of the caller in a slot of one of their loadouts, or empties the slot.
+func EquipItem(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("EquipItem RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req EquipItemRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.Slot == common.EmptyString {
+		logger.Error("Payload did not contain an equipment slot")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+	slotIndex := slices.IndexFunc(config.EquipmentSlots, func(slot common.EquipmentSlot) bool {
+		return slot.ID == req.Slot
+	})
+	if slotIndex < 0 {
+		logger.Error("Unknown equipment slot %s", req.Slot)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	slot := config.EquipmentSlots[slotIndex]
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	ensureLoadouts(inventory)
+	if req.Loadout == common.EmptyString {
+		req.Loadout = inventory.ActiveLoadout
+	}
+	loadout, ok := inventory.Loadouts[req.Loadout]
+	if !ok {
+		logger.Error("Loadout %s of user %s not found", req.Loadout, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	if loadout.Slots == nil {
+		loadout.Slots = make(map[string]string)
+	}
+
+	if req.InstanceID == common.EmptyString {
+		delete(loadout.Slots, slot.ID)
+	} else {
+		index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+			return instance.ID == req.InstanceID
+		})
+		if index < 0 {
+			logger.Error("Item instance %s of user %s not found", req.InstanceID, userID)
+			return common.EmptyString, common.ErrNotFound
+		}
+		instance := inventory.Items[index]
+
+		item, _ := lootItem(&config.Rarity, instance.Item)
+		if item == nil || !slices.Contains(slot.Accepts, item.Type) {
+			logger.Error("Item %s does not fit equipment slot %s", instance.Item, slot.ID)
+			return common.EmptyString, common.ErrInvalidArgument
+		}
+
+		// An instance is equipped in a single slot of a loadout, equipping it elsewhere moves it
+		for other, instanceID := range loadout.Slots {
+			if instanceID == instance.ID {
+				delete(loadout.Slots, other)
+			}
+		}
+		loadout.Slots[slot.ID] = instance.ID
+	}
+	inventory.Loadouts[req.Loadout] = loadout
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writePlayerObjects(ctx, logger, nk, write); err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, equippedStats(inventory, req.Loadout))
+}
+
+// SelectLoadout switches the caller to one of their loadouts, saving a new empty one if they have none by that name.
+func SelectLoadout(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("SelectLoadout RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req SelectLoadoutRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if strings.TrimSpace(req.Loadout) == common.EmptyString {
+		logger.Error("Payload did not contain a loadout")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	ensureLoadouts(inventory)
+	if _, ok := inventory.Loadouts[req.Loadout]; !ok {
+		if limit := maxLoadouts(config); len(inventory.Loadouts) >= limit {
+			logger.Error("User %s cannot save more than %d loadouts", userID, limit)
+			return common.EmptyString, common.ErrLoadoutLimit
+		}
+		inventory.Loadouts[req.Loadout] = common.Loadout{Slots: make(map[string]string)}
+	}
+	inventory.ActiveLoadout = req.Loadout
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writePlayerObjects(ctx, logger, nk, write); err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, equippedStats(inventory, req.Loadout))
+}
+
+// DeleteLoadout deletes a saved loadout of the caller other than the active one.
+func DeleteLoadout(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("DeleteLoadout RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req DeleteLoadoutRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.Loadout == common.EmptyString {
+		logger.Error("Payload did not contain a loadout")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	ensureLoadouts(inventory)
+	if req.Loadout == inventory.ActiveLoadout {
+		logger.Error("Cannot delete the active loadout %s of user %s", req.Loadout, userID)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if _, ok := inventory.Loadouts[req.Loadout]; !ok {
+		logger.Error("Loadout %s of user %s not found", req.Loadout, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	delete(inventory.Loadouts, req.Loadout)
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writePlayerObjects(ctx, logger, nk, write); err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, &DeleteLoadoutResponse{
+		ActiveLoadout: inventory.ActiveLoadout,
+		Loadouts:      inventory.Loadouts,
+	})
+}
+
+// ReadCombatStats returns the combat stats of the caller's active loadout, or of the named one.
+func ReadCombatStats(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ReadCombatStats RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req ReadCombatStatsRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+
+	inventory, _, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	ensureLoadouts(inventory)
+	if req.Loadout == common.EmptyString {
+		req.Loadout = inventory.ActiveLoadout
+	}
+	if _, ok := inventory.Loadouts[req.Loadout]; !ok {
+		logger.Error("Loadout %s of user %s not found", req.Loadout, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+
+	return marshalResponse(logger, equippedStats(inventory, req.Loadout))
+}
+
+// S2SReadCombatStats returns the authoritative combat stats of a player to a trusted server.
+func S2SReadCombatStats(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("S2SReadCombatStats RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req S2SReadCombatStatsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString {
+		logger.Error("Payload did not contain a user ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	stats, err := LoadCombatStats(ctx, logger, nk, req.UserID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, stats)
+}
@@ -0,0 +1,276 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// equippedInventory returns the storage object of an inventory with a sword and a shield equipped in the default
+// loadout and a spare sword.
+func equippedInventory(t *testing.T) []*api.StorageObject {
+	inventoryJSON, err := json.Marshal(&common.Inventory{
+		Items: []*common.ItemInstance{
+			{ID: "sword", Item: "Steel Sword", Rarity: "rare", Damage: 40, Affixes: []common.ItemAffix{{ID: "fiery", Stat: "fire_damage", Value: 10}}},
+			{ID: "shield", Item: "Dragon Shield", Rarity: "rare", Defense: 30},
+			{ID: "spare", Item: "Iron Sword", Rarity: "uncommon", Damage: 20, Broken: true},
+		},
+		Loadouts: map[string]common.Loadout{
+			"default": {Slots: map[string]string{"weapon": "sword", "off_hand": "shield"}},
+		},
+		ActiveLoadout: "default",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return []*api.StorageObject{{Value: string(inventoryJSON), Version: "v1"}}
+}
+
+func TestEquipmentSlotViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Inventory.Loadouts = -1
+	config.EquipmentSlots = append(config.EquipmentSlots,
+		common.EquipmentSlot{ID: "weapon", Name: "Second weapon", Accepts: []string{"weapon"}},
+		common.EquipmentSlot{ID: "ring"},
+	)
+	config.Rarity.Common.Items[0].Type = "ring"
+
+	// Call the function
+	violations := equipmentSlotViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "inventory.loadouts", Message: "must not be negative, got -1"},
+		{Path: "equipment_slots[3].id", Message: `duplicates the equipment slot ID "weapon"`},
+		{Path: "equipment_slots[4].name", Message: "must not be empty"},
+		{Path: "equipment_slots[4].accepts", Message: "must not be empty"},
+		{Path: "rarity.common.items[0].type", Message: `is not accepted by any equipment slot, got "ring"`},
+	}, violations)
+}
+
+func TestEquippedStats_SkipsBrokenAndMissingInstances(t *testing.T) {
+	// Setup
+	inventory := &common.Inventory{
+		Items: []*common.ItemInstance{
+			{ID: "sword", Damage: 40},
+			{ID: "armor", Defense: 20, Broken: true},
+		},
+		Loadouts: map[string]common.Loadout{
+			"default": {Slots: map[string]string{"weapon": "sword", "armor": "armor", "off_hand": "discarded"}},
+		},
+	}
+
+	// Call the function
+	stats := equippedStats(inventory, "default")
+
+	// Assertions
+	assert.Equal(t, "default", stats.Loadout)
+	assert.Len(t, stats.Equipped, 2)
+	assert.Equal(t, &CombatStats{Damage: 40, Stats: map[string]int{}}, stats.Stats)
+}
+
+func TestEquipItem_MovesItemWithinLoadout(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "EquipItem RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	config := embeddedGameConfig(t)
+	config.EquipmentSlots[1].Accepts = append(config.EquipmentSlots[1].Accepts, "weapon")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(equippedInventory(t), nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].Version == "v1" &&
+			assert.ObjectsAreEqual(map[string]string{"off_hand": "sword"}, inventory.Loadouts["default"].Slots)
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := EquipItem(ctx, mockLogger, nil, nk, `{"slot":"off_hand","instance_id":"sword"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp EquippedStats
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "sword", resp.Equipped["off_hand"].ID)
+	assert.Equal(t, &CombatStats{Damage: 40, Stats: map[string]int{"fire_damage": 10}}, resp.Stats)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestEquipItem_DoesNotFitSlot(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "EquipItem RPC called").Once()
+	mockLogger.On("Error", "Item %s does not fit equipment slot %s", "Steel Sword", "armor").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(equippedInventory(t), nil)
+
+	// Call the function
+	result, err := EquipItem(ctx, mockLogger, nil, nk, `{"slot":"armor","instance_id":"sword"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSelectLoadout_SavesNewLoadout(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SelectLoadout RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(equippedInventory(t), nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return inventory.ActiveLoadout == "pvp" && len(inventory.Loadouts) == 2
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := SelectLoadout(ctx, mockLogger, nil, nk, `{"loadout":"pvp"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp EquippedStats
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "pvp", resp.Loadout)
+	assert.Empty(t, resp.Equipped)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSelectLoadout_Limit(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SelectLoadout RPC called").Once()
+	mockLogger.On("Error", "User %s cannot save more than %d loadouts", "user123", 1).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	config := embeddedGameConfig(t)
+	config.Inventory.Loadouts = 0
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := SelectLoadout(ctx, mockLogger, nil, nk, `{"loadout":"pvp"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrLoadoutLimit, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDeleteLoadout_ActiveLoadout(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DeleteLoadout RPC called").Once()
+	mockLogger.On("Error", "Cannot delete the active loadout %s of user %s", "default", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(equippedInventory(t), nil)
+
+	// Call the function
+	result, err := DeleteLoadout(ctx, mockLogger, nil, nk, `{"loadout":"default"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestS2SReadCombatStats_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SReadCombatStats RPC called").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(equippedInventory(t), nil)
+
+	// Call the function
+	result, err := S2SReadCombatStats(ctx, mockLogger, nil, nk, `{"user_id":"user123"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp EquippedStats
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "default", resp.Loadout)
+	assert.Equal(t, &CombatStats{Damage: 40, Defense: 30, Stats: map[string]int{"fire_damage": 10}}, resp.Stats)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestS2SReadCombatStats_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SReadCombatStats RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := S2SReadCombatStats(ctx, mockLogger, nil, nil, `{"user_id":"user123"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDiscardItem_Equipped(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiscardItem RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s is equipped", "shield", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(equippedInventory(t), nil)
+
+	// Call the function
+	result, err := DiscardItem(ctx, mockLogger, nil, nk, `{"instance_id":"shield"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrItemEquipped, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
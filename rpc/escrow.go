@@ -0,0 +1,149 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"time"
+)
+
+type (
+	ExpireEscrowsRequest struct {
+		Limit  int    `json:"limit,omitempty"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+
+	// ExpireEscrowsResponse lists the escrows of a page closed on expiry and the ones that failed to close.
+	ExpireEscrowsResponse struct {
+		Expired []string `json:"expired"`
+		Failed  []string `json:"failed,omitempty"`
+		Cursor  string   `json:"cursor,omitempty"`
+	}
+)
+
+const maxExpireEscrowsLimit = 100
+
+// takeEscrowedItems removes the listed item instances from the inventory of the user and returns them. Every instance
+// must be owned, listed once, unequipped, unlocked and tradable.
+func takeEscrowedItems(logger runtime.Logger, config *common.GameConfig, userID string, inventory *common.Inventory, instanceIDs []string) ([]*common.ItemInstance, error) {
+	equipped := equippedInstanceIDs(inventory)
+	taken := make([]*common.ItemInstance, 0, len(instanceIDs))
+	for i, instanceID := range instanceIDs {
+		if slices.Contains(instanceIDs[:i], instanceID) {
+			logger.Error("Item instance %s of user %s is listed more than once", instanceID, userID)
+			return nil, common.ErrInvalidArgument
+		}
+		index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+			return instance.ID == instanceID
+		})
+		if index < 0 {
+			logger.Error("Item instance %s of user %s not found", instanceID, userID)
+			return nil, common.ErrNotFound
+		}
+
+		instance := inventory.Items[index]
+		switch {
+		case equipped[instance.ID]:
+			logger.Error("Item instance %s of user %s is equipped", instance.ID, userID)
+			return nil, common.ErrItemEquipped
+		case instance.Locked:
+			logger.Error("Item instance %s of user %s is locked", instance.ID, userID)
+			return nil, common.ErrItemLocked
+		case !itemTradable(config, instance):
+			logger.Error("Item instance %s of user %s cannot be traded", instance.ID, userID)
+			return nil, common.ErrItemUntradable
+		}
+		taken = append(taken, instance)
+	}
+
+	inventory.Items = slices.DeleteFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return slices.Contains(taken, instance)
+	})
+	return taken, nil
+}
+
+// itemTradable reports whether the item of the instance can be traded. Instances of items removed from the
+// configuration keep being tradable.
+func itemTradable(config *common.GameConfig, instance *common.ItemInstance) bool {
+	item, _ := lootItem(&config.Rarity, instance.Item)
+	return item == nil || item.Tradable == nil || *item.Tradable
+}
+
+// holdEscrow creates the escrow object of a trade or an auction listing, which holds item instances and currency out
+// of the reach of their owner, in the transaction writing the inventory the instances were taken from and applying
+// the wallet updates. They are escrowed only if the inventory did not change since it was read and the wallet can
+// afford them.
+func holdEscrow(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, inventory *common.Inventory, inventoryVersion string, escrow *runtime.StorageWrite, walletUpdates []*runtime.WalletUpdate) error {
+	writeInventory, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return err
+	}
+	return updatePlayerData(ctx, logger, nk, []*runtime.StorageWrite{writeInventory, escrow}, nil, walletUpdates)
+}
+
+// releaseEscrow returns the escrowed instances to the inventory of their owner, even beyond its capacity, in the
+// transaction deleting the escrow object and applying the wallet updates. The deletion is guarded by the storage
+// version the escrow was read at, so an escrow is released only once.
+func releaseEscrow(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, instances []*common.ItemInstance, escrow *runtime.StorageDelete, walletUpdates []*runtime.WalletUpdate) error {
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return err
+	}
+	inventory.Items = append(inventory.Items, instances...)
+
+	writeInventory, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return err
+	}
+	return updatePlayerData(ctx, logger, nk, []*runtime.StorageWrite{writeInventory}, []*runtime.StorageDelete{escrow}, walletUpdates)
+}
+
+// expireEscrows closes the expired escrows of a page of the collection, read from storage, with the close function,
+// and returns the response of the expiry RPC. A trusted server calls the RPC periodically, following the cursor until
+// every page is swept. An escrow closed concurrently is left to the call that closed it, and an escrow failing to
+// close is logged, reported as failed and left to the next sweep so that it does not hold back the rest of the page.
+func expireEscrows[T any](ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, payload, collection, name string, expiresAt func(escrow *T) int64, closeExpired func(escrow *T, storageVersion string) error) (string, error) {
+	var req ExpireEscrowsRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+	if req.Limit <= 0 || req.Limit > maxExpireEscrowsLimit {
+		req.Limit = maxExpireEscrowsLimit
+	}
+
+	objects, cursor, err := nk.StorageList(ctx, common.EmptyString, common.EmptyString, collection, req.Limit, req.Cursor)
+	if err != nil {
+		logger.Error("StorageList error: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	resp := &ExpireEscrowsResponse{Expired: []string{}, Cursor: cursor}
+	now := time.Now().Unix()
+	for _, obj := range objects {
+		escrow := new(T)
+		if err := json.Unmarshal([]byte(obj.GetValue()), escrow); err != nil {
+			logger.Error("Cannot unmarshal %s %s: %+v", name, obj.GetKey(), err)
+			resp.Failed = append(resp.Failed, obj.GetKey())
+			continue
+		}
+		if now < expiresAt(escrow) {
+			continue
+		}
+		if err := closeExpired(escrow, obj.GetVersion()); err != nil {
+			if !errors.Is(err, common.ErrPlayerDataConflict) {
+				logger.Error("Cannot expire %s %s: %+v", name, obj.GetKey(), err)
+				resp.Failed = append(resp.Failed, obj.GetKey())
+			}
+			continue
+		}
+		resp.Expired = append(resp.Expired, obj.GetKey())
+	}
+
+	return marshalResponse(logger, resp)
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+	"time"
+)
+
+// escrowedIronSword returns the Iron Sword instance of user123 held in escrow by the trade and listing fixtures.
+func escrowedIronSword() *common.ItemInstance {
+	return &common.ItemInstance{ID: "iron", Item: "Iron Sword", Rarity: "uncommon", AcquiredAt: 10}
+}
+
+// storedEscrow returns the storage object of an escrow, e.g. a trade or an auction listing, under the key and version.
+func storedEscrow(t *testing.T, key, version string, escrow any) *api.StorageObject {
+	escrowJSON, err := json.Marshal(escrow)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &api.StorageObject{Key: key, Value: string(escrowJSON), Version: version}
+}
+
+func TestExpireEscrows_SkipsFailedEscrows(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Error", "Cannot unmarshal %s %s: %+v", "trade", "corrupt", mock.Anything).Once()
+	mockLogger.On("Error", "Cannot expire %s %s: %+v", "trade", "failing", common.ErrInternalError).Once()
+
+	ctx := context.Background()
+	expired := time.Now().Add(-time.Minute).Unix()
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageList", ctx, "", "", common.StorageTrades, 10, "page1").Return([]*api.StorageObject{
+		storedEscrow(t, "failing", "v1", &common.Trade{ID: "failing", ExpiresAt: expired}),
+		{Key: "corrupt", Value: "{", Version: "v2"},
+		storedEscrow(t, "pending", "v3", &common.Trade{ID: "pending", ExpiresAt: time.Now().Add(time.Hour).Unix()}),
+		storedEscrow(t, "closed", "v4", &common.Trade{ID: "closed", ExpiresAt: expired}),
+		storedEscrow(t, "expired", "v5", &common.Trade{ID: "expired", ExpiresAt: expired}),
+	}, "page2", nil)
+
+	// The first trade fails to close and the fourth one was closed concurrently
+	closeErrors := map[string]error{"failing": common.ErrInternalError, "closed": common.ErrPlayerDataConflict}
+	var closed []string
+
+	// Call the function
+	result, err := expireEscrows(ctx, mockLogger, nk, `{"limit":10,"cursor":"page1"}`, common.StorageTrades, "trade",
+		func(trade *common.Trade) int64 { return trade.ExpiresAt },
+		func(trade *common.Trade, storageVersion string) error {
+			closed = append(closed, trade.ID+"@"+storageVersion)
+			return closeErrors[trade.ID]
+		})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"expired":["expired"],"failed":["failing","corrupt"],"cursor":"page2"}`, result)
+	assert.Equal(t, []string{"failing@v1", "closed@v4", "expired@v5"}, closed)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
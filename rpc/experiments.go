@@ -0,0 +1,354 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"time"
+)
+
+const (
+	// Number of buckets the players are hashed into, giving allocations a precision of 0.01%
+	experimentBuckets = 10000
+)
+
+type (
+	ExperimentAssignmentSummary struct {
+		ExperimentID string `json:"experiment_id"`
+		Variant      string `json:"variant"`
+		Forced       bool   `json:"forced,omitempty"`
+		AssignedAt   int64  `json:"assigned_at"`
+	}
+
+	ReadExperimentAssignmentsResponse struct {
+		Assignments []*ExperimentAssignmentSummary `json:"assignments"`
+	}
+
+	AssignExperimentVariantRequest struct {
+		UserID       string `json:"user_id"`
+		ExperimentID string `json:"experiment_id"`
+		Variant      string `json:"variant"`
+	}
+
+	AssignExperimentVariantResponse struct {
+		Status common.Status `json:"status"`
+	}
+)
+
+// ReadExperimentAssignments reads the experiment variants the user is assigned to in the active game configuration.
+func ReadExperimentAssignments(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, _ string) (string, error) {
+	logger.Debug("ReadExperimentAssignments RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	assignments, err := ensureExperimentAssignments(ctx, logger, nk, userID, active.Config.Experiments)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	resp := &ReadExperimentAssignmentsResponse{
+		Assignments: make([]*ExperimentAssignmentSummary, 0, len(assignments)),
+	}
+	for _, experiment := range active.Config.Experiments {
+		assignment := assignments[experiment.ID]
+		resp.Assignments = append(resp.Assignments, &ExperimentAssignmentSummary{
+			ExperimentID: experiment.ID,
+			Variant:      assignment.Variant,
+			Forced:       assignment.Forced,
+			AssignedAt:   assignment.AssignedAt,
+		})
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// S2SAssignExperimentVariant force assigns a user, typically a QA account, to a variant of an experiment.
+func S2SAssignExperimentVariant(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("S2SAssignExperimentVariant RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req AssignExperimentVariantRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString {
+		logger.Error("Payload did not contain a user ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	experiment := findExperiment(active.Config.Experiments, req.ExperimentID)
+	if experiment == nil {
+		logger.Error("Experiment %s not found", req.ExperimentID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	if req.Variant != common.ExperimentControlVariant && findExperimentVariant(experiment, req.Variant) == nil {
+		logger.Error("Variant %s of experiment %s not found", req.Variant, req.ExperimentID)
+		return common.EmptyString, common.ErrNotFound
+	}
+
+	// Assignments are only written for existing users, so that no storage object is left without an owner
+	if err = checkUserExists(ctx, logger, nk, req.UserID); err != nil {
+		return common.EmptyString, err
+	}
+
+	assignments, storageVersion, err := readExperimentAssignments(ctx, logger, nk, req.UserID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	assignments.Assignments[req.ExperimentID] = &common.ExperimentAssignment{
+		Variant:    req.Variant,
+		Forced:     true,
+		AssignedAt: time.Now().Unix(),
+	}
+
+	if err = writeExperimentAssignments(ctx, logger, nk, req.UserID, assignments, storageVersion); err != nil {
+		if errors.Is(err, runtime.ErrStorageRejectedVersion) {
+			logger.Error("Experiment assignments of user %s were modified concurrently", req.UserID)
+			return common.EmptyString, common.ErrVersionConflict
+		}
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	logger.Info("Assigned user %s to variant %s of experiment %s", req.UserID, req.Variant, req.ExperimentID)
+
+	return marshalResponse(logger, &AssignExperimentVariantResponse{
+		Status: common.StatusSuccess,
+	})
+}
+
+// checkUserExists returns a not found error when there is no user with the ID.
+func checkUserExists(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) error {
+	users, err := nk.UsersGetId(ctx, []string{userID}, nil)
+	if err != nil {
+		logger.Error("UsersGetId error: %+v", err)
+		return common.ErrInternalError
+	}
+	if len(users) == 0 {
+		logger.Error("User %s not found", userID)
+		return common.ErrNotFound
+	}
+
+	return nil
+}
+
+// ensureExperimentAssignments returns the user's assignment for each of the experiments, bucketing the user into the
+// experiments it was not assigned to yet and persisting these new assignments.
+func ensureExperimentAssignments(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, experiments []common.Experiment) (map[string]*common.ExperimentAssignment, error) {
+	result := make(map[string]*common.ExperimentAssignment, len(experiments))
+	if len(experiments) == 0 {
+		return result, nil
+	}
+
+	assignments, storageVersion, err := readExperimentAssignments(ctx, logger, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := false
+	for i := range experiments {
+		experiment := &experiments[i]
+		assignment, ok := assignments.Assignments[experiment.ID]
+		if !ok {
+			assignment = &common.ExperimentAssignment{
+				Variant:    bucketExperimentVariant(experiment, userID),
+				AssignedAt: time.Now().Unix(),
+			}
+			assignments.Assignments[experiment.ID] = assignment
+			assigned = true
+		}
+		result[experiment.ID] = assignment
+	}
+
+	if assigned {
+		if err = writeExperimentAssignments(ctx, logger, nk, userID, assignments, storageVersion); err != nil {
+			if !errors.Is(err, runtime.ErrStorageRejectedVersion) {
+				return nil, common.ErrInternalError
+			}
+			// A concurrent request persisted assignments first, possibly forcing a variant, so what is stored wins over
+			// the bucketing. Experiments it did not assign keep their deterministic bucket and are persisted later.
+			logger.Warn("Experiment assignments of user %s were modified concurrently", userID)
+			stored, _, err := readExperimentAssignments(ctx, logger, nk, userID)
+			if err != nil {
+				return nil, err
+			}
+			for experimentID, assignment := range stored.Assignments {
+				if _, ok := result[experimentID]; ok {
+					result[experimentID] = assignment
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// bucketExperimentVariant deterministically assigns the user to a variant by hashing the experiment and user IDs.
+func bucketExperimentVariant(experiment *common.Experiment, userID string) string {
+	sum := sha256.Sum256([]byte(experiment.ID + "/" + userID))
+	bucket := float64(binary.BigEndian.Uint64(sum[:8]) % experimentBuckets)
+
+	threshold := 0.0
+	for _, variant := range experiment.Variants {
+		threshold += variant.Allocation * experimentBuckets / 100
+		if bucket < threshold {
+			return variant.Name
+		}
+	}
+
+	return common.ExperimentControlVariant
+}
+
+// applyExperimentVariants returns the configuration seen by a player in the given variants, keyed by experiment ID.
+// The experiment definitions are not part of the returned configuration.
+func applyExperimentVariants(config *common.GameConfig, variants map[string]string) (*common.GameConfig, error) {
+	result := *config
+	result.Experiments = nil
+
+	for i := range config.Experiments {
+		variant := findExperimentVariant(&config.Experiments[i], variants[config.Experiments[i].ID])
+		if variant == nil {
+			continue
+		}
+
+		patched, err := applyGameConfigPatch(&result, variant.Overrides)
+		if err != nil {
+			return nil, err
+		}
+		result = *patched
+	}
+
+	return &result, nil
+}
+
+// experimentVariantConfigs returns the configuration served to the players outside of every experiment followed by
+// the configuration served to each experiment variant.
+func experimentVariantConfigs(config *common.GameConfig) ([]*common.GameConfig, error) {
+	control, err := applyExperimentVariants(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []*common.GameConfig{control}
+	for _, experiment := range config.Experiments {
+		for _, variant := range experiment.Variants {
+			patched, err := applyExperimentVariants(config, map[string]string{experiment.ID: variant.Name})
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, patched)
+		}
+	}
+
+	return configs, nil
+}
+
+// readExperimentAssignments reads the persisted assignments of the user along with their storage version.
+func readExperimentAssignments(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) (*common.ExperimentAssignments, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageExperiments,
+		Key:        common.StorageExperimentAssignmentsKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+
+	assignments := &common.ExperimentAssignments{}
+	storageVersion := common.EmptyString
+	if len(objects) > 0 {
+		if err = json.Unmarshal([]byte(objects[0].GetValue()), assignments); err != nil {
+			logger.Error("Cannot unmarshal experiment assignments: %+v", err)
+			return nil, common.EmptyString, common.ErrUnMarshallingError
+		}
+		storageVersion = objects[0].GetVersion()
+	}
+	if assignments.Assignments == nil {
+		assignments.Assignments = make(map[string]*common.ExperimentAssignment)
+	}
+
+	return assignments, storageVersion, nil
+}
+
+// writeExperimentAssignments writes the assignments of the user, guarded by the storage version they were read at.
+func writeExperimentAssignments(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, assignments *common.ExperimentAssignments, storageVersion string) error {
+	assignmentsJSON, err := json.Marshal(assignments)
+	if err != nil {
+		logger.Error("Cannot marshal experiment assignments: %+v", err)
+		return err
+	}
+
+	if storageVersion == common.EmptyString {
+		storageVersion = "*"
+	}
+
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      common.StorageExperiments,
+		Key:             common.StorageExperimentAssignmentsKey,
+		UserID:          userID,
+		Value:           string(assignmentsJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_OWNER_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}})
+	if err != nil && !errors.Is(err, runtime.ErrStorageRejectedVersion) {
+		logger.Error("StorageWrite error: %+v", err)
+	}
+
+	return err
+}
+
+// experimentVariants flattens the assignments to the variant name of each experiment.
+func experimentVariants(assignments map[string]*common.ExperimentAssignment) map[string]string {
+	variants := make(map[string]string, len(assignments))
+	for experimentID, assignment := range assignments {
+		variants[experimentID] = assignment.Variant
+	}
+	return variants
+}
+
+// findExperiment returns the experiment with the given ID, nil if there is none.
+func findExperiment(experiments []common.Experiment, id string) *common.Experiment {
+	for i := range experiments {
+		if experiments[i].ID == id {
+			return &experiments[i]
+		}
+	}
+	return nil
+}
+
+// findExperimentVariant returns the variant of the experiment with the given name, nil for the control group.
+func findExperimentVariant(experiment *common.Experiment, name string) *common.ExperimentVariant {
+	for i := range experiment.Variants {
+		if experiment.Variants[i].Name == name {
+			return &experiment.Variants[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+var xpExperiment = common.Experiment{
+	ID: "xp_boost",
+	Variants: []common.ExperimentVariant{
+		{Name: "double", Allocation: 25, Overrides: map[string]any{"xp_rate": 3.0}},
+		{Name: "welcome", Allocation: 25, Overrides: map[string]any{"welcome_message": "Hello"}},
+	},
+}
+
+func experimentAssignmentsRead(userID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageExperiments,
+		Key:        common.StorageExperimentAssignmentsKey,
+		UserID:     userID,
+	}}
+}
+
+func TestBucketExperimentVariant_DeterministicAndProportional(t *testing.T) {
+	// Setup
+	counts := make(map[string]int)
+
+	// Call the function
+	for i := 0; i < 20000; i++ {
+		counts[bucketExperimentVariant(&xpExperiment, fmt.Sprintf("user-%d", i))]++
+	}
+
+	// Assertions
+	assert.Equal(t, bucketExperimentVariant(&xpExperiment, "user-1"), bucketExperimentVariant(&xpExperiment, "user-1"))
+	assert.InDelta(t, 5000, counts["double"], 300)
+	assert.InDelta(t, 5000, counts["welcome"], 300)
+	assert.InDelta(t, 10000, counts[common.ExperimentControlVariant], 300)
+}
+
+func TestApplyExperimentVariants_AppliesAssignedVariant(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Experiments = []common.Experiment{xpExperiment}
+
+	// Call the function
+	variant, err := applyExperimentVariants(config, map[string]string{"xp_boost": "double"})
+	control, controlErr := applyExperimentVariants(config, map[string]string{"xp_boost": common.ExperimentControlVariant})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.NoError(t, controlErr)
+	assert.Equal(t, 3.0, variant.XpRate)
+	assert.Equal(t, 1.5, control.XpRate)
+	assert.Nil(t, variant.Experiments)
+	assert.Nil(t, control.Experiments)
+	assert.Equal(t, config.Rarity, variant.Rarity)
+}
+
+func TestValidateGameConfig_InvalidExperimentVariants(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Experiments = []common.Experiment{{
+		ID: "legendary_week",
+		Variants: []common.ExperimentVariant{
+			{Name: "boost", Allocation: 80, Overrides: map[string]any{
+				"rarity": map[string]any{"legendary": map[string]any{"chance": 0.2}},
+			}},
+			{Name: "control", Allocation: 30},
+		},
+	}}
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "experiments[0].variants[0].overrides.rarity", Message: "chances must sum to 1.0, got 1.15"},
+		{Path: "experiments[0].variants[1].name", Message: `"control" is reserved for the players outside of every variant`},
+		{Path: "experiments[0].variants", Message: "allocations must not exceed 100%, got 110"},
+	}, violations)
+}
+
+func TestReadExperimentAssignments_BucketsNewExperiments(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadExperimentAssignments RPC called").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 1, Config: common.GameConfig{
+		Experiments: []common.Experiment{xpExperiment},
+	}})
+
+	var written common.ExperimentAssignments
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, experimentAssignmentsRead("user123")).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].Version == "*" &&
+			json.Unmarshal([]byte(writes[0].Value), &written) == nil
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := ReadExperimentAssignments(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.NoError(t, err)
+	variant := bucketExperimentVariant(&xpExperiment, "user123")
+	assert.Contains(t, result, fmt.Sprintf(`{"experiment_id":"xp_boost","variant":"%s"`, variant))
+	assert.Equal(t, variant, written.Assignments["xp_boost"].Variant)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestEnsureExperimentAssignments_ConcurrentForcedVariantWins(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Warn", "Experiment assignments of user %s were modified concurrently", "user123").Once()
+
+	ctx := context.Background()
+	forced := `{"assignments":{"xp_boost":{"variant":"welcome","forced":true,"assigned_at":1}}}`
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, experimentAssignmentsRead("user123")).Return([]*api.StorageObject{}, nil).Once()
+	nk.On("StorageWrite", ctx, mock.Anything).Return(nil, runtime.ErrStorageRejectedVersion).Once()
+	// The variant was forced between the read and the write
+	nk.On("StorageRead", ctx, experimentAssignmentsRead("user123")).Return([]*api.StorageObject{
+		{Value: forced, Version: "v2"},
+	}, nil).Once()
+
+	// Call the function
+	assignments, err := ensureExperimentAssignments(ctx, mockLogger, nk, "user123", []common.Experiment{xpExperiment})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "welcome", assignments["xp_boost"].Variant)
+	assert.True(t, assignments["xp_boost"].Forced)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestS2SAssignExperimentVariant_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SAssignExperimentVariant RPC called").Once()
+	mockLogger.On("Info", "Assigned user %s to variant %s of experiment %s", "qa1", "double", "xp_boost").Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 1, Config: common.GameConfig{
+		Experiments: []common.Experiment{xpExperiment},
+	}})
+
+	var written common.ExperimentAssignments
+	nk := new(mocks.NakamaModule)
+	nk.On("UsersGetId", ctx, []string{"qa1"}, []string(nil)).Return([]*api.User{{Id: "qa1"}}, nil)
+	nk.On("StorageRead", ctx, experimentAssignmentsRead("qa1")).Return([]*api.StorageObject{
+		{Value: `{"assignments":{"xp_boost":{"variant":"control","assigned_at":1}}}`, Version: "v1"},
+	}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].UserID == "qa1" && writes[0].Version == "v1" &&
+			json.Unmarshal([]byte(writes[0].Value), &written) == nil
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := S2SAssignExperimentVariant(ctx, mockLogger, nil, nk, `{"user_id":"qa1","experiment_id":"xp_boost","variant":"double"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":"success"}`, result)
+	assert.Equal(t, &common.ExperimentAssignment{Variant: "double", Forced: true, AssignedAt: written.Assignments["xp_boost"].AssignedAt}, written.Assignments["xp_boost"])
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestS2SAssignExperimentVariant_UnknownUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SAssignExperimentVariant RPC called").Once()
+	mockLogger.On("Error", "User %s not found", "ghost").Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 1, Config: common.GameConfig{
+		Experiments: []common.Experiment{xpExperiment},
+	}})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("UsersGetId", ctx, []string{"ghost"}, []string(nil)).Return([]*api.User{}, nil)
+
+	// Call the function
+	result, err := S2SAssignExperimentVariant(ctx, mockLogger, nil, nk, `{"user_id":"ghost","experiment_id":"xp_boost","variant":"double"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	nk.AssertNotCalled(t, "StorageWrite", mock.Anything, mock.Anything)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestS2SAssignExperimentVariant_UnknownVariant(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SAssignExperimentVariant RPC called").Once()
+	mockLogger.On("Error", "Variant %s of experiment %s not found", "triple", "xp_boost").Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 1, Config: common.GameConfig{
+		Experiments: []common.Experiment{xpExperiment},
+	}})
+
+	// Call the function
+	result, err := S2SAssignExperimentVariant(ctx, mockLogger, nil, nil, `{"user_id":"qa1","experiment_id":"xp_boost","variant":"triple"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+}
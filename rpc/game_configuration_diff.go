@@ -0,0 +1,290 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"reflect"
+	"sort"
+)
+
+type (
+	// GameConfigSource designates a game configuration by exactly one of its fields.
+	GameConfigSource struct {
+		Version  int    `json:"version,omitempty"`
+		Active   bool   `json:"active,omitempty"`
+		Embedded bool   `json:"embedded,omitempty"`
+		UserID   string `json:"user_id,omitempty"`
+	}
+
+	DiffGameConfigRequest struct {
+		From *GameConfigSource `json:"from"`
+		// Defaults to the active version
+		To *GameConfigSource `json:"to,omitempty"`
+	}
+
+	DiffGameConfigResponse struct {
+		FromVersion int                    `json:"from_version"`
+		ToVersion   int                    `json:"to_version"`
+		Changes     []*common.ConfigChange `json:"changes"`
+	}
+)
+
+// DiffGameConfig reports the field-level differences between two game configurations, each being a published version,
+// the active version, the embedded configuration or the copy stored for a user.
+func DiffGameConfig(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("DiffGameConfig RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req DiffGameConfigRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.To == nil {
+		req.To = &GameConfigSource{Active: true}
+	}
+
+	from, fromVersion, err := readGameConfigSource(ctx, logger, nk, req.From)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	to, toVersion, err := readGameConfigSource(ctx, logger, nk, req.To)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	changes, err := diffGameConfigs(from, to)
+	if err != nil {
+		logger.Error("Cannot diff game configurations: %+v", err)
+		return common.EmptyString, common.ErrMarshallingError
+	}
+
+	return marshalResponse(logger, &DiffGameConfigResponse{
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Changes:     changes,
+	})
+}
+
+// readGameConfigSource reads the designated game configuration along with the version it is, or was derived from.
+func readGameConfigSource(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, source *GameConfigSource) (*common.GameConfig, int, error) {
+	selected := 0
+	if source != nil {
+		for _, set := range []bool{source.Version != 0, source.Active, source.Embedded, source.UserID != common.EmptyString} {
+			if set {
+				selected++
+			}
+		}
+	}
+	if selected != 1 {
+		logger.Error("Game configuration source must designate exactly one configuration")
+		return nil, 0, common.ErrInvalidArgument
+	}
+
+	switch {
+	case source.Active:
+		active, err := LoadActiveGameConfig(ctx, logger, nk)
+		if err != nil {
+			return nil, 0, err
+		}
+		return &active.Config, active.Version, nil
+
+	case source.Embedded:
+		config, err := loadEmbeddedGameConfig(logger)
+		if err != nil {
+			return nil, 0, err
+		}
+		return config, 0, nil
+
+	case source.UserID != common.EmptyString:
+		objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+			Collection: common.StorageConfiguration,
+			Key:        common.StorageGameConfigKey,
+			UserID:     source.UserID,
+		}})
+		if err != nil {
+			logger.Error("StorageRead error: %+v", err)
+			return nil, 0, common.ErrInternalError
+		}
+		if len(objects) == 0 {
+			logger.Error("Game configuration of user %s not found", source.UserID)
+			return nil, 0, common.ErrNotFound
+		}
+
+		snapshot, _ := decodeGameConfigSnapshot(logger, objects[0].GetValue())
+		if snapshot == nil {
+			return nil, 0, common.ErrUnMarshallingError
+		}
+		return &snapshot.Config, snapshot.ConfigVersion, nil
+
+	default:
+		version, err := readGameConfigVersion(ctx, logger, nk, source.Version)
+		if err != nil {
+			return nil, 0, err
+		}
+		if version == nil {
+			logger.Error("Game configuration version %d not found", source.Version)
+			return nil, 0, common.ErrNotFound
+		}
+		return &version.Config, version.Version, nil
+	}
+}
+
+// diffGameConfigs returns the changes turning one configuration into the other, ordered by path.
+func diffGameConfigs(from, to *common.GameConfig) ([]*common.ConfigChange, error) {
+	fromDocument, err := gameConfigDocument(from)
+	if err != nil {
+		return nil, err
+	}
+	toDocument, err := gameConfigDocument(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*common.ConfigChange{}
+	diffConfigDocuments(common.EmptyString, fromDocument, toDocument, &changes)
+
+	return changes, nil
+}
+
+// gameConfigDocument converts the configuration, or a response embedding it, to its generic JSON document.
+func gameConfigDocument(config any) (any, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	if err = json.Unmarshal(configJSON, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// diffConfigDocuments appends the changes between two JSON values at the path. Objects are compared field by field,
+// arrays of objects identified by a name or an ID element by element, and any other values as a whole.
+func diffConfigDocuments(path string, from, to any, changes *[]*common.ConfigChange) {
+	fromObject, fromIsObject := from.(map[string]any)
+	toObject, toIsObject := to.(map[string]any)
+	if fromIsObject && toIsObject {
+		keys := make([]string, 0, len(fromObject)+len(toObject))
+		for key := range fromObject {
+			keys = append(keys, key)
+		}
+		for key := range toObject {
+			if _, ok := fromObject[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fieldPath := key
+			if path != common.EmptyString {
+				fieldPath = path + "." + key
+			}
+			diffConfigFields(fieldPath, fromObject, toObject, key, changes)
+		}
+		return
+	}
+
+	fromArray, fromIsArray := from.([]any)
+	toArray, toIsArray := to.([]any)
+	if fromIsArray && toIsArray {
+		if keyField := configArrayKey(fromArray, toArray); keyField != common.EmptyString {
+			diffKeyedConfigArrays(path, keyField, fromArray, toArray, changes)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, &common.ConfigChange{Path: path, Op: common.ConfigChangeChanged, From: from, To: to})
+	}
+}
+
+// diffConfigFields appends the changes of the field of two objects, either of which may lack it.
+func diffConfigFields(path string, from, to map[string]any, key string, changes *[]*common.ConfigChange) {
+	fromValue, inFrom := from[key]
+	toValue, inTo := to[key]
+
+	switch {
+	case !inFrom:
+		*changes = append(*changes, &common.ConfigChange{Path: path, Op: common.ConfigChangeAdded, To: toValue})
+	case !inTo:
+		*changes = append(*changes, &common.ConfigChange{Path: path, Op: common.ConfigChangeRemoved, From: fromValue})
+	default:
+		diffConfigDocuments(path, fromValue, toValue, changes)
+	}
+}
+
+// diffKeyedConfigArrays matches the elements of two arrays by their key field, in the order of the first array
+// followed by the elements only found in the second one.
+func diffKeyedConfigArrays(path, keyField string, from, to []any, changes *[]*common.ConfigChange) {
+	elementPath := func(key string) string {
+		return fmt.Sprintf("%s[%s=%s]", path, keyField, key)
+	}
+
+	fromElements := configArrayElements(from, keyField)
+	toElements := configArrayElements(to, keyField)
+
+	for _, element := range from {
+		key := element.(map[string]any)[keyField].(string)
+		if _, ok := toElements[key]; !ok {
+			*changes = append(*changes, &common.ConfigChange{Path: elementPath(key), Op: common.ConfigChangeRemoved, From: element})
+			continue
+		}
+		diffConfigDocuments(elementPath(key), element, toElements[key], changes)
+	}
+
+	for _, element := range to {
+		key := element.(map[string]any)[keyField].(string)
+		if _, ok := fromElements[key]; !ok {
+			*changes = append(*changes, &common.ConfigChange{Path: elementPath(key), Op: common.ConfigChangeAdded, To: element})
+		}
+	}
+}
+
+// configArrayKey returns the field identifying the elements of both arrays, "name" or "id", or an empty string when
+// the elements are not objects with a unique value of either field.
+func configArrayKey(arrays ...[]any) string {
+	for _, keyField := range []string{"name", "id"} {
+		identified := true
+		for _, array := range arrays {
+			if len(configArrayElements(array, keyField)) != len(array) {
+				identified = false
+				break
+			}
+		}
+		if identified {
+			return keyField
+		}
+	}
+
+	return common.EmptyString
+}
+
+// configArrayElements indexes the object elements of the array by the non-empty string value of their key field.
+func configArrayElements(array []any, keyField string) map[string]any {
+	elements := make(map[string]any, len(array))
+	for _, element := range array {
+		object, ok := element.(map[string]any)
+		if !ok {
+			continue
+		}
+		if key, ok := object[keyField].(string); ok && key != common.EmptyString {
+			elements[key] = element
+		}
+	}
+
+	return elements
+}
@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func TestDiffGameConfigs_KeysItemsByName(t *testing.T) {
+	// Setup
+	from := &common.GameConfig{
+		WelcomeMessage: "Welcome",
+		XpRate:         1.5,
+		Rarity: common.Rarity{Common: common.RarityItems{Chance: 1, Items: []common.Item{
+			{Name: "Wooden Sword", Damage: 10, Durability: 100},
+			{Name: "Leather Armor", Defense: 5, Durability: 100},
+		}}},
+	}
+	to := &common.GameConfig{
+		WelcomeMessage: "Welcome",
+		XpRate:         2,
+		Rarity: common.Rarity{Common: common.RarityItems{Chance: 1, Items: []common.Item{
+			{Name: "Stone Axe", Damage: 12, Durability: 80},
+			{Name: "Wooden Sword", Damage: 15, Durability: 100},
+		}}},
+	}
+
+	// Call the function
+	changes, err := diffGameConfigs(from, to)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []*common.ConfigChange{
+		{Path: "rarity.common.items[name=Wooden Sword].damage", Op: common.ConfigChangeChanged, From: 10.0, To: 15.0},
+		{Path: "rarity.common.items[name=Leather Armor]", Op: common.ConfigChangeRemoved, From: map[string]any{
+			"name": "Leather Armor", "defense": 5.0, "durability": 100.0,
+		}},
+		{Path: "rarity.common.items[name=Stone Axe]", Op: common.ConfigChangeAdded, To: map[string]any{
+			"name": "Stone Axe", "damage": 12.0, "durability": 80.0,
+		}},
+		{Path: "xp_rate", Op: common.ConfigChangeChanged, From: 1.5, To: 2.0},
+	}, changes)
+}
+
+func TestDiffGameConfigs_IdenticalConfigs(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+
+	// Call the function
+	changes, err := diffGameConfigs(config, embeddedGameConfig(t))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffGameConfig_UserCopyAgainstVersion(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiffGameConfig RPC called").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000002",
+	}}).Return([]*api.StorageObject{
+		{Value: `{"version":2,"schema_version":1,"config":{"welcome_message":"Welcome","xp_rate":2}}`},
+	}, nil)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{
+		{Value: `{"welcome_message":"Welcome","xp_rate":1.5,"_snapshot":{"schema_version":1,"config_version":1}}`},
+	}, nil)
+
+	// Call the function
+	result, err := DiffGameConfig(ctx, mockLogger, nil, nk, `{"from":{"user_id":"user123"},"to":{"version":2}}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"from_version":1,"to_version":2,"changes":[{"path":"xp_rate","op":"changed","from":1.5,"to":2}]}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDiffGameConfig_AmbiguousSource(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiffGameConfig RPC called").Once()
+	mockLogger.On("Error", "Game configuration source must designate exactly one configuration").Once()
+
+	// Call the function
+	result, err := DiffGameConfig(context.Background(), mockLogger, nil, nil, `{"from":{"version":1,"embedded":true}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestDiffGameConfig_UserCopyNotFound(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiffGameConfig RPC called").Once()
+	mockLogger.On("Error", "Game configuration of user %s not found", "user123").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := DiffGameConfig(ctx, mockLogger, nil, nk, `{"from":{"user_id":"user123"}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDiffGameConfig_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiffGameConfig RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := DiffGameConfig(ctx, mockLogger, nil, nil, `{}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"context"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+)
+
+// gameConfigDerivation is what a configuration served to a user was derived with on top of the active version.
+type gameConfigDerivation struct {
+	// Variant of each experiment the user is assigned to
+	Variants map[string]string
+	// Live-ops overrides in effect, nil if none is active or upcoming
+	LiveOps *common.LiveOpsStatus
+}
+
+// liveOpsOverrides returns the IDs of the live-ops overrides the configuration was derived with, in the order they
+// were applied.
+func (derivation *gameConfigDerivation) liveOpsOverrides() []string {
+	if derivation == nil || derivation.LiveOps == nil || len(derivation.LiveOps.ActiveOverrides) == 0 {
+		return nil
+	}
+	return derivation.LiveOps.ActiveOverrides
+}
+
+// deriveUserGameConfig derives the configuration served to the user from the active version: the experiment variants
+// the user is assigned to are applied first, then the live-ops overrides active at the given Unix time.
+// It also returns what the configuration was derived with.
+func deriveUserGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, active *common.GameConfigVersion, now int64) (*common.GameConfig, *gameConfigDerivation, error) {
+	derivation, overrides, err := resolveGameConfigDerivation(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := applyGameConfigDerivation(logger, active, derivation, overrides, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, derivation, nil
+}
+
+// resolveGameConfigDerivation resolves what the configuration served to the user is derived with from the active
+// version at the given Unix time, without deriving it. It also returns the scheduled live-ops overrides to derive it
+// with.
+func resolveGameConfigDerivation(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, active *common.GameConfigVersion, now int64) (*gameConfigDerivation, []*common.LiveOpsOverride, error) {
+	assignments, err := ensureExperimentAssignments(ctx, logger, nk, userID, active.Config.Experiments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overrides, _, err := readLiveOpsOverrides(ctx, logger, nk)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &gameConfigDerivation{
+		Variants: experimentVariants(assignments),
+		LiveOps:  liveOpsStatus(overrides.Overrides, now),
+	}, overrides.Overrides, nil
+}
+
+// applyGameConfigDerivation derives the configuration from the active version with the resolved experiment variants,
+// then the live-ops overrides active at the given Unix time.
+func applyGameConfigDerivation(logger runtime.Logger, active *common.GameConfigVersion, derivation *gameConfigDerivation, overrides []*common.LiveOpsOverride, now int64) (*common.GameConfig, error) {
+	config, err := applyExperimentVariants(&active.Config, derivation.Variants)
+	if err != nil {
+		logger.Error("Cannot apply experiment variants to game configuration version %d: %+v", active.Version, err)
+		return nil, common.ErrInternalError
+	}
+
+	config, err = applyLiveOpsOverrides(config, overrides, now)
+	if err != nil {
+		logger.Error("Cannot apply live-ops overrides to game configuration version %d: %+v", active.Version, err)
+		return nil, common.ErrInternalError
+	}
+
+	return config, nil
+}
@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"oak/common"
+	"reflect"
+	"strings"
+)
+
+// gameConfigFieldKnown reports whether the dot separated path of JSON field names, e.g. "rarity.legendary",
+// designates a field of the game configuration response. Paths stop at fields that are not objects, such as item lists.
+func gameConfigFieldKnown(path string) bool {
+	fieldType := reflect.TypeOf(GameConfigResponse{})
+	for _, name := range strings.Split(path, ".") {
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			return false
+		}
+
+		field, ok := jsonStructField(fieldType, name)
+		if !ok {
+			return false
+		}
+		fieldType = field.Type
+	}
+
+	return true
+}
+
+// jsonStructField returns the field of the struct type encoded under the JSON name, including the fields of the
+// embedded structs whose fields are encoded inline.
+func jsonStructField(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && tagName == common.EmptyString {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Pointer {
+				embeddedType = embeddedType.Elem()
+			}
+			if embedded, ok := jsonStructField(embeddedType, name); ok {
+				return embedded, true
+			}
+			continue
+		}
+		if tagName == name && name != "-" {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// selectGameConfigFields returns the JSON document of the configuration response reduced to the subtrees at the paths.
+// Fields omitted from the response are omitted from the document as well.
+func selectGameConfigFields(response *GameConfigResponse, paths []string) (map[string]any, error) {
+	document, err := gameConfigDocument(response)
+	if err != nil {
+		return nil, err
+	}
+	source, _ := document.(map[string]any)
+
+	selected := make(map[string]any)
+	for _, path := range paths {
+		names := strings.Split(path, ".")
+
+		value, ok := any(source), true
+		for _, name := range names {
+			object, isObject := value.(map[string]any)
+			if !isObject {
+				ok = false
+				break
+			}
+			value, ok = object[name]
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		target := selected
+		for _, name := range names[:len(names)-1] {
+			next, isObject := target[name].(map[string]any)
+			if !isObject {
+				next = make(map[string]any)
+				target[name] = next
+			}
+			target = next
+		}
+		target[names[len(names)-1]] = value
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"context"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func TestGameConfigFieldKnown(t *testing.T) {
+	tests := []struct {
+		path  string
+		known bool
+	}{
+		{path: "xp_rate", known: true},
+		{path: "rarity", known: true},
+		{path: "rarity.legendary", known: true},
+		{path: "rarity.legendary.items", known: true},
+		{path: "live_ops.active_overrides", known: true},
+		{path: "rarity.mythic", known: false},
+		{path: "rarity.legendary.items.name", known: false},
+		{path: "xp_rate.value", known: false},
+		{path: "XpRate", known: false},
+		{path: "", known: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Call the function
+			known := gameConfigFieldKnown(tt.path)
+
+			// Assertions
+			assert.Equal(t, tt.known, known)
+		})
+	}
+}
+
+func TestSelectGameConfigFields(t *testing.T) {
+	// Setup
+	config := &common.GameConfig{
+		WelcomeMessage: "Welcome",
+		XpRate:         2,
+		Rarity: common.Rarity{
+			Common:    common.RarityItems{Chance: 0.9},
+			Legendary: common.RarityItems{Chance: 0.1, Items: []common.Item{{Name: "Excalibur", Damage: 100, Durability: 500}}},
+		},
+	}
+
+	// Call the function
+	fields, err := selectGameConfigFields(&GameConfigResponse{GameConfig: config}, []string{"rarity.legendary", "xp_rate", "rarity.common.chance", "live_ops"})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"xp_rate": 2.0,
+		"rarity": map[string]any{
+			"common": map[string]any{"chance": 0.9},
+			"legendary": map[string]any{
+				"chance": 0.1,
+				"items":  []any{map[string]any{"name": "Excalibur", "damage": 100.0, "durability": 500.0}},
+			},
+		},
+	}, fields)
+}
+
+func TestReadGameConfigurationFromFile_Fields(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReturnGameConfigurationFromFile RPC called").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{
+		Version: 3,
+		Config: common.GameConfig{
+			WelcomeMessage: "Welcome",
+			XpRate:         2,
+			Rarity:         common.Rarity{Common: common.RarityItems{Chance: 1}},
+		},
+	})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := ReadGameConfigurationFromFile(ctx, mockLogger, nil, nk, `{"fields":["xp_rate"]}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "content_hash": "1e7cf2bc86b6e488f1b2bd533f428fdca3a27cdea8e1c96825ca7b8754c38186",
+  "xp_rate": 2
+}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReadGameConfigurationFromStorage_UnknownField(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadGameConfigurationFromStorage RPC called").Once()
+	mockLogger.On("Error", "Unknown game configuration field %s", "rarity.mythic").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ReadGameConfigurationFromStorage(ctx, mockLogger, nil, nil, `{"fields":["rarity.legendary","rarity.mythic"]}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.EqualError(t, err, "unknown game configuration field: rarity.mythic")
+	assert.Equal(t, common.RpcCodeInvalidArgument, err.(*runtime.Error).Code)
+	mockLogger.AssertExpectations(t)
+}
@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"oak/common"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// Root file of the embedded game configuration
+	gameConfigRootFile = "config/game_config.yaml"
+
+	// Key of the directive replacing a mapping with the documents of the listed files
+	gameConfigIncludeDirective = "$include"
+)
+
+// Embed the configuration directory, the root file includes the others
+//
+//go:embed config
+var gameConfigFS embed.FS
+
+// decodeGameConfigFile decodes the game configuration from a JSON or YAML file, resolving its includes. The files are
+// decoded to the same generic document whatever their format, so they produce the same configuration.
+func decodeGameConfigFile(fsys fs.FS, name string) (*common.GameConfig, error) {
+	document, err := loadConfigDocument(fsys, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	documentJSON, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var config common.GameConfig
+	if err = json.Unmarshal(documentJSON, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return &config, nil
+}
+
+// LoadGameConfigFile decodes the game configuration from a JSON or YAML file on the disk, resolving its includes
+// relative to the directory of the file.
+func LoadGameConfigFile(name string) (*common.GameConfig, error) {
+	return decodeGameConfigFile(os.DirFS(filepath.Dir(name)), filepath.Base(name))
+}
+
+// loadConfigDocument decodes a configuration file according to its extension and resolves its includes.
+// The chain of files being included is used to detect include cycles.
+func loadConfigDocument(fsys fs.FS, name string, including []string) (any, error) {
+	if slices.Contains(including, name) {
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(including, " -> "), name)
+	}
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	switch path.Ext(name) {
+	case ".json":
+		err = json.Unmarshal(data, &document)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration format", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return resolveConfigIncludes(fsys, name, document, append(slices.Clone(including), name))
+}
+
+// resolveConfigIncludes replaces every mapping holding the include directive with the included documents merged in
+// order, then the other keys of the mapping merged over them as a JSON merge patch.
+func resolveConfigIncludes(fsys fs.FS, name string, node any, including []string) (any, error) {
+	switch value := node.(type) {
+	case map[string]any:
+		return resolveConfigMappingIncludes(fsys, name, value, including)
+	case map[any]any:
+		// YAML mappings with keys other than strings, which JSON cannot represent
+		mapping := make(map[string]any, len(value))
+		for key, item := range value {
+			stringKey, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: mapping key %v is not a string", name, key)
+			}
+			mapping[stringKey] = item
+		}
+		return resolveConfigMappingIncludes(fsys, name, mapping, including)
+	case []any:
+		for i, item := range value {
+			resolved, err := resolveConfigIncludes(fsys, name, item, including)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = resolved
+		}
+		return value, nil
+	default:
+		return node, nil
+	}
+}
+
+// resolveConfigMappingIncludes resolves the includes of a mapping and of its values.
+func resolveConfigMappingIncludes(fsys fs.FS, name string, mapping map[string]any, including []string) (any, error) {
+	for key, item := range mapping {
+		if key == gameConfigIncludeDirective {
+			continue
+		}
+		resolved, err := resolveConfigIncludes(fsys, name, item, including)
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = resolved
+	}
+
+	directive, ok := mapping[gameConfigIncludeDirective]
+	if !ok {
+		return mapping, nil
+	}
+	delete(mapping, gameConfigIncludeDirective)
+
+	var includes []string
+	switch value := directive.(type) {
+	case string:
+		includes = []string{value}
+	case []any:
+		for _, item := range value {
+			include, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s must list file paths", name, gameConfigIncludeDirective)
+			}
+			includes = append(includes, include)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s must be a file path or a list of file paths", name, gameConfigIncludeDirective)
+	}
+	if len(includes) == 0 {
+		return nil, fmt.Errorf("%s: %s must not be empty", name, gameConfigIncludeDirective)
+	}
+
+	var result any
+	for _, include := range includes {
+		document, err := loadConfigDocument(fsys, path.Join(path.Dir(name), include), including)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = document
+			continue
+		}
+
+		base, baseOk := result.(map[string]any)
+		patch, patchOk := document.(map[string]any)
+		if !baseOk || !patchOk {
+			return nil, fmt.Errorf("%s: only mappings can be merged, cannot include %s", name, include)
+		}
+		result = mergePatch(base, patch)
+	}
+
+	if len(mapping) == 0 {
+		return result, nil
+	}
+
+	base, ok := result.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: keys next to %s can only be merged over a mapping", name, gameConfigIncludeDirective)
+	}
+
+	return mergePatch(base, mapping), nil
+}
@@ -0,0 +1,112 @@
+package rpc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDecodeGameConfigFile_SameConfigFromJSONAndYAML(t *testing.T) {
+	// Setup
+	fsys := fstest.MapFS{
+		"game_config.json": {Data: []byte(`{
+  "welcome_message": "Welcome",
+  "xp_rate": 1.5,
+  "rarity": {
+    "common": { "chance": 1, "items": [{ "name": "Wooden Sword", "damage": 10, "durability": 100 }] }
+  }
+}`)},
+		"game_config.yaml": {Data: []byte(`
+welcome_message: Welcome
+xp_rate: 1.5
+rarity:
+  common:
+    chance: 1
+    items:
+      - { name: Wooden Sword, damage: 10, durability: 100 }
+`)},
+	}
+
+	// Call the function
+	fromJSON, jsonErr := decodeGameConfigFile(fsys, "game_config.json")
+	fromYAML, yamlErr := decodeGameConfigFile(fsys, "game_config.yaml")
+
+	// Assertions
+	assert.NoError(t, jsonErr)
+	assert.NoError(t, yamlErr)
+	assert.Equal(t, fromJSON, fromYAML)
+	assert.Equal(t, []common.Item{{Name: "Wooden Sword", Damage: 10, Durability: 100}}, fromYAML.Rarity.Common.Items)
+}
+
+func TestDecodeGameConfigFile_IncludesAndMerges(t *testing.T) {
+	// Setup
+	fsys := fstest.MapFS{
+		"config/game_config.yaml": {Data: []byte(`
+welcome_message: Welcome
+xp_rate: 1.5
+rarity:
+  common:
+    $include: rarity/common.json
+  rare:
+    $include: [rarity/rare.yaml, rarity/rare_event.yaml]
+    chance: 0.2
+`)},
+		"config/rarity/common.json":     {Data: []byte(`{"chance": 0.8, "items": [{"name": "Wooden Sword", "durability": 100}]}`)},
+		"config/rarity/rare.yaml":       {Data: []byte("chance: 0.1\nitems:\n  - { name: Steel Sword, durability: 250 }\n")},
+		"config/rarity/rare_event.yaml": {Data: []byte("items:\n  - { name: Event Sword, durability: 50 }\n")},
+	}
+
+	// Call the function
+	config, err := decodeGameConfigFile(fsys, "config/game_config.yaml")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, common.RarityItems{Chance: 0.8, Items: []common.Item{{Name: "Wooden Sword", Durability: 100}}}, config.Rarity.Common)
+	assert.Equal(t, common.RarityItems{Chance: 0.2, Items: []common.Item{{Name: "Event Sword", Durability: 50}}}, config.Rarity.Rare)
+}
+
+func TestDecodeGameConfigFile_IncludeCycle(t *testing.T) {
+	// Setup
+	fsys := fstest.MapFS{
+		"a.yaml": {Data: []byte("rarity:\n  $include: b.yaml\n")},
+		"b.yaml": {Data: []byte("common:\n  $include: a.yaml\n")},
+	}
+
+	// Call the function
+	config, err := decodeGameConfigFile(fsys, "a.yaml")
+
+	// Assertions
+	assert.Nil(t, config)
+	assert.EqualError(t, err, "include cycle: a.yaml -> b.yaml -> a.yaml")
+}
+
+func TestDecodeGameConfigFile_InvalidIncludes(t *testing.T) {
+	tests := []struct {
+		name  string
+		root  string
+		error string
+	}{
+		{name: "missing file", root: "rarity:\n  $include: missing.yaml\n", error: "open missing.yaml: file does not exist"},
+		{name: "unsupported format", root: "rarity:\n  $include: rarity.toml\n", error: "rarity.toml: unsupported configuration format"},
+		{name: "not a path", root: "rarity:\n  $include: 3\n", error: "root.yaml: $include must be a file path or a list of file paths"},
+		{name: "empty list", root: "rarity:\n  $include: []\n", error: "root.yaml: $include must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			fsys := fstest.MapFS{
+				"root.yaml":   {Data: []byte(tt.root)},
+				"rarity.toml": {Data: []byte("chance = 1")},
+			}
+
+			// Call the function
+			config, err := decodeGameConfigFile(fsys, "root.yaml")
+
+			// Assertions
+			assert.Nil(t, config)
+			assert.EqualError(t, err, tt.error)
+		})
+	}
+}
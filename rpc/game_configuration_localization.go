@@ -0,0 +1,246 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type (
+	// localizedStringReference is a string ID referenced by the field at the path.
+	localizedStringReference struct {
+		path string
+		id   string
+	}
+)
+
+// localizeUserGameConfig resolves the localized texts of the configuration in the language of the user's account.
+// It also returns the language the texts were resolved in, empty when the configuration has no string tables.
+func localizeUserGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, config *common.GameConfig) (*common.GameConfig, string, error) {
+	if config.Localization == nil {
+		localized, _ := localizeGameConfig(config, common.EmptyString)
+		return localized, common.EmptyString, nil
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		logger.Error("AccountGetId error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+
+	localized, language := localizeGameConfig(config, account.GetUser().GetLangTag())
+	return localized, language, nil
+}
+
+// localizeGameConfig returns a copy of the configuration with every text referenced by a string ID resolved in the
+// given language, falling back to its base language and then to the default language. The string tables are not
+// part of the returned configuration. The special abilities of the items are described from their definitions.
+func localizeGameConfig(config *common.GameConfig, langTag string) (*common.GameConfig, string) {
+	if config.Localization == nil && len(config.Abilities) == 0 {
+		return config, common.EmptyString
+	}
+
+	var tables []map[string]string
+	language := common.EmptyString
+	if config.Localization != nil {
+		tables, language = localizationTables(config.Localization, langTag)
+	}
+	translate := func(id, literal string) string {
+		if id == common.EmptyString {
+			return literal
+		}
+		for _, table := range tables {
+			if text, ok := table[id]; ok {
+				return text
+			}
+		}
+		return literal
+	}
+
+	result := *config
+	result.Localization = nil
+	result.WelcomeMessage = translate(config.WelcomeMessageID, config.WelcomeMessage)
+
+	for _, tier := range rarityTiers(&result.Rarity) {
+		items := slices.Clone(tier.Items.Items)
+		for i := range items {
+			items[i].Name = translate(items[i].NameID, items[i].Name)
+			items[i].SpecialAbility = translate(items[i].SpecialAbilityID, items[i].SpecialAbility)
+			if ability, ok := config.Abilities[items[i].Ability]; ok {
+				items[i].SpecialAbility = abilityDescription(&ability, translate)
+			}
+		}
+		tier.Items.Items = items
+	}
+
+	result.EquipmentSlots = slices.Clone(config.EquipmentSlots)
+	for i := range result.EquipmentSlots {
+		result.EquipmentSlots[i].Name = translate(result.EquipmentSlots[i].NameID, result.EquipmentSlots[i].Name)
+	}
+
+	if config.AffixPools != nil {
+		result.AffixPools = make(map[string][]common.Affix, len(config.AffixPools))
+		for pool, affixes := range config.AffixPools {
+			affixes = slices.Clone(affixes)
+			for i := range affixes {
+				affixes[i].Text = translate(affixes[i].TextID, affixes[i].Text)
+			}
+			result.AffixPools[pool] = affixes
+		}
+	}
+
+	return &result, language
+}
+
+// localizationTables returns the string tables to look texts up in, from the most to the least specific, along with
+// the language of the first one.
+func localizationTables(localization *common.Localization, langTag string) ([]map[string]string, string) {
+	byLanguage := make(map[string]string, len(localization.Strings))
+	for language := range localization.Strings {
+		byLanguage[normalizeLanguageTag(language)] = language
+	}
+
+	var tables []map[string]string
+	resolved := common.EmptyString
+	for _, candidate := range languageFallbacks(langTag, localization.DefaultLanguage) {
+		language, ok := byLanguage[candidate]
+		if !ok {
+			continue
+		}
+		if resolved == common.EmptyString {
+			resolved = language
+		}
+		tables = append(tables, localization.Strings[language])
+	}
+
+	return tables, resolved
+}
+
+// languageFallbacks returns the normalized language tags to try for the requested one: the tag itself,
+// its base language and the default language.
+func languageFallbacks(langTag, defaultLanguage string) []string {
+	var fallbacks []string
+	add := func(tag string) {
+		if tag != common.EmptyString && !slices.Contains(fallbacks, tag) {
+			fallbacks = append(fallbacks, tag)
+		}
+	}
+
+	tag := normalizeLanguageTag(langTag)
+	add(tag)
+	add(baseLanguage(tag))
+	add(normalizeLanguageTag(defaultLanguage))
+
+	return fallbacks
+}
+
+// normalizeLanguageTag lower cases the tag and uses hyphens as separator, so that "pt_BR" matches "pt-br".
+func normalizeLanguageTag(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// baseLanguage returns the primary language subtag of a normalized tag, e.g. "pt" for "pt-br".
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+// localizationViolations checks that every string ID referenced by the configuration is translated. The default
+// language and every language without a base language table must translate all of them, while regional tables,
+// e.g. "pt-BR" next to "pt", may only translate the texts that differ. The default language must also translate
+// every text ability descriptions are generated from when the configuration has abilities.
+func localizationViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+
+	references := localizedStringReferences(config)
+	localization := config.Localization
+	if localization == nil {
+		for _, reference := range references {
+			violations.add(reference.path, "references the string %q but the configuration has no localization", reference.id)
+		}
+		return violations
+	}
+
+	defaultLanguage := normalizeLanguageTag(localization.DefaultLanguage)
+	languages := make([]string, 0, len(localization.Strings))
+	normalized := make(map[string]bool, len(localization.Strings))
+	for language := range localization.Strings {
+		languages = append(languages, language)
+		normalized[normalizeLanguageTag(language)] = true
+	}
+	sort.Strings(languages)
+
+	if defaultLanguage == common.EmptyString {
+		violations.add("localization.default_language", "must not be empty")
+	} else if !normalized[defaultLanguage] {
+		violations.add("localization.default_language", "has no string table for %q", localization.DefaultLanguage)
+	}
+
+	for _, language := range languages {
+		tag := normalizeLanguageTag(language)
+		if tag == common.EmptyString {
+			violations.add("localization.strings", "language tag must not be empty")
+			continue
+		}
+
+		table := localization.Strings[language]
+		if tag == defaultLanguage && len(config.Abilities) > 0 {
+			for _, id := range abilityStringIDs() {
+				if _, ok := table[id]; !ok {
+					violations.add("localization.strings."+language, "missing translation of %q describing the abilities", id)
+				}
+			}
+		}
+		if base := baseLanguage(tag); base != tag && normalized[base] {
+			continue
+		}
+
+		for _, reference := range references {
+			if _, ok := table[reference.id]; !ok {
+				violations.add("localization.strings."+language, "missing translation of %q referenced at %s", reference.id, reference.path)
+			}
+		}
+	}
+
+	return violations
+}
+
+// localizedStringReferences returns the string IDs referenced by the configuration in declaration order.
+func localizedStringReferences(config *common.GameConfig) []localizedStringReference {
+	var references []localizedStringReference
+	reference := func(path, id string) {
+		if id != common.EmptyString {
+			references = append(references, localizedStringReference{path: path, id: id})
+		}
+	}
+
+	reference("welcome_message_id", config.WelcomeMessageID)
+	for _, tier := range rarityTiers(&config.Rarity) {
+		for i, item := range tier.Items.Items {
+			itemPath := fmt.Sprintf("rarity.%s.items[%d]", tier.Name, i)
+			reference(itemPath+".name_id", item.NameID)
+			reference(itemPath+".special_ability_id", item.SpecialAbilityID)
+		}
+	}
+
+	for i, slot := range config.EquipmentSlots {
+		reference(fmt.Sprintf("equipment_slots[%d].name_id", i), slot.NameID)
+	}
+
+	pools := make([]string, 0, len(config.AffixPools))
+	for pool := range config.AffixPools {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	for _, pool := range pools {
+		for i, affix := range config.AffixPools[pool] {
+			reference(fmt.Sprintf("affix_pools.%s[%d].text_id", pool, i), affix.TextID)
+		}
+	}
+
+	return references
+}
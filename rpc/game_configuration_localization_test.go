@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func localizedGameConfig() *common.GameConfig {
+	return &common.GameConfig{
+		WelcomeMessage:   "Welcome",
+		WelcomeMessageID: "welcome",
+		XpRate:           1,
+		Rarity: common.Rarity{Common: common.RarityItems{Chance: 1, Items: []common.Item{
+			{Name: "Wooden Sword", NameID: "sword", Damage: 10, Durability: 100},
+		}}},
+		Localization: &common.Localization{
+			DefaultLanguage: "en",
+			Strings: map[string]map[string]string{
+				"en":    {"welcome": "Welcome", "sword": "Wooden Sword"},
+				"pt":    {"welcome": "Bem-vindo", "sword": "Espada de madeira"},
+				"pt-BR": {"welcome": "Bem-vindo, parceiro"},
+			},
+		},
+	}
+}
+
+func TestLocalizeGameConfig_FallsBackThroughBaseAndDefaultLanguage(t *testing.T) {
+	tests := []struct {
+		langTag  string
+		language string
+		welcome  string
+		sword    string
+	}{
+		{langTag: "pt-BR", language: "pt-BR", welcome: "Bem-vindo, parceiro", sword: "Espada de madeira"},
+		{langTag: "pt_br", language: "pt-BR", welcome: "Bem-vindo, parceiro", sword: "Espada de madeira"},
+		{langTag: "pt-PT", language: "pt", welcome: "Bem-vindo", sword: "Espada de madeira"},
+		{langTag: "fr", language: "en", welcome: "Welcome", sword: "Wooden Sword"},
+		{langTag: "", language: "en", welcome: "Welcome", sword: "Wooden Sword"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.langTag, func(t *testing.T) {
+			// Setup
+			config := localizedGameConfig()
+
+			// Call the function
+			localized, language := localizeGameConfig(config, tt.langTag)
+
+			// Assertions
+			assert.Equal(t, tt.language, language)
+			assert.Equal(t, tt.welcome, localized.WelcomeMessage)
+			assert.Equal(t, tt.sword, localized.Rarity.Common.Items[0].Name)
+			assert.Nil(t, localized.Localization)
+			assert.Equal(t, "Wooden Sword", config.Rarity.Common.Items[0].Name, "the source configuration must not be modified")
+		})
+	}
+}
+
+func TestValidateGameConfig_MissingTranslations(t *testing.T) {
+	// Setup
+	config := localizedGameConfig()
+	delete(config.Localization.Strings["pt"], "sword")
+	config.Rarity.Common.Items[0].SpecialAbilityID = "sword.ability"
+	config.Rarity.Common.Items[0].SpecialAbility = "Slashes"
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "localization.strings.en", Message: `missing translation of "sword.ability" referenced at rarity.common.items[0].special_ability_id`},
+		{Path: "localization.strings.pt", Message: `missing translation of "sword" referenced at rarity.common.items[0].name_id`},
+		{Path: "localization.strings.pt", Message: `missing translation of "sword.ability" referenced at rarity.common.items[0].special_ability_id`},
+	}, violations)
+}
+
+func TestValidateGameConfig_StringIDsWithoutLocalization(t *testing.T) {
+	// Setup
+	config := localizedGameConfig()
+	config.Localization = nil
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "welcome_message_id", Message: `references the string "welcome" but the configuration has no localization`},
+		{Path: "rarity.common.items[0].name_id", Message: `references the string "sword" but the configuration has no localization`},
+	}, violations)
+}
+
+func TestValidateGameConfig_DefaultLanguageWithoutTable(t *testing.T) {
+	// Setup
+	config := localizedGameConfig()
+	config.Localization.DefaultLanguage = "de"
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "localization.default_language", Message: `has no string table for "de"`},
+	}, violations)
+}
+
+func TestReadGameConfigurationFromFile_Localized(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReturnGameConfigurationFromFile RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 3, Config: *localizedGameConfig()})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("AccountGetId", ctx, userID).Return(&api.Account{User: &api.User{Id: userID, LangTag: "pt-BR"}}, nil)
+
+	// Call the function
+	result, err := ReadGameConfigurationFromFile(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.NoError(t, err)
+	var config common.GameConfig
+	assert.NoError(t, json.Unmarshal([]byte(result), &config))
+	assert.Equal(t, "Bem-vindo, parceiro", config.WelcomeMessage)
+	assert.Equal(t, "Espada de madeira", config.Rarity.Common.Items[0].Name)
+	assert.Nil(t, config.Localization)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReadGameConfigurationFromStorage_LocalizedContentHash(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadGameConfigurationFromStorage RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 3})
+
+	snapshotJSON, _ := encodeGameConfigSnapshot(&common.GameConfigSnapshot{
+		SchemaVersion: common.GameConfigSchemaVersion,
+		ConfigVersion: 3,
+		Config:        *localizedGameConfig(),
+	})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead(userID)).Return([]*api.StorageObject{{Value: string(snapshotJSON), Version: "v7"}}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("AccountGetId", ctx, userID).Return(&api.Account{User: &api.User{Id: userID, LangTag: "pt-PT"}}, nil)
+
+	// Call the function
+	result, err := ReadGameConfigurationFromStorage(ctx, mockLogger, nil, nk, `{"if_none_match":"v7/pt"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"not_modified":true,"content_hash":"v7/pt"}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+)
+
+const (
+	// Schema version of the documents stored before they were tagged with one
+	legacyGameConfigSchemaVersion = 1
+)
+
+type (
+	// GameConfigMigration upgrades a stored game configuration document by one schema version.
+	GameConfigMigration struct {
+		FromSchema  int
+		Description string
+		Migrate     func(config map[string]any) error
+	}
+)
+
+// gameConfigMigrations is the chain of structural migrations of the game configuration.
+// Append a step migrating from the previous schema whenever common.GameConfigSchemaVersion is bumped.
+var gameConfigMigrations []*GameConfigMigration
+
+// decodeGameConfig decodes a stored game configuration document, migrating it from its schema version to the current one.
+func decodeGameConfig(logger runtime.Logger, raw json.RawMessage, schemaVersion int) (*common.GameConfig, error) {
+	if schemaVersion != common.GameConfigSchemaVersion {
+		migrated, err := migrateGameConfig(raw, schemaVersion, common.GameConfigSchemaVersion, gameConfigMigrations)
+		if err != nil {
+			logger.Error("Cannot migrate game configuration from schema %d: %+v", schemaVersion, err)
+			return nil, common.ErrUnMarshallingError
+		}
+		raw = migrated
+	}
+
+	var config common.GameConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		logger.Error("Cannot unmarshal game configuration: %+v", err)
+		return nil, common.ErrUnMarshallingError
+	}
+
+	return &config, nil
+}
+
+// migrateGameConfig runs the migration steps needed to bring the document from one schema version to another.
+func migrateGameConfig(raw json.RawMessage, from, to int, steps []*GameConfigMigration) (json.RawMessage, error) {
+	if from > to {
+		return nil, fmt.Errorf("schema version %d is newer than the supported version %d", from, to)
+	}
+
+	var document map[string]any
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+
+	for version := from; version < to; version++ {
+		step := findGameConfigMigration(steps, version)
+		if step == nil {
+			return nil, fmt.Errorf("no migration from schema version %d", version)
+		}
+		if err := step.Migrate(document); err != nil {
+			return nil, fmt.Errorf("migration from schema version %d (%s): %w", version, step.Description, err)
+		}
+	}
+
+	return json.Marshal(document)
+}
+
+// findGameConfigMigration returns the step migrating from the given schema version, nil if there is none.
+func findGameConfigMigration(steps []*GameConfigMigration, from int) *GameConfigMigration {
+	for _, step := range steps {
+		if step.FromSchema == from {
+			return step
+		}
+	}
+	return nil
+}
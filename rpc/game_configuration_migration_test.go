@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMigrateGameConfig_RunsStepsInOrder(t *testing.T) {
+	// Setup
+	steps := []*GameConfigMigration{
+		{
+			FromSchema:  2,
+			Description: "default welcome message",
+			Migrate: func(config map[string]any) error {
+				if _, ok := config["welcome_message"]; !ok {
+					config["welcome_message"] = "Welcome"
+				}
+				return nil
+			},
+		},
+		{
+			FromSchema:  1,
+			Description: "rename xp to xp_rate",
+			Migrate: func(config map[string]any) error {
+				config["xp_rate"] = config["xp"]
+				delete(config, "xp")
+				return nil
+			},
+		},
+	}
+
+	// Call the function
+	migrated, err := migrateGameConfig(json.RawMessage(`{"xp":1.5}`), 1, 3, steps)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"xp_rate":1.5,"welcome_message":"Welcome"}`, string(migrated))
+}
+
+func TestMigrateGameConfig_MissingStep(t *testing.T) {
+	// Call the function
+	migrated, err := migrateGameConfig(json.RawMessage(`{}`), 1, 2, nil)
+
+	// Assertions
+	assert.Nil(t, migrated)
+	assert.EqualError(t, err, "no migration from schema version 1")
+}
+
+func TestMigrateGameConfig_NewerSchema(t *testing.T) {
+	// Call the function
+	migrated, err := migrateGameConfig(json.RawMessage(`{}`), 3, 2, nil)
+
+	// Assertions
+	assert.Nil(t, migrated)
+	assert.EqualError(t, err, "schema version 3 is newer than the supported version 2")
+}
@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"encoding/json"
+	"oak/common"
+)
+
+// applyGameConfigPatch returns a copy of the configuration with a JSON merge patch (RFC 7386) applied:
+// objects are merged recursively, null removes a field and any other value, including arrays, replaces it.
+func applyGameConfigPatch(config *common.GameConfig, patch map[string]any) (*common.GameConfig, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]any
+	if err = json.Unmarshal(configJSON, &document); err != nil {
+		return nil, err
+	}
+
+	patchedJSON, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return nil, err
+	}
+
+	var patched common.GameConfig
+	if err = json.Unmarshal(patchedJSON, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// mergePatch merges the patch into the target document and returns it.
+func mergePatch(target map[string]any, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any, len(patch))
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+
+		patchObject, ok := value.(map[string]any)
+		if !ok {
+			target[key] = value
+			continue
+		}
+
+		targetObject, _ := target[key].(map[string]any)
+		target[key] = mergePatch(targetObject, patchObject)
+	}
+
+	return target
+}
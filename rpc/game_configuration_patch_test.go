@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergePatch_MergesObjectsAndRemovesNulls(t *testing.T) {
+	// Setup
+	target := map[string]any{
+		"xp_rate": 1.5,
+		"rarity": map[string]any{
+			"legendary": map[string]any{"chance": 0.05, "items": []any{"Excalibur"}},
+		},
+		"welcome_message": "Welcome",
+	}
+	patch := map[string]any{
+		"rarity": map[string]any{
+			"legendary": map[string]any{"chance": 0.1},
+		},
+		"welcome_message": nil,
+	}
+
+	// Call the function
+	merged := mergePatch(target, patch)
+
+	// Assertions
+	assert.Equal(t, map[string]any{
+		"xp_rate": 1.5,
+		"rarity": map[string]any{
+			"legendary": map[string]any{"chance": 0.1, "items": []any{"Excalibur"}},
+		},
+	}, merged)
+}
@@ -2,34 +2,85 @@ package rpc
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
-	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"github.com/heroiclabs/nakama-common/runtime"
 	"oak/common"
+	"time"
 )
 
-// Embed the game_config.json file
-//
-//go:embed config/game_config.json
-var gameConfigJSON []byte
+type (
+	ReadGameConfigRequest struct {
+		// Content hash of the configuration the client already has
+		IfNoneMatch string `json:"if_none_match,omitempty"`
+		// Paths of the fields to return, e.g. "rarity.legendary", all of them if empty
+		Fields []string `json:"fields,omitempty"`
+	}
+
+	// GameConfigResponse is the configuration served to the client along with what the server computed for it.
+	GameConfigResponse struct {
+		*common.GameConfig
+		LiveOps     *common.LiveOpsStatus `json:"live_ops,omitempty"`
+		ContentHash string                `json:"content_hash,omitempty"`
+	}
 
-// ReadGameConfigurationFromFile reads the game configuration from the embedded JSON file.
-func ReadGameConfigurationFromFile(ctx context.Context, logger runtime.Logger, _ *sql.DB, _ runtime.NakamaModule, _ string) (string, error) {
+	GameConfigNotModifiedResponse struct {
+		NotModified bool   `json:"not_modified"`
+		ContentHash string `json:"content_hash"`
+	}
+)
+
+// ReadGameConfigurationFromFile reads the active game configuration version, which is bootstrapped from the embedded JSON file,
+// with the experiment variants the user is assigned to and the live-ops overrides in effect applied, localized in the
+// language of the user's account.
+// The configuration is tagged with a hash of its content, and only a not modified response is returned when it
+// matches the hash sent by the client.
+func ReadGameConfigurationFromFile(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	logger.Debug("ReturnGameConfigurationFromFile RPC called")
 
 	// Get the user ID from the context
-	_, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
 	if !ok {
 		logger.Error("Context did not contain user ID.")
 		return common.EmptyString, common.ErrUserNotFound
 	}
 
-	return LoadGameConfig(logger)
+	req, err := unmarshalReadGameConfigRequest(logger, payload)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	version, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	// Serve the experiment variants the user is assigned to and the live-ops overrides in effect
+	config, derivation, err := deriveUserGameConfig(ctx, logger, nk, userID, version, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	config, _, err = localizeUserGameConfig(ctx, logger, nk, userID, config)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	contentHash, err := gameConfigContentHash(config, derivation.LiveOps)
+	if err != nil {
+		logger.Error("Error encoding JSON: %+v", err)
+		return common.EmptyString, common.ErrMarshallingError
+	}
+
+	return gameConfigResponse(logger, config, derivation.LiveOps, contentHash, req)
 }
 
-// ReadGameConfigurationFromStorage reads the game configuration from the storage.
-func ReadGameConfigurationFromStorage(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, _ string) (string, error) {
+// ReadGameConfigurationFromStorage reads the user's copy of the game configuration from the storage,
+// refreshing it first when the active game configuration version has changed since it was derived.
+// The storage object version, qualified by the language the texts are resolved in, is used as the content hash.
+func ReadGameConfigurationFromStorage(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	logger.Debug("ReadGameConfigurationFromStorage RPC called")
 
 	// Get the user ID from the context
@@ -39,6 +90,11 @@ func ReadGameConfigurationFromStorage(ctx context.Context, logger runtime.Logger
 		return common.EmptyString, common.ErrUserNotFound
 	}
 
+	req, err := unmarshalReadGameConfigRequest(logger, payload)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
 	// Read the game configuration from the storage
 	obj, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
 		Collection: common.StorageConfiguration,
@@ -55,16 +111,108 @@ func ReadGameConfigurationFromStorage(ctx context.Context, logger runtime.Logger
 		return common.EmptyString, common.ErrNotFound
 	}
 
-	return obj[0].GetValue(), nil
+	snapshot, storageVersion, err := refreshUserGameConfig(ctx, logger, nk, userID, obj[0])
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	config, language, err := localizeUserGameConfig(ctx, logger, nk, userID, &snapshot.Config)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	contentHash := storageVersion
+	switch {
+	case storageVersion == common.EmptyString:
+		// The copy was refreshed concurrently, so the version of the one served is unknown
+		if contentHash, err = gameConfigContentHash(config, snapshot.LiveOps); err != nil {
+			logger.Error("Error encoding JSON: %+v", err)
+			return common.EmptyString, common.ErrMarshallingError
+		}
+	case language != common.EmptyString:
+		// The same copy is served in another language once the player changes it
+		contentHash = storageVersion + "/" + language
+	}
+
+	return gameConfigResponse(logger, config, snapshot.LiveOps, contentHash, req)
+}
+
+// unmarshalReadGameConfigRequest unmarshals the optional payload of the config read RPCs.
+func unmarshalReadGameConfigRequest(logger runtime.Logger, payload string) (*ReadGameConfigRequest, error) {
+	req := &ReadGameConfigRequest{}
+	if payload == common.EmptyString {
+		return req, nil
+	}
+
+	if err := json.Unmarshal([]byte(payload), req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return nil, common.ErrUnMarshallingError
+	}
+
+	for _, field := range req.Fields {
+		if !gameConfigFieldKnown(field) {
+			logger.Error("Unknown game configuration field %s", field)
+			return nil, runtime.NewError("unknown game configuration field: "+field, common.RpcCodeInvalidArgument)
+		}
+	}
+
+	return req, nil
 }
 
-// LoadGameConfig loads the game configuration from the embedded JSON file.
+// gameConfigResponse returns a compact not modified response when the client already has the configuration with the
+// given content hash, otherwise the indented configuration, reduced to the requested fields, tagged with it and the
+// live-ops overrides in effect.
+func gameConfigResponse(logger runtime.Logger, config *common.GameConfig, liveOps *common.LiveOpsStatus, contentHash string, req *ReadGameConfigRequest) (string, error) {
+	if req.IfNoneMatch == contentHash {
+		return marshalResponse(logger, &GameConfigNotModifiedResponse{
+			NotModified: true,
+			ContentHash: contentHash,
+		})
+	}
+
+	tagged := &GameConfigResponse{
+		GameConfig:  config,
+		LiveOps:     liveOps,
+		ContentHash: contentHash,
+	}
+
+	var response any = tagged
+	if len(req.Fields) > 0 {
+		fields, err := selectGameConfigFields(tagged, req.Fields)
+		if err != nil {
+			logger.Error("Error encoding JSON: %+v", err)
+			return common.EmptyString, common.ErrMarshallingError
+		}
+		fields["content_hash"] = contentHash
+		response = fields
+	}
+
+	configJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		logger.Error("Error encoding JSON: %+v", err)
+		return common.EmptyString, common.ErrMarshallingError
+	}
+
+	return string(configJSON), nil
+}
+
+// gameConfigContentHash returns the SHA-256 of the compact JSON encoding of the configuration and the live-ops
+// overrides in effect, which is stable as struct fields are encoded in declaration order and map keys sorted.
+func gameConfigContentHash(config *common.GameConfig, liveOps *common.LiveOpsStatus) (string, error) {
+	configJSON, err := json.Marshal(&GameConfigResponse{GameConfig: config, LiveOps: liveOps})
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	sum := sha256.Sum256(configJSON)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LoadGameConfig loads the game configuration from the embedded configuration files.
 var LoadGameConfig = func(logger runtime.Logger) (string, error) {
-	// Original function code
-	var config common.GameConfig
-	if err := json.Unmarshal(gameConfigJSON, &config); err != nil {
-		logger.Error("Error decoding embedded JSON: %+v", err)
-		return common.EmptyString, common.ErrUnMarshallingError
+	config, err := loadEmbeddedGameConfig(logger)
+	if err != nil {
+		return common.EmptyString, err
 	}
 	configJSON, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
@@ -73,3 +221,13 @@ var LoadGameConfig = func(logger runtime.Logger) (string, error) {
 	}
 	return string(configJSON), nil
 }
+
+// loadEmbeddedGameConfig decodes the embedded configuration files, the bootstrap default of the game configuration.
+func loadEmbeddedGameConfig(logger runtime.Logger) (*common.GameConfig, error) {
+	config, err := decodeGameConfigFile(gameConfigFS, gameConfigRootFile)
+	if err != nil {
+		logger.Error("Error decoding embedded game configuration: %+v", err)
+		return nil, common.ErrUnMarshallingError
+	}
+	return config, nil
+}
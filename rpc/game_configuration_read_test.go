@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/heroiclabs/nakama-common/api"
 	"github.com/heroiclabs/nakama-common/runtime"
@@ -21,23 +22,54 @@ func TestReadGameConfigurationFromFile_Success(t *testing.T) {
 	userID := "user123"
 	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
 
-	// Mock the LoadGameConfig function to return a predefined game config
+	// Mock the LoadActiveGameConfig function to return a predefined game config
 	expectedConfig := `{
-	  "game_name": "Super Fun Game",
-	  "max_players": 100
-	}`
-	mockLoadGameConfig := func(logger runtime.Logger) (string, error) {
-		return expectedConfig, nil
+  "welcome_message": "Welcome",
+  "xp_rate": 2,
+  "rarity": {
+    "common": {
+      "chance": 1,
+      "items": null
+    },
+    "uncommon": {
+      "chance": 0,
+      "items": null
+    },
+    "rare": {
+      "chance": 0,
+      "items": null
+    },
+    "legendary": {
+      "chance": 0,
+      "items": null
+    }
+  },
+  "content_hash": "1e7cf2bc86b6e488f1b2bd533f428fdca3a27cdea8e1c96825ca7b8754c38186"
+}`
+	mockLoadActiveGameConfig := func(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.GameConfigVersion, error) {
+		return &common.GameConfigVersion{
+			Version: 3,
+			Config: common.GameConfig{
+				WelcomeMessage: "Welcome",
+				XpRate:         2,
+				Rarity:         common.Rarity{Common: common.RarityItems{Chance: 1}},
+			},
+		}, nil
 	}
-	LoadGameConfig = mockLoadGameConfig
+	LoadActiveGameConfig = mockLoadActiveGameConfig
+
+	// Mock the Nakama module to simulate that no live-ops override is scheduled
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
 
 	// Call the function
-	result, err := ReadGameConfigurationFromFile(ctx, mockLogger, nil, nil, "")
+	result, err := ReadGameConfigurationFromFile(ctx, mockLogger, nil, nk, "")
 
 	// Assertions
 	assert.NoError(t, err)
 	assert.Equal(t, expectedConfig, result)
 	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
 }
 
 func TestReadGameConfigurationFromStorage_Success(t *testing.T) {
@@ -49,10 +81,34 @@ func TestReadGameConfigurationFromStorage_Success(t *testing.T) {
 	userID := "user123"
 	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
 
+	// Mock the active version the stored copy was derived from
+	LoadActiveGameConfig = func(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.GameConfigVersion, error) {
+		return &common.GameConfigVersion{Version: 3}, nil
+	}
+
 	// Mock the Nakama module to simulate a successful storage read
 	expectedConfig := `{
-  "game_name": "Super Fun Game",
-  "max_players": 100
+  "welcome_message": "Welcome",
+  "xp_rate": 2,
+  "rarity": {
+    "common": {
+      "chance": 1,
+      "items": null
+    },
+    "uncommon": {
+      "chance": 0,
+      "items": null
+    },
+    "rare": {
+      "chance": 0,
+      "items": null
+    },
+    "legendary": {
+      "chance": 0,
+      "items": null
+    }
+  },
+  "content_hash": "v7"
 }`
 	nk := new(mocks.NakamaModule)
 	nk.On("StorageRead", ctx, []*runtime.StorageRead{
@@ -62,8 +118,12 @@ func TestReadGameConfigurationFromStorage_Success(t *testing.T) {
 			UserID:     userID,
 		},
 	}).Return([]*api.StorageObject{
-		{Value: expectedConfig},
+		{
+			Value:   `{"welcome_message":"Welcome","xp_rate":2,"rarity":{"common":{"chance":1}},"_snapshot":{"schema_version":1,"config_version":3}}`,
+			Version: "v7",
+		},
 	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
 
 	// Call the function
 	result, err := ReadGameConfigurationFromStorage(ctx, mockLogger, nil, nk, "")
@@ -112,11 +172,11 @@ func TestReadGameConfigurationFromFile_LoadGameConfigError(t *testing.T) {
 	userID := "user123"
 	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
 
-	// Mock the LoadGameConfig function to return an error
-	mockLoadGameConfig := func(logger runtime.Logger) (string, error) {
-		return common.EmptyString, common.ErrUnMarshallingError
+	// Mock the LoadActiveGameConfig function to return an error
+	mockLoadActiveGameConfig := func(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.GameConfigVersion, error) {
+		return nil, common.ErrUnMarshallingError
 	}
-	LoadGameConfig = mockLoadGameConfig
+	LoadActiveGameConfig = mockLoadActiveGameConfig
 
 	// Call the function
 	result, err := ReadGameConfigurationFromFile(ctx, mockLogger, nil, nil, "")
@@ -244,3 +304,103 @@ func TestReadGameConfigurationFromFile_ContextErrorHandling(t *testing.T) {
 	assert.Equal(t, common.ErrUserNotFound, err)
 	mockLogger.AssertExpectations(t)
 }
+
+func TestReadGameConfigurationFromFile_NotModified(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReturnGameConfigurationFromFile RPC called").Twice()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{
+		Version: 3,
+		Config: common.GameConfig{
+			WelcomeMessage: "Welcome",
+			XpRate:         2,
+			Rarity:         common.Rarity{Common: common.RarityItems{Chance: 1}},
+		},
+	})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function once to learn the content hash, then again with it
+	result, err := ReadGameConfigurationFromFile(ctx, mockLogger, nil, nk, "")
+	assert.NoError(t, err)
+	var config GameConfigResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &config))
+	assert.Len(t, config.ContentHash, 64)
+
+	result, err = ReadGameConfigurationFromFile(ctx, mockLogger, nil, nk, `{"if_none_match":"`+config.ContentHash+`"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"not_modified":true,"content_hash":"`+config.ContentHash+`"}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReadGameConfigurationFromFile_ContentHashChanges(t *testing.T) {
+	// Setup
+	base := &common.GameConfig{WelcomeMessage: "Welcome", XpRate: 2}
+	changed := &common.GameConfig{WelcomeMessage: "Welcome", XpRate: 3}
+
+	liveOps := &common.LiveOpsStatus{ActiveOverrides: []string{"double_xp"}, NextChangeAt: 200}
+
+	// Call the function
+	baseHash, err := gameConfigContentHash(base, nil)
+	assert.NoError(t, err)
+	changedHash, err := gameConfigContentHash(changed, nil)
+	assert.NoError(t, err)
+	liveOpsHash, err := gameConfigContentHash(base, liveOps)
+	assert.NoError(t, err)
+	sameHash, err := gameConfigContentHash(&common.GameConfig{WelcomeMessage: "Welcome", XpRate: 2}, nil)
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.NotEqual(t, baseHash, changedHash)
+	assert.NotEqual(t, baseHash, liveOpsHash)
+	assert.Equal(t, baseHash, sameHash)
+}
+
+func TestReadGameConfigurationFromStorage_NotModified(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadGameConfigurationFromStorage RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 3})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead(userID)).Return([]*api.StorageObject{{
+		Value:   `{"welcome_message":"Welcome","_snapshot":{"schema_version":1,"config_version":3}}`,
+		Version: "v7",
+	}}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := ReadGameConfigurationFromStorage(ctx, mockLogger, nil, nk, `{"if_none_match":"v7"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"not_modified":true,"content_hash":"v7"}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReadGameConfigurationFromStorage_InvalidPayload(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadGameConfigurationFromStorage RPC called").Once()
+	mockLogger.On("Error", "Cannot unmarshal payload: %+v", mock.Anything).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ReadGameConfigurationFromStorage(ctx, mockLogger, nil, nil, "{")
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrUnMarshallingError, err)
+	mockLogger.AssertExpectations(t)
+}
@@ -0,0 +1,221 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"maps"
+	"oak/common"
+	"reflect"
+	"time"
+)
+
+// SyncUserGameConfig brings the user's copy of the game configuration in line with the active version, creating it if missing.
+func SyncUserGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) (*common.GameConfigSnapshot, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageConfiguration,
+		Key:        common.StorageGameConfigKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.ErrInternalError
+	}
+
+	var obj *api.StorageObject
+	if len(objects) > 0 {
+		obj = objects[0]
+	}
+
+	snapshot, _, err := refreshUserGameConfig(ctx, logger, nk, userID, obj)
+	return snapshot, err
+}
+
+// refreshUserGameConfig migrates the stored user copy to the current schema and re-derives it from the active version
+// when it was derived from another version, other experiment variants or other live-ops overrides, e.g. after a publish,
+// a rollback, a forced assignment or once an override starts or ends. A nil object creates the copy.
+// It also returns the storage version of the copy, empty if it is unknown because of a concurrent refresh.
+func refreshUserGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, obj *api.StorageObject) (*common.GameConfigSnapshot, string, error) {
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return nil, common.EmptyString, err
+	}
+
+	// What the copy is derived with is resolved first, so that an up-to-date copy is not derived again
+	now := time.Now().Unix()
+	derivation, overrides, err := resolveGameConfigDerivation(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return nil, common.EmptyString, err
+	}
+
+	// Only create the copy if no concurrent request has created it in the meantime
+	storageVersion := "*"
+	var snapshot *common.GameConfigSnapshot
+	migrated := false
+	if obj != nil {
+		storageVersion = obj.GetVersion()
+		snapshot, migrated = decodeGameConfigSnapshot(logger, obj.GetValue())
+	}
+
+	if snapshot != nil && snapshot.ConfigVersion == active.Version && maps.Equal(snapshot.Variants, derivation.Variants) &&
+		reflect.DeepEqual(snapshot.LiveOps, derivation.LiveOps) {
+		if !migrated {
+			return snapshot, storageVersion, nil
+		}
+	} else {
+		config, err := applyGameConfigDerivation(logger, active, derivation, overrides, now)
+		if err != nil {
+			return nil, common.EmptyString, err
+		}
+		snapshot = &common.GameConfigSnapshot{
+			ConfigVersion: active.Version,
+			Variants:      derivation.Variants,
+			LiveOps:       derivation.LiveOps,
+			Config:        *config,
+		}
+	}
+	snapshot.SchemaVersion = common.GameConfigSchemaVersion
+	snapshot.UpdatedAt = now
+
+	snapshotJSON, err := encodeGameConfigSnapshot(snapshot)
+	if err != nil {
+		logger.Error("Cannot marshal game configuration snapshot: %+v", err)
+		return nil, common.EmptyString, common.ErrMarshallingError
+	}
+
+	acks, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      common.StorageConfiguration,
+		Key:             common.StorageGameConfigKey,
+		UserID:          userID,
+		Value:           string(snapshotJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_OWNER_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}})
+	if err != nil {
+		if errors.Is(err, runtime.ErrStorageRejectedVersion) {
+			// A concurrent request has refreshed the copy from the same active version
+			logger.Warn("Game configuration of user %s was refreshed concurrently", userID)
+			return snapshot, common.EmptyString, nil
+		}
+		logger.Error("StorageWrite error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+
+	logger.Debug("Refreshed game configuration of user %s to version %d", userID, snapshot.ConfigVersion)
+
+	if len(acks) == 0 {
+		return snapshot, common.EmptyString, nil
+	}
+
+	return snapshot, acks[0].GetVersion(), nil
+}
+
+// gameConfigSnapshotMetaKey is the key of the stored user copy holding what it was derived from, next to the fields
+// of the configuration. The copy keeps the shape of the bare configuration that clients read it with.
+const gameConfigSnapshotMetaKey = "_snapshot"
+
+// gameConfigSnapshotMeta is what a stored user copy was derived from and the schema it was written with.
+type gameConfigSnapshotMeta struct {
+	SchemaVersion int                   `json:"schema_version"`
+	ConfigVersion int                   `json:"config_version"`
+	Variants      map[string]string     `json:"variants,omitempty"`
+	LiveOps       *common.LiveOpsStatus `json:"live_ops,omitempty"`
+	UpdatedAt     int64                 `json:"updated_at"`
+}
+
+// encodeGameConfigSnapshot encodes a user copy as the bare configuration along with its metadata under
+// gameConfigSnapshotMetaKey.
+func encodeGameConfigSnapshot(snapshot *common.GameConfigSnapshot) ([]byte, error) {
+	configJSON, err := json.Marshal(&snapshot.Config)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]json.RawMessage
+	if err = json.Unmarshal(configJSON, &document); err != nil {
+		return nil, err
+	}
+
+	metaJSON, err := json.Marshal(&gameConfigSnapshotMeta{
+		SchemaVersion: snapshot.SchemaVersion,
+		ConfigVersion: snapshot.ConfigVersion,
+		Variants:      snapshot.Variants,
+		LiveOps:       snapshot.LiveOps,
+		UpdatedAt:     snapshot.UpdatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	document[gameConfigSnapshotMetaKey] = metaJSON
+
+	return json.Marshal(document)
+}
+
+// decodeGameConfigSnapshot decodes a stored user copy, reporting whether it had to be migrated to the current schema
+// or rewritten in the current shape. Besides the current shape it reads the bare configuration written before copies
+// were versioned and the envelope nesting the configuration under "config" written since.
+// An undecodable copy yields nil so that it gets re-derived from the active version.
+func decodeGameConfigSnapshot(logger runtime.Logger, value string) (*common.GameConfigSnapshot, bool) {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &document); err != nil {
+		logger.Warn("Cannot unmarshal game configuration snapshot, re-deriving it: %+v", err)
+		return nil, false
+	}
+
+	meta := gameConfigSnapshotMeta{SchemaVersion: legacyGameConfigSchemaVersion}
+	raw := json.RawMessage(value)
+	rewrite := false
+	if metaJSON, ok := document[gameConfigSnapshotMetaKey]; ok {
+		if err := json.Unmarshal(metaJSON, &meta); err != nil {
+			logger.Warn("Cannot unmarshal game configuration snapshot metadata, re-deriving it: %+v", err)
+			return nil, false
+		}
+		delete(document, gameConfigSnapshotMetaKey)
+		stripped, err := json.Marshal(document)
+		if err != nil {
+			logger.Warn("Cannot marshal game configuration snapshot, re-deriving it: %+v", err)
+			return nil, false
+		}
+		raw = stripped
+	} else {
+		var envelope struct {
+			SchemaVersion *int              `json:"schema_version"`
+			ConfigVersion int               `json:"config_version"`
+			Variants      map[string]string `json:"variants"`
+			Config        json.RawMessage   `json:"config"`
+			UpdatedAt     int64             `json:"updated_at"`
+		}
+		if err := json.Unmarshal([]byte(value), &envelope); err != nil {
+			logger.Warn("Cannot unmarshal game configuration snapshot, re-deriving it: %+v", err)
+			return nil, false
+		}
+		// Copies without a schema version hold the bare configuration
+		if envelope.SchemaVersion != nil {
+			meta = gameConfigSnapshotMeta{
+				SchemaVersion: *envelope.SchemaVersion,
+				ConfigVersion: envelope.ConfigVersion,
+				Variants:      envelope.Variants,
+				UpdatedAt:     envelope.UpdatedAt,
+			}
+			raw = envelope.Config
+		}
+		rewrite = true
+	}
+
+	config, err := decodeGameConfig(logger, raw, meta.SchemaVersion)
+	if err != nil {
+		logger.Warn("Cannot decode game configuration snapshot, re-deriving it: %+v", err)
+		return nil, false
+	}
+
+	return &common.GameConfigSnapshot{
+		SchemaVersion: meta.SchemaVersion,
+		ConfigVersion: meta.ConfigVersion,
+		Variants:      meta.Variants,
+		LiveOps:       meta.LiveOps,
+		Config:        *config,
+		UpdatedAt:     meta.UpdatedAt,
+	}, rewrite || meta.SchemaVersion != common.GameConfigSchemaVersion
+}
@@ -0,0 +1,212 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// mockActiveGameConfig replaces the active game configuration for the duration of the test.
+func mockActiveGameConfig(t *testing.T, version *common.GameConfigVersion) {
+	original := LoadActiveGameConfig
+	LoadActiveGameConfig = func(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.GameConfigVersion, error) {
+		return version, nil
+	}
+	t.Cleanup(func() {
+		LoadActiveGameConfig = original
+	})
+}
+
+func userGameConfigRead(userID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageConfiguration,
+		Key:        common.StorageGameConfigKey,
+		UserID:     userID,
+	}}
+}
+
+func TestSyncUserGameConfig_CreatesMissingCopy(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Refreshed game configuration of user %s to version %d", "user123", 2).Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 2, Config: common.GameConfig{XpRate: 3}})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].UserID == "user123" && writes[0].Version == "*"
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	snapshot, err := SyncUserGameConfig(ctx, mockLogger, nk, "user123")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 2, snapshot.ConfigVersion)
+	assert.Equal(t, common.GameConfigSchemaVersion, snapshot.SchemaVersion)
+	assert.Equal(t, 3.0, snapshot.Config.XpRate)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSyncUserGameConfig_ReDerivesLegacyCopy(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Refreshed game configuration of user %s to version %d", "user123", 4).Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: common.GameConfig{XpRate: 2}})
+
+	var written string
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{
+		// Copies written at sign-up before they were versioned hold the bare configuration
+		{Value: `{"welcome_message":"Old","xp_rate":1}`, Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		written = writes[0].Value
+		return len(writes) == 1 && writes[0].Version == "v1" &&
+			writes[0].PermissionRead == runtime.STORAGE_PERMISSION_OWNER_READ
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	snapshot, err := SyncUserGameConfig(ctx, mockLogger, nk, "user123")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 4, snapshot.ConfigVersion)
+	assert.Equal(t, 2.0, snapshot.Config.XpRate)
+	// The copy keeps the shape of the bare configuration, with what it was derived from alongside
+	var stored struct {
+		XpRate   float64                `json:"xp_rate"`
+		Snapshot gameConfigSnapshotMeta `json:"_snapshot"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(written), &stored))
+	assert.Equal(t, 2.0, stored.XpRate)
+	assert.Equal(t, 4, stored.Snapshot.ConfigVersion)
+	assert.Equal(t, common.GameConfigSchemaVersion, stored.Snapshot.SchemaVersion)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSyncUserGameConfig_RewritesEnvelopeCopy(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "Refreshed game configuration of user %s to version %d", "user123", 4).Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: common.GameConfig{XpRate: 2}})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{
+		// Copies nesting the configuration under "config" are rewritten in the shape of the bare configuration
+		{Value: `{"schema_version":1,"config_version":4,"config":{"xp_rate":2}}`, Version: "v4"},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		snapshot, rewrite := decodeGameConfigSnapshot(mockLogger, writes[0].Value)
+		return len(writes) == 1 && writes[0].Version == "v4" && !rewrite &&
+			snapshot.ConfigVersion == 4 && snapshot.Config.XpRate == 2
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	snapshot, err := SyncUserGameConfig(ctx, mockLogger, nk, "user123")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 4, snapshot.ConfigVersion)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSyncUserGameConfig_UpToDateCopy(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: common.GameConfig{XpRate: 2}})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{
+		{Value: `{"xp_rate":2,"_snapshot":{"schema_version":1,"config_version":4}}`, Version: "v4"},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	snapshot, err := SyncUserGameConfig(ctx, mockLogger, nk, "user123")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 4, snapshot.ConfigVersion)
+	nk.AssertNotCalled(t, "StorageWrite", mock.Anything, mock.Anything)
+	nk.AssertExpectations(t)
+}
+
+func TestSyncUserGameConfig_UpToDateCopyIsNotDerived(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+
+	ctx := context.Background()
+	// The variant cannot be applied, so deriving the configuration would fail
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: common.GameConfig{
+		XpRate: 2,
+		Experiments: []common.Experiment{{
+			ID:       "xp_boost",
+			Variants: []common.ExperimentVariant{{Name: "broken", Allocation: 100, Overrides: map[string]any{"xp_rate": "fast"}}},
+		}},
+	}})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{
+		{Value: `{"xp_rate":2,"_snapshot":{"schema_version":1,"config_version":4,"variants":{"xp_boost":"broken"}}}`, Version: "v4"},
+	}, nil)
+	nk.On("StorageRead", ctx, experimentAssignmentsRead("user123")).Return([]*api.StorageObject{
+		{Value: `{"assignments":{"xp_boost":{"variant":"broken","assigned_at":1}}}`, Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	snapshot, err := SyncUserGameConfig(ctx, mockLogger, nk, "user123")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 4, snapshot.ConfigVersion)
+	assert.Equal(t, map[string]string{"xp_boost": "broken"}, snapshot.Variants)
+	nk.AssertNotCalled(t, "StorageWrite", mock.Anything, mock.Anything)
+	nk.AssertExpectations(t)
+}
+
+func TestSyncUserGameConfig_ConcurrentRefresh(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Warn", "Game configuration of user %s was refreshed concurrently", "user123").Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 5})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, userGameConfigRead("user123")).Return([]*api.StorageObject{
+		{Value: `{"_snapshot":{"schema_version":1,"config_version":4}}`, Version: "v4"},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.Anything).Return(nil, runtime.ErrStorageRejectedVersion)
+
+	// Call the function
+	snapshot, err := SyncUserGameConfig(ctx, mockLogger, nk, "user123")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 5, snapshot.ConfigVersion)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
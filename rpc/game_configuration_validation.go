@@ -0,0 +1,215 @@
+package rpc
+
+import (
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+	"strings"
+)
+
+const (
+	// Tolerance used when comparing the sum of the rarity chances to 1.0
+	rarityChanceEpsilon = 1e-6
+)
+
+type (
+	// rarityTier is a rarity tier of the drop table addressed by its JSON name.
+	rarityTier struct {
+		Name  string
+		Items *common.RarityItems
+	}
+
+	// configViolations collects the semantic violations of a game configuration.
+	configViolations []*common.ConfigViolation
+)
+
+// add records a violation of the field at the path, with a message formatted from the format and arguments.
+func (violations *configViolations) add(path, format string, args ...any) {
+	*violations = append(*violations, &common.ConfigViolation{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// rarityTiers returns the rarity tiers of the drop table from the most to the least common.
+func rarityTiers(rarity *common.Rarity) []rarityTier {
+	return []rarityTier{
+		{Name: "common", Items: &rarity.Common},
+		{Name: "uncommon", Items: &rarity.Uncommon},
+		{Name: "rare", Items: &rarity.Rare},
+		{Name: "legendary", Items: &rarity.Legendary},
+	}
+}
+
+// ValidateGameConfig checks the game configuration semantically and logs every violation found.
+// It returns an invalid argument error listing the violations, or nil if the configuration is valid.
+func ValidateGameConfig(logger runtime.Logger, config *common.GameConfig) error {
+	violations := gameConfigViolations(config)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		logger.Error("Invalid game configuration at %s: %s", violation.Path, violation.Message)
+		messages = append(messages, violation.Path+": "+violation.Message)
+	}
+
+	return runtime.NewError("invalid game configuration: "+strings.Join(messages, "; "), common.RpcCodeInvalidArgument)
+}
+
+// gameConfigViolations returns the semantic violations of the game configuration.
+func gameConfigViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+
+	if strings.TrimSpace(config.WelcomeMessage) == common.EmptyString && config.WelcomeMessageID == common.EmptyString {
+		violations.add("welcome_message", "must not be empty")
+	}
+
+	if config.XpRate <= 0 || math.IsNaN(config.XpRate) || math.IsInf(config.XpRate, 0) {
+		violations.add("xp_rate", "must be a positive number, got %v", config.XpRate)
+	}
+
+	if config.Inventory != nil && config.Inventory.Capacity < 0 {
+		violations.add("inventory.capacity", "must not be negative, got %d", config.Inventory.Capacity)
+	}
+
+	violations = append(violations, rarityViolations(&config.Rarity)...)
+	violations = append(violations, lootTableViolations(&config.Rarity, config.LootTables)...)
+	violations = append(violations, affixViolations(config)...)
+	violations = append(violations, repairViolations(config)...)
+	violations = append(violations, equipmentSlotViolations(config)...)
+	violations = append(violations, abilityViolations(config)...)
+	violations = append(violations, recipeViolations(config)...)
+	violations = append(violations, salvageViolations(config)...)
+	violations = append(violations, tradingViolations(config)...)
+	violations = append(violations, auctionHouseViolations(config)...)
+	violations = append(violations, localizationViolations(config)...)
+
+	// Variants are only checked against a valid base so that its violations are not repeated for every variant
+	baseValid := len(violations) == 0
+	base := *config
+	base.Experiments = nil
+
+	experimentIDs := make(map[string]bool, len(config.Experiments))
+	for i, experiment := range config.Experiments {
+		experimentPath := fmt.Sprintf("experiments[%d]", i)
+
+		switch {
+		case experiment.ID == common.EmptyString:
+			violations.add(experimentPath+".id", "must not be empty")
+		case experimentIDs[experiment.ID]:
+			violations.add(experimentPath+".id", "duplicates the experiment ID %q", experiment.ID)
+		default:
+			experimentIDs[experiment.ID] = true
+		}
+
+		if len(experiment.Variants) == 0 {
+			violations.add(experimentPath+".variants", "must not be empty")
+		}
+
+		allocationSum := 0.0
+		variantNames := make(map[string]bool, len(experiment.Variants))
+		for j, variant := range experiment.Variants {
+			variantPath := fmt.Sprintf("%s.variants[%d]", experimentPath, j)
+
+			switch {
+			case variant.Name == common.EmptyString:
+				violations.add(variantPath+".name", "must not be empty")
+			case variant.Name == common.ExperimentControlVariant:
+				violations.add(variantPath+".name", "%q is reserved for the players outside of every variant", variant.Name)
+			case variantNames[variant.Name]:
+				violations.add(variantPath+".name", "duplicates the variant name %q", variant.Name)
+			default:
+				variantNames[variant.Name] = true
+			}
+
+			if variant.Allocation < 0 || variant.Allocation > 100 || math.IsNaN(variant.Allocation) {
+				violations.add(variantPath+".allocation", "must be a percentage between 0 and 100, got %v", variant.Allocation)
+			} else {
+				allocationSum += variant.Allocation
+			}
+
+			if _, ok := variant.Overrides["experiments"]; ok {
+				violations.add(variantPath+".overrides.experiments", "must not be overridden")
+				continue
+			}
+			if !baseValid {
+				continue
+			}
+
+			patched, err := applyGameConfigPatch(&base, variant.Overrides)
+			if err != nil {
+				violations.add(variantPath+".overrides", "cannot be applied: %v", err)
+				continue
+			}
+			for _, violation := range gameConfigViolations(patched) {
+				violations.add(variantPath+".overrides."+violation.Path, "%s", violation.Message)
+			}
+		}
+
+		if allocationSum > 100+rarityChanceEpsilon {
+			violations.add(experimentPath+".variants", "allocations must not exceed 100%%, got %.6g", allocationSum)
+		}
+	}
+
+	return violations
+}
+
+// rarityViolations returns the semantic violations of the drop table, which loot rolls are checked against as well.
+func rarityViolations(rarity *common.Rarity) []*common.ConfigViolation {
+	var violations configViolations
+
+	for _, currency := range sortedKeys(rarity.Cost) {
+		if amount := rarity.Cost[currency]; amount <= 0 {
+			violations.add("rarity.cost."+currency, "must be positive, got %d", amount)
+		}
+	}
+
+	chanceSum := 0.0
+	itemPaths := make(map[string]string)
+	for _, tier := range rarityTiers(rarity) {
+		tierPath := "rarity." + tier.Name
+
+		chance := tier.Items.Chance
+		if chance < 0 || chance > 1 || math.IsNaN(chance) {
+			violations.add(tierPath+".chance", "must be between 0 and 1, got %v", chance)
+		} else {
+			chanceSum += chance
+		}
+
+		if chance > 0 && len(tier.Items.Items) == 0 {
+			violations.add(tierPath+".items", "must not be empty when the tier can drop")
+		}
+		violations = append(violations, pityViolations(tierPath, tier.Items)...)
+
+		for i, item := range tier.Items.Items {
+			itemPath := fmt.Sprintf("%s.items[%d]", tierPath, i)
+
+			name := strings.TrimSpace(item.Name)
+			switch {
+			case name == common.EmptyString:
+				violations.add(itemPath+".name", "must not be empty")
+			case itemPaths[name] != common.EmptyString:
+				violations.add(itemPath+".name", "duplicates the item name %q declared at %s", item.Name, itemPaths[name])
+			default:
+				itemPaths[name] = itemPath
+			}
+
+			violations = append(violations, itemStatViolations(itemPath, &item)...)
+			if item.Weight < 0 || math.IsNaN(item.Weight) || math.IsInf(item.Weight, 0) {
+				violations.add(itemPath+".weight", "must be a non-negative number, got %v", item.Weight)
+			}
+			if tier.Name == "legendary" && itemMaxStat(item.Damage, item.DamageRange) <= 0 && itemMaxStat(item.Defense, item.DefenseRange) <= 0 {
+				violations.add(itemPath, "legendary item must have damage or defense")
+			}
+		}
+	}
+
+	if math.Abs(chanceSum-1) > rarityChanceEpsilon {
+		violations.add("rarity", "chances must sum to 1.0, got %.6g", chanceSum)
+	}
+
+	return violations
+}
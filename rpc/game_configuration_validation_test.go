@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func embeddedGameConfig(t *testing.T) *common.GameConfig {
+	config, err := decodeGameConfigFile(gameConfigFS, gameConfigRootFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func marshalGameConfig(t *testing.T, config *common.GameConfig) string {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(configJSON)
+}
+
+func TestValidateGameConfig_EmbeddedConfigIsValid(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+
+	// Call the function
+	err := ValidateGameConfig(mockLogger, embeddedGameConfig(t))
+
+	// Assertions
+	assert.NoError(t, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestValidateGameConfig_ReportsPathQualifiedViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.LootTables = nil
+	config.Recipes = nil
+	config.XpRate = 0
+	config.Rarity.Common.Chance = 0.6
+	config.Rarity.Rare.Items[1].Durability = -1
+	config.Rarity.Legendary.Items[0].Damage = 0
+	config.Rarity.Uncommon.Items[0].Name = "Wooden Sword"
+	config.Rarity.Cost = map[string]int64{"gold": 0}
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "xp_rate", Message: "must be a positive number, got 0"},
+		{Path: "rarity.cost.gold", Message: "must be positive, got 0"},
+		{Path: "rarity.uncommon.items[0].name", Message: `duplicates the item name "Wooden Sword" declared at rarity.common.items[0]`},
+		{Path: "rarity.rare.items[1].durability", Message: "must be positive, got -1"},
+		{Path: "rarity.legendary.items[0]", Message: "legendary item must have damage or defense"},
+		{Path: "rarity", Message: "chances must sum to 1.0, got 1.1"},
+	}, violations)
+}
+
+func TestValidateGameConfig_EmptyTierThatCanDrop(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.LootTables = nil
+	config.Recipes = nil
+	config.Rarity.Rare.Items = nil
+
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Error", "Invalid game configuration at %s: %s", "rarity.rare.items", mock.Anything).Once()
+
+	// Call the function
+	err := ValidateGameConfig(mockLogger, config)
+
+	// Assertions
+	assert.EqualError(t, err, "invalid game configuration: rarity.rare.items: must not be empty when the tier can drop")
+	mockLogger.AssertExpectations(t)
+}
@@ -0,0 +1,424 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"time"
+)
+
+const (
+	// Maximum number of versions returned by a single history page
+	maxGameConfigHistoryLimit = 100
+)
+
+type (
+	PublishGameConfigRequest struct {
+		Config *common.GameConfig `json:"config"`
+		Note   string             `json:"note,omitempty"`
+	}
+
+	PublishGameConfigResponse struct {
+		Status  common.Status `json:"status"`
+		Version int           `json:"version"`
+	}
+
+	ListGameConfigVersionsRequest struct {
+		Limit  int    `json:"limit,omitempty"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+
+	GameConfigVersionSummary struct {
+		Version     int    `json:"version"`
+		Note        string `json:"note,omitempty"`
+		PublishedAt int64  `json:"published_at"`
+		Active      bool   `json:"active"`
+	}
+
+	ListGameConfigVersionsResponse struct {
+		ActiveVersion int                         `json:"active_version"`
+		Versions      []*GameConfigVersionSummary `json:"versions"`
+		Cursor        string                      `json:"cursor,omitempty"`
+	}
+
+	RollbackGameConfigRequest struct {
+		Version int `json:"version"`
+	}
+
+	RollbackGameConfigResponse struct {
+		Status        common.Status `json:"status"`
+		ActiveVersion int           `json:"active_version"`
+	}
+)
+
+// LoadActiveGameConfig loads the currently active game configuration version from the storage.
+var LoadActiveGameConfig = loadActiveGameConfig
+
+// loadActiveGameConfig returns the embedded configuration as version 0 while nothing has been published yet.
+func loadActiveGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.GameConfigVersion, error) {
+	pointer, _, err := readGameConfigPointer(ctx, logger, nk)
+	if err != nil {
+		return nil, err
+	}
+
+	if pointer == nil {
+		config, err := loadEmbeddedGameConfig(logger)
+		if err != nil {
+			return nil, err
+		}
+		return &common.GameConfigVersion{SchemaVersion: common.GameConfigSchemaVersion, Config: *config}, nil
+	}
+
+	version, err := readGameConfigVersion(ctx, logger, nk, pointer.ActiveVersion)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		logger.Error("Active game configuration version %d is missing", pointer.ActiveVersion)
+		return nil, common.ErrInternalError
+	}
+
+	return version, nil
+}
+
+// BootstrapGameConfig publishes the embedded configuration as the first version when the storage holds none.
+// The embedded configuration is validated even if it is not published, as it stays the fallback default.
+func BootstrapGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) error {
+	config, err := loadEmbeddedGameConfig(logger)
+	if err != nil {
+		return err
+	}
+	if err = ValidateGameConfig(logger, config); err != nil {
+		return err
+	}
+
+	pointer, pointerVersion, err := readGameConfigPointer(ctx, logger, nk)
+	if err != nil {
+		return err
+	}
+	if pointer != nil {
+		return nil
+	}
+
+	_, err = publishGameConfigVersion(ctx, logger, nk, pointer, pointerVersion, config, "bootstrap from embedded configuration")
+	if errors.Is(err, common.ErrVersionConflict) {
+		// Another node has bootstrapped the configuration in the meantime
+		return nil
+	}
+
+	return err
+}
+
+// PublishGameConfig publishes a new version of the game configuration and makes it the active one.
+func PublishGameConfig(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("PublishGameConfig RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req PublishGameConfigRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.Config == nil {
+		logger.Error("Payload did not contain a game configuration")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if err := ValidateGameConfig(logger, req.Config); err != nil {
+		return common.EmptyString, err
+	}
+	if err := validateLiveOpsOverrides(ctx, logger, nk, req.Config); err != nil {
+		return common.EmptyString, err
+	}
+
+	pointer, pointerVersion, err := readGameConfigPointer(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	version, err := publishGameConfigVersion(ctx, logger, nk, pointer, pointerVersion, req.Config, req.Note)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("Published game configuration version %d", version)
+
+	return marshalResponse(logger, &PublishGameConfigResponse{
+		Status:  common.StatusSuccess,
+		Version: version,
+	})
+}
+
+// ListGameConfigVersions lists the published game configuration versions, oldest first.
+func ListGameConfigVersions(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ListGameConfigVersions RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req ListGameConfigVersionsRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+	if req.Limit <= 0 || req.Limit > maxGameConfigHistoryLimit {
+		req.Limit = maxGameConfigHistoryLimit
+	}
+
+	pointer, _, err := readGameConfigPointer(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	objects, cursor, err := nk.StorageList(ctx, common.EmptyString, common.EmptyString, common.StorageGameConfigVersions, req.Limit, req.Cursor)
+	if err != nil {
+		logger.Error("StorageList error: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	resp := &ListGameConfigVersionsResponse{
+		Versions: make([]*GameConfigVersionSummary, 0, len(objects)),
+		Cursor:   cursor,
+	}
+	if pointer != nil {
+		resp.ActiveVersion = pointer.ActiveVersion
+	}
+
+	for _, obj := range objects {
+		// The summary fields are shared with the stored version, so the configuration is not decoded
+		summary := &GameConfigVersionSummary{}
+		if err := json.Unmarshal([]byte(obj.GetValue()), summary); err != nil {
+			logger.Error("Cannot unmarshal game configuration version %s: %+v", obj.GetKey(), err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+		summary.Active = summary.Version == resp.ActiveVersion
+		resp.Versions = append(resp.Versions, summary)
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// RollbackGameConfig makes a previously published game configuration version the active one.
+func RollbackGameConfig(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("RollbackGameConfig RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req RollbackGameConfigRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	pointer, pointerVersion, err := readGameConfigPointer(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if pointer == nil || req.Version <= 0 || req.Version > pointer.LatestVersion {
+		logger.Error("Game configuration version %d not found", req.Version)
+		return common.EmptyString, common.ErrNotFound
+	}
+
+	// Make sure the target version still exists before pointing at it
+	version, err := readGameConfigVersion(ctx, logger, nk, req.Version)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if version == nil {
+		logger.Error("Game configuration version %d not found", req.Version)
+		return common.EmptyString, common.ErrNotFound
+	}
+	if err = validateLiveOpsOverrides(ctx, logger, nk, &version.Config); err != nil {
+		return common.EmptyString, err
+	}
+
+	pointer.ActiveVersion = req.Version
+	pointer.UpdatedAt = time.Now().Unix()
+
+	pointerWrite, err := gameConfigPointerWrite(logger, pointer, pointerVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writeGameConfigObjects(ctx, logger, nk, pointerWrite); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("Rolled back game configuration to version %d", req.Version)
+
+	return marshalResponse(logger, &RollbackGameConfigResponse{
+		Status:        common.StatusSuccess,
+		ActiveVersion: req.Version,
+	})
+}
+
+// publishGameConfigVersion writes the configuration as the next version and activates it in one storage transaction.
+func publishGameConfigVersion(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, pointer *common.GameConfigPointer, pointerVersion string, config *common.GameConfig, note string) (int, error) {
+	if pointer == nil {
+		pointer = &common.GameConfigPointer{}
+	}
+
+	now := time.Now().Unix()
+	version := &common.GameConfigVersion{
+		Version:       pointer.LatestVersion + 1,
+		SchemaVersion: common.GameConfigSchemaVersion,
+		Config:        *config,
+		Note:          note,
+		PublishedAt:   now,
+	}
+	versionJSON, err := json.Marshal(version)
+	if err != nil {
+		logger.Error("Cannot marshal game configuration version: %+v", err)
+		return 0, common.ErrMarshallingError
+	}
+
+	pointer.ActiveVersion = version.Version
+	pointer.LatestVersion = version.Version
+	pointer.UpdatedAt = now
+
+	pointerWrite, err := gameConfigPointerWrite(logger, pointer, pointerVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	err = writeGameConfigObjects(ctx, logger, nk, &runtime.StorageWrite{
+		Collection: common.StorageGameConfigVersions,
+		Key:        gameConfigVersionKey(version.Version),
+		Value:      string(versionJSON),
+		// Published versions are immutable
+		Version:         "*",
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, pointerWrite)
+	if err != nil {
+		return 0, err
+	}
+
+	return version.Version, nil
+}
+
+// readGameConfigPointer reads the active version pointer along with its storage version, nil if nothing was published yet.
+func readGameConfigPointer(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.GameConfigPointer, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageConfiguration,
+		Key:        common.StorageGameConfigActiveKey,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+	if len(objects) == 0 {
+		return nil, common.EmptyString, nil
+	}
+
+	var pointer common.GameConfigPointer
+	if err = json.Unmarshal([]byte(objects[0].GetValue()), &pointer); err != nil {
+		logger.Error("Cannot unmarshal game configuration pointer: %+v", err)
+		return nil, common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	return &pointer, objects[0].GetVersion(), nil
+}
+
+// readGameConfigVersion reads a published game configuration version, nil if it does not exist.
+func readGameConfigVersion(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, version int) (*common.GameConfigVersion, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        gameConfigVersionKey(version),
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.ErrInternalError
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	return decodeGameConfigVersion(logger, objects[0].GetValue())
+}
+
+// decodeGameConfigVersion decodes a stored version, migrating its configuration to the current schema.
+func decodeGameConfigVersion(logger runtime.Logger, value string) (*common.GameConfigVersion, error) {
+	var stored struct {
+		common.GameConfigVersion
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		logger.Error("Cannot unmarshal game configuration version: %+v", err)
+		return nil, common.ErrUnMarshallingError
+	}
+
+	if stored.SchemaVersion == 0 {
+		// Versions published before they were tagged with a schema
+		stored.SchemaVersion = legacyGameConfigSchemaVersion
+	}
+
+	config, err := decodeGameConfig(logger, stored.Config, stored.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	version := stored.GameConfigVersion
+	version.SchemaVersion = common.GameConfigSchemaVersion
+	version.Config = *config
+
+	return &version, nil
+}
+
+// gameConfigPointerWrite builds the conditional write of the pointer, guarded by the storage version it was read at.
+func gameConfigPointerWrite(logger runtime.Logger, pointer *common.GameConfigPointer, pointerVersion string) (*runtime.StorageWrite, error) {
+	pointerJSON, err := json.Marshal(pointer)
+	if err != nil {
+		logger.Error("Cannot marshal game configuration pointer: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	if pointerVersion == common.EmptyString {
+		// Only succeed if no other writer created the pointer first
+		pointerVersion = "*"
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageConfiguration,
+		Key:             common.StorageGameConfigActiveKey,
+		Value:           string(pointerJSON),
+		Version:         pointerVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// writeGameConfigObjects writes the system owned configuration objects, mapping version check failures to a conflict.
+func writeGameConfigObjects(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, writes ...*runtime.StorageWrite) error {
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		if errors.Is(err, runtime.ErrStorageRejectedVersion) {
+			logger.Error("Game configuration was modified concurrently: %+v", err)
+			return common.ErrVersionConflict
+		}
+		logger.Error("StorageWrite error: %+v", err)
+		return common.ErrInternalError
+	}
+
+	return nil
+}
+
+// gameConfigVersionKey zero pads the version so storage listings are ordered by version.
+func gameConfigVersionKey(version int) string {
+	return fmt.Sprintf("%010d", version)
+}
@@ -0,0 +1,291 @@
+package rpc
+
+import (
+	"context"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+var gameConfigPointerRead = []*runtime.StorageRead{{
+	Collection: common.StorageConfiguration,
+	Key:        common.StorageGameConfigActiveKey,
+}}
+
+func TestPublishGameConfig_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "PublishGameConfig RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := PublishGameConfig(ctx, mockLogger, nil, nil, `{"config":{}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestPublishGameConfig_MissingConfig(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "PublishGameConfig RPC called").Once()
+	mockLogger.On("Error", "Payload did not contain a game configuration").Once()
+
+	// Call the function
+	result, err := PublishGameConfig(context.Background(), mockLogger, nil, nil, `{"note":"empty"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestPublishGameConfig_FirstVersion(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "PublishGameConfig RPC called").Once()
+	mockLogger.On("Info", "Published game configuration version %d", 1).Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 2 &&
+			writes[0].Collection == common.StorageGameConfigVersions &&
+			writes[0].Key == "0000000001" &&
+			writes[0].Version == "*" &&
+			writes[1].Key == common.StorageGameConfigActiveKey &&
+			writes[1].Version == "*"
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := PublishGameConfig(ctx, mockLogger, nil, nk, `{"config":`+marshalGameConfig(t, embeddedGameConfig(t))+`}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":"success","version":1}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestPublishGameConfig_ConcurrentPublish(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "PublishGameConfig RPC called").Once()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{
+		{Value: `{"active_version":2,"latest_version":2}`, Version: "v2"},
+	}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 2 && writes[0].Key == "0000000003" && writes[1].Version == "v2"
+	})).Return(nil, runtime.ErrStorageRejectedVersion)
+
+	// Call the function
+	result, err := PublishGameConfig(ctx, mockLogger, nil, nk, `{"config":`+marshalGameConfig(t, embeddedGameConfig(t))+`}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrVersionConflict, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestPublishGameConfig_InvalidConfig(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "PublishGameConfig RPC called").Once()
+	mockLogger.On("Error", "Invalid game configuration at %s: %s", "xp_rate", "must be a positive number, got 0").Once()
+
+	config := embeddedGameConfig(t)
+	config.XpRate = 0
+
+	// Call the function
+	payload := `{"config":` + marshalGameConfig(t, config) + `}`
+	result, err := PublishGameConfig(context.Background(), mockLogger, nil, nil, payload)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.EqualError(t, err, "invalid game configuration: xp_rate: must be a positive number, got 0")
+	mockLogger.AssertExpectations(t)
+}
+
+func TestPublishGameConfig_InvalidWithPendingOverride(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "PublishGameConfig RPC called").Once()
+	mockLogger.On("Error", "Invalid game configuration at %s: %s", "rarity", "chances must sum to 1.0, got 1.05").Once()
+	mockLogger.On("Error", "Live-ops override %s is not valid against the game configuration", "legendary_week").Once()
+
+	ctx := context.Background()
+
+	// Valid against the current base, but not once a variant of the new one raises the uncommon chance
+	config := embeddedGameConfig(t)
+	config.Experiments = []common.Experiment{{
+		ID: "uncommon_boost",
+		Variants: []common.ExperimentVariant{{
+			Name:       "boosted",
+			Allocation: 50,
+			Overrides:  map[string]any{"rarity": map[string]any{"common": map[string]any{"chance": 0.45}, "uncommon": map[string]any{"chance": 0.35}}},
+		}},
+	}}
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{
+		{Value: `{"overrides":[{"id":"legendary_week","start_at":100,"end_at":4102444800,"overrides":{"rarity":{"common":{"chance":0.45},"legendary":{"chance":0.1}}}}]}`, Version: "v1"},
+	}, nil)
+
+	// Call the function
+	result, err := PublishGameConfig(ctx, mockLogger, nil, nk, `{"config":`+marshalGameConfig(t, config)+`}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.EqualError(t, err, "invalid game configuration: rarity: chances must sum to 1.0, got 1.05")
+	mockLogger.AssertExpectations(t)
+	nk.AssertNotCalled(t, "StorageWrite", mock.Anything, mock.Anything)
+	nk.AssertExpectations(t)
+}
+
+func TestListGameConfigVersions_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ListGameConfigVersions RPC called").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{
+		{Value: `{"active_version":1,"latest_version":2}`},
+	}, nil)
+	nk.On("StorageList", ctx, "", "", common.StorageGameConfigVersions, 10, "").Return([]*api.StorageObject{
+		{Key: "0000000001", Value: `{"version":1,"note":"launch","published_at":100}`},
+		{Key: "0000000002", Value: `{"version":2,"published_at":200}`},
+	}, "next", nil)
+
+	// Call the function
+	result, err := ListGameConfigVersions(ctx, mockLogger, nil, nk, `{"limit":10}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"active_version": 1,
+		"versions": [
+			{"version": 1, "note": "launch", "published_at": 100, "active": true},
+			{"version": 2, "published_at": 200, "active": false}
+		],
+		"cursor": "next"
+	}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollbackGameConfig_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollbackGameConfig RPC called").Once()
+	mockLogger.On("Info", "Rolled back game configuration to version %d", 1).Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{
+		{Value: `{"active_version":2,"latest_version":2}`, Version: "v2"},
+	}, nil)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000001",
+	}}).Return([]*api.StorageObject{
+		{Value: `{"version":1,"schema_version":1,"config":{"xp_rate":1}}`},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 &&
+			writes[0].Key == common.StorageGameConfigActiveKey &&
+			writes[0].Version == "v2"
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := RollbackGameConfig(ctx, mockLogger, nil, nk, `{"version":1}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":"success","active_version":1}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollbackGameConfig_UnknownVersion(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollbackGameConfig RPC called").Once()
+	mockLogger.On("Error", "Game configuration version %d not found", 5).Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{
+		{Value: `{"active_version":2,"latest_version":2}`},
+	}, nil)
+
+	// Call the function
+	result, err := RollbackGameConfig(ctx, mockLogger, nil, nk, `{"version":5}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestLoadActiveGameConfig_FallsBackToEmbedded(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	version, err := loadActiveGameConfig(ctx, mockLogger, nk)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version.Version)
+	assert.Equal(t, "Welcome to Last Pagan Stronghold", version.Config.WelcomeMessage)
+	nk.AssertExpectations(t)
+}
+
+func TestBootstrapGameConfig_AlreadyPublished(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, gameConfigPointerRead).Return([]*api.StorageObject{
+		{Value: `{"active_version":1,"latest_version":1}`},
+	}, nil)
+
+	// Call the function
+	err := BootstrapGameConfig(ctx, mockLogger, nk)
+
+	// Assertions
+	assert.NoError(t, err)
+	nk.AssertNotCalled(t, "StorageWrite", mock.Anything, mock.Anything)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,488 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	ListInventoryRequest struct {
+		Rarity string `json:"rarity,omitempty"`
+		Limit  int    `json:"limit,omitempty"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+
+	ListInventoryResponse struct {
+		Items    []*common.ItemInstance `json:"items"`
+		Cursor   string                 `json:"cursor,omitempty"`
+		Count    int                    `json:"count"`
+		Capacity int                    `json:"capacity,omitempty"`
+	}
+
+	InspectItemRequest struct {
+		InstanceID string `json:"instance_id"`
+	}
+
+	InspectItemResponse struct {
+		Instance *common.ItemInstance `json:"instance"`
+		Item     *common.Item         `json:"item,omitempty"`
+		Affixes  []*InspectedAffix    `json:"affixes"`
+		Language string               `json:"language,omitempty"`
+	}
+
+	// InspectedAffix is an affix of an item instance described in the player's language.
+	InspectedAffix struct {
+		ID    string `json:"id"`
+		Stat  string `json:"stat"`
+		Value int    `json:"value"`
+		Text  string `json:"text,omitempty"`
+	}
+
+	DiscardItemRequest struct {
+		InstanceID string `json:"instance_id"`
+	}
+
+	DiscardItemResponse struct {
+		Discarded *common.ItemInstance `json:"discarded"`
+		Count     int                  `json:"count"`
+	}
+
+	LockItemRequest struct {
+		InstanceID string `json:"instance_id"`
+		Locked     bool   `json:"locked"`
+	}
+
+	LockItemResponse struct {
+		Instance *common.ItemInstance `json:"instance"`
+	}
+)
+
+const maxInventoryPageLimit = 100
+
+// newItemInstance creates an instance of the configured item for a player, rolling its stats from a new seed.
+func newItemInstance(config *common.GameConfig, configVersion int, derivation *gameConfigDerivation, item *common.Item, rarity, source string, now int64) (*common.ItemInstance, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+	seed, err := newItemSeed()
+	if err != nil {
+		return nil, err
+	}
+	stats := rollItemStats(config, item, rarity, seed)
+
+	instance := &common.ItemInstance{
+		ID:               id,
+		Item:             item.Name,
+		Rarity:           rarity,
+		Durability:       stats.MaxDurability,
+		Source:           source,
+		AcquiredAt:       now,
+		ConfigVersion:    configVersion,
+		LiveOpsOverrides: derivation.liveOpsOverrides(),
+		Seed:             hex.EncodeToString(seed),
+		Damage:           stats.Damage,
+		Defense:          stats.Defense,
+		MaxDurability:    stats.MaxDurability,
+		Affixes:          stats.Affixes,
+	}
+	if derivation != nil && len(derivation.Variants) > 0 {
+		instance.Variants = derivation.Variants
+	}
+
+	return instance, nil
+}
+
+// addInventoryItem adds the instance to the inventory unless it already holds the capacity of the configuration.
+func addInventoryItem(logger runtime.Logger, config *common.GameConfig, userID string, inventory *common.Inventory, instance *common.ItemInstance) error {
+	if capacity := inventoryCapacity(config); capacity > 0 && len(inventory.Items) >= capacity {
+		logger.Error("Inventory of user %s is full, capacity %d", userID, capacity)
+		return common.ErrInventoryFull
+	}
+
+	inventory.Items = append(inventory.Items, instance)
+	return nil
+}
+
+// inventoryCapacity returns the number of item instances a player can own, 0 if unlimited.
+func inventoryCapacity(config *common.GameConfig) int {
+	if config.Inventory == nil {
+		return 0
+	}
+	return config.Inventory.Capacity
+}
+
+// localizedItem returns the copy of the named item of the drop table in its localized copy, nil if there is none.
+func localizedItem(rarity, localized *common.Rarity, name string) *common.Item {
+	localizedTiers := rarityTiers(localized)
+	for i, tier := range rarityTiers(rarity) {
+		for j, item := range tier.Items.Items {
+			if item.Name == name {
+				localizedItem := localizedTiers[i].Items.Items[j]
+				return &localizedItem
+			}
+		}
+	}
+	return nil
+}
+
+// inventoryCursor returns the position of the instance in the inventory listing, ordered by acquisition. The listing
+// resumes after the position, so discarding the last listed instance does not invalidate the cursor.
+func inventoryCursor(instance *common.ItemInstance) string {
+	return fmt.Sprintf("%019d-%s", instance.AcquiredAt, instance.ID)
+}
+
+// newRandomID returns a random (version 4) UUID.
+func newRandomID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return common.EmptyString, err
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]), nil
+}
+
+// readInventory reads the inventory of the user along with its storage version, empty if the user owns no item yet.
+func readInventory(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) (*common.Inventory, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageInventory,
+		Key:        common.StorageInventoryItemsKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+
+	inventory := &common.Inventory{Items: []*common.ItemInstance{}}
+	if len(objects) == 0 {
+		return inventory, common.EmptyString, nil
+	}
+
+	if err = json.Unmarshal([]byte(objects[0].GetValue()), inventory); err != nil {
+		logger.Error("Cannot unmarshal inventory: %+v", err)
+		return nil, common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	return inventory, objects[0].GetVersion(), nil
+}
+
+// inventoryWrite builds the conditional write of the user's inventory, guarded by the storage version it was read at.
+func inventoryWrite(logger runtime.Logger, userID string, inventory *common.Inventory, storageVersion string) (*runtime.StorageWrite, error) {
+	inventoryJSON, err := json.Marshal(inventory)
+	if err != nil {
+		logger.Error("Cannot marshal inventory: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	if storageVersion == common.EmptyString {
+		storageVersion = "*"
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageInventory,
+		Key:             common.StorageInventoryItemsKey,
+		UserID:          userID,
+		Value:           string(inventoryJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_OWNER_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// writePlayerObjects writes objects owned by players in one transaction, mapping version check failures to a conflict.
+func writePlayerObjects(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, writes ...*runtime.StorageWrite) error {
+	if _, err := nk.StorageWrite(ctx, writes); err != nil {
+		if errors.Is(err, runtime.ErrStorageRejectedVersion) {
+			logger.Error("Player data was modified concurrently: %+v", err)
+			return common.ErrPlayerDataConflict
+		}
+		logger.Error("StorageWrite error: %+v", err)
+		return common.ErrInternalError
+	}
+
+	return nil
+}
+
+// updatePlayerData writes and deletes objects owned by players and updates their wallets in one transaction, mapping
+// version check failures to a conflict and wallets going negative to insufficient funds. The wallet changes are
+// recorded in the ledger.
+func updatePlayerData(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, writes []*runtime.StorageWrite, deletes []*runtime.StorageDelete, walletUpdates []*runtime.WalletUpdate) error {
+	if len(deletes) == 0 && len(walletUpdates) == 0 {
+		return writePlayerObjects(ctx, logger, nk, writes...)
+	}
+
+	if _, _, err := nk.MultiUpdate(ctx, nil, writes, deletes, walletUpdates, true); err != nil {
+		var negative *runtime.WalletNegativeError
+		if errors.As(err, &negative) {
+			logger.Error("Wallet of user %s cannot afford %d %s", negative.UserID, -negative.Amount, negative.Path)
+			return common.ErrInsufficientFunds
+		}
+		if errors.Is(err, runtime.ErrStorageRejectedVersion) {
+			logger.Error("Player data was modified concurrently: %+v", err)
+			return common.ErrPlayerDataConflict
+		}
+		logger.Error("MultiUpdate error: %+v", err)
+		return common.ErrInternalError
+	}
+
+	return nil
+}
+
+// ListInventory pages through the item instances of the caller, oldest first, optionally of a single rarity tier.
+func ListInventory(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ListInventory RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req ListInventoryRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+	if req.Limit <= 0 || req.Limit > maxInventoryPageLimit {
+		req.Limit = maxInventoryPageLimit
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	if req.Rarity != common.EmptyString && !slices.ContainsFunc(rarityTiers(&config.Rarity), func(tier rarityTier) bool {
+		return tier.Name == req.Rarity
+	}) {
+		logger.Error("Unknown rarity tier %s", req.Rarity)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, _, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	items := slices.Clone(inventory.Items)
+	slices.SortFunc(items, func(a, b *common.ItemInstance) int {
+		return strings.Compare(inventoryCursor(a), inventoryCursor(b))
+	})
+
+	resp := &ListInventoryResponse{
+		Items:    []*common.ItemInstance{},
+		Count:    len(inventory.Items),
+		Capacity: inventoryCapacity(config),
+	}
+	for _, instance := range items {
+		if req.Cursor != common.EmptyString && inventoryCursor(instance) <= req.Cursor {
+			continue
+		}
+		if req.Rarity != common.EmptyString && instance.Rarity != req.Rarity {
+			continue
+		}
+		if len(resp.Items) == req.Limit {
+			resp.Cursor = inventoryCursor(resp.Items[len(resp.Items)-1])
+			break
+		}
+		resp.Items = append(resp.Items, instance)
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// InspectItem returns an item instance of the caller along with its item and affixes described in their language.
+func InspectItem(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("InspectItem RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req InspectItemRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.InstanceID == common.EmptyString {
+		logger.Error("Payload did not contain an instance ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, _, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return instance.ID == req.InstanceID
+	})
+	if index < 0 {
+		logger.Error("Item instance %s of user %s not found", req.InstanceID, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	instance := inventory.Items[index]
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+	localized, language, err := localizeUserGameConfig(ctx, logger, nk, userID, config)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	// The item may have been removed from the configuration since the instance dropped
+	resp := &InspectItemResponse{Instance: instance, Affixes: []*InspectedAffix{}, Language: language}
+	resp.Item = localizedItem(&config.Rarity, &localized.Rarity, instance.Item)
+
+	var pool []common.Affix
+	if resp.Item != nil {
+		pool = localized.AffixPools[resp.Item.AffixPool]
+	}
+	for _, rolled := range instance.Affixes {
+		inspected := &InspectedAffix{ID: rolled.ID, Stat: rolled.Stat, Value: rolled.Value}
+		if i := slices.IndexFunc(pool, func(affix common.Affix) bool { return affix.ID == rolled.ID }); i >= 0 {
+			inspected.Text = strings.ReplaceAll(pool[i].Text, "{value}", strconv.Itoa(rolled.Value))
+		}
+		resp.Affixes = append(resp.Affixes, inspected)
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// DiscardItem removes an item instance from the caller's inventory.
+func DiscardItem(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("DiscardItem RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req DiscardItemRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.InstanceID == common.EmptyString {
+		logger.Error("Payload did not contain an instance ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return instance.ID == req.InstanceID
+	})
+	if index < 0 {
+		logger.Error("Item instance %s of user %s not found", req.InstanceID, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	discarded := inventory.Items[index]
+	if equippedInstanceIDs(inventory)[discarded.ID] {
+		logger.Error("Item instance %s of user %s is equipped", discarded.ID, userID)
+		return common.EmptyString, common.ErrItemEquipped
+	}
+	if discarded.Locked {
+		logger.Error("Item instance %s of user %s is locked", discarded.ID, userID)
+		return common.EmptyString, common.ErrItemLocked
+	}
+	inventory.Items = slices.Delete(inventory.Items, index, index+1)
+
+	// The write is guarded by the version the inventory was read at, so an item granted concurrently is not lost
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writePlayerObjects(ctx, logger, nk, write); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s discarded item instance %s of %s", userID, discarded.ID, discarded.Item)
+
+	return marshalResponse(logger, &DiscardItemResponse{Discarded: discarded, Count: len(inventory.Items)})
+}
+
+// LockItem locks an item instance of the caller, protecting it from being discarded, salvaged or consumed by recipes,
+// or unlocks it.
+func LockItem(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("LockItem RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req LockItemRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.InstanceID == common.EmptyString {
+		logger.Error("Payload did not contain an instance ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return instance.ID == req.InstanceID
+	})
+	if index < 0 {
+		logger.Error("Item instance %s of user %s not found", req.InstanceID, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	instance := inventory.Items[index]
+	if instance.Locked == req.Locked {
+		return marshalResponse(logger, &LockItemResponse{Instance: instance})
+	}
+	instance.Locked = req.Locked
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writePlayerObjects(ctx, logger, nk, write); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s set the lock of item instance %s to %t", userID, instance.ID, instance.Locked)
+
+	return marshalResponse(logger, &LockItemResponse{Instance: instance})
+}
@@ -0,0 +1,303 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// storedInventory returns the storage object of an inventory holding the instances.
+func storedInventory(t *testing.T, instances ...*common.ItemInstance) []*api.StorageObject {
+	inventoryJSON, err := json.Marshal(&common.Inventory{Items: instances})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return []*api.StorageObject{{Value: string(inventoryJSON), Version: "v1"}}
+}
+
+func TestAddInventoryItem_Capacity(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Error", "Inventory of user %s is full, capacity %d", "user123", 2).Once()
+
+	config := &common.GameConfig{Inventory: &common.InventorySettings{Capacity: 2}}
+	inventory := &common.Inventory{Items: []*common.ItemInstance{{ID: "a"}}}
+
+	// Call the function
+	first := addInventoryItem(mockLogger, config, "user123", inventory, &common.ItemInstance{ID: "b"})
+	second := addInventoryItem(mockLogger, config, "user123", inventory, &common.ItemInstance{ID: "c"})
+
+	// Assertions
+	assert.NoError(t, first)
+	assert.Equal(t, common.ErrInventoryFull, second)
+	assert.Len(t, inventory.Items, 2)
+	assert.NoError(t, addInventoryItem(mockLogger, &common.GameConfig{}, "user123", inventory, &common.ItemInstance{ID: "c"}))
+	mockLogger.AssertExpectations(t)
+}
+
+func TestRollLoot_InventoryFull(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Error", "Inventory of user %s is full, capacity %d", "user123", 1).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	config := embeddedGameConfig(t)
+	config.Inventory.Capacity = 1
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+	mockLootRandom(t, 0.1, 0.1)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, pityCountersRead(userID)).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t, &common.ItemInstance{ID: "a"}), nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInventoryFull, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestListInventory_PagesByRarity(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ListInventory RPC called").Times(2)
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	instances := []*common.ItemInstance{
+		{ID: "d", Item: "Iron Sword", Rarity: "uncommon", AcquiredAt: 40},
+		{ID: "a", Item: "Wooden Sword", Rarity: "common", AcquiredAt: 10},
+		{ID: "c", Item: "Leather Armor", Rarity: "common", AcquiredAt: 30},
+		{ID: "b", Item: "Iron Shield", Rarity: "uncommon", AcquiredAt: 20},
+		{ID: "e", Item: "Wooden Sword", Rarity: "common", AcquiredAt: 50},
+	}
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t, instances...), nil)
+
+	// Call the function
+	first, err := ListInventory(ctx, mockLogger, nil, nk, `{"rarity":"common","limit":2}`)
+	assert.NoError(t, err)
+	var firstPage ListInventoryResponse
+	assert.NoError(t, json.Unmarshal([]byte(first), &firstPage))
+
+	second, err := ListInventory(ctx, mockLogger, nil, nk, `{"rarity":"common","limit":2,"cursor":"`+firstPage.Cursor+`"}`)
+	assert.NoError(t, err)
+	var secondPage ListInventoryResponse
+	assert.NoError(t, json.Unmarshal([]byte(second), &secondPage))
+
+	// Assertions
+	assert.Equal(t, []*common.ItemInstance{instances[1], instances[2]}, firstPage.Items)
+	assert.Equal(t, "0000000000000000030-c", firstPage.Cursor)
+	assert.Equal(t, 5, firstPage.Count)
+	assert.Equal(t, 200, firstPage.Capacity)
+	assert.Equal(t, []*common.ItemInstance{instances[4]}, secondPage.Items)
+	assert.Empty(t, secondPage.Cursor)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestListInventory_UnknownRarity(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ListInventory RPC called").Once()
+	mockLogger.On("Error", "Unknown rarity tier %s", "mythic").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := ListInventory(ctx, mockLogger, nil, nk, `{"rarity":"mythic"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestInspectItem_DescribesAffixes(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "InspectItem RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	instance := &common.ItemInstance{ID: "a", Item: "Steel Sword", Rarity: "rare", Damage: 40, Affixes: []common.ItemAffix{
+		{ID: "fiery", Stat: "fire_damage", Value: 12},
+		{ID: "removed", Stat: "luck", Value: 1},
+	}}
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t, instance), nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("AccountGetId", ctx, userID).Return(&api.Account{User: &api.User{Id: userID, LangTag: "es"}}, nil)
+
+	// Call the function
+	result, err := InspectItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp InspectItemResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, instance, resp.Instance)
+	assert.Equal(t, "Espada de acero", resp.Item.Name)
+	assert.Equal(t, "es", resp.Language)
+	assert.Equal(t, []*InspectedAffix{
+		{ID: "fiery", Stat: "fire_damage", Value: 12, Text: "+12% de daño de fuego"},
+		{ID: "removed", Stat: "luck", Value: 1},
+	}, resp.Affixes)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDiscardItem_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiscardItem RPC called").Once()
+	mockLogger.On("Info", "User %s discarded item instance %s of %s", "user123", "a", "Wooden Sword").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t,
+		&common.ItemInstance{ID: "a", Item: "Wooden Sword"},
+		&common.ItemInstance{ID: "b", Item: "Iron Sword"},
+	), nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].Version == "v1" &&
+			len(inventory.Items) == 1 && inventory.Items[0].ID == "b"
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := DiscardItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp DiscardItemResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "a", resp.Discarded.ID)
+	assert.Equal(t, 1, resp.Count)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDiscardItem_Conflict(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiscardItem RPC called").Once()
+	mockLogger.On("Error", "Player data was modified concurrently: %+v", runtime.ErrStorageRejectedVersion).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t, &common.ItemInstance{ID: "a"}), nil)
+	nk.On("StorageWrite", ctx, mock.Anything).Return(nil, runtime.ErrStorageRejectedVersion)
+
+	// Call the function
+	result, err := DiscardItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrPlayerDataConflict, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDiscardItem_NotFound(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiscardItem RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s not found", "missing", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := DiscardItem(ctx, mockLogger, nil, nk, `{"instance_id":"missing"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDiscardItem_Locked(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DiscardItem RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s is locked", "a", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t, &common.ItemInstance{ID: "a", Locked: true}), nil)
+
+	// Call the function
+	result, err := DiscardItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrItemLocked, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestLockItem_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "LockItem RPC called").Once()
+	mockLogger.On("Info", "User %s set the lock of item instance %s to %t", "user123", "a", true).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t, &common.ItemInstance{ID: "a"}), nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].Version == "v1" && inventory.Items[0].Locked
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := LockItem(ctx, mockLogger, nil, nk, `{"instance_id":"a","locked":true}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp LockItemResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.True(t, resp.Instance.Locked)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,287 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	ApplyDurabilityLossRequest struct {
+		UserID string `json:"user_id"`
+		// Durability lost by instance ID
+		Losses map[string]int `json:"losses"`
+	}
+
+	ApplyDurabilityLossResponse struct {
+		Items []*common.ItemInstance `json:"items"`
+	}
+
+	RepairItemRequest struct {
+		InstanceID string `json:"instance_id"`
+	}
+
+	RepairItemResponse struct {
+		Instance *common.ItemInstance `json:"instance"`
+		Currency string               `json:"currency"`
+		Cost     int64                `json:"cost"`
+	}
+
+	// CombatStats are the stats the item instances of a player add up to in combat: their damage and defense,
+	// including the affixes raising them, and the sum of every other affix stat.
+	CombatStats struct {
+		Damage  int            `json:"damage"`
+		Defense int            `json:"defense"`
+		Stats   map[string]int `json:"stats"`
+	}
+)
+
+// WearItems wears the item instances of the player by the durability lost by each, e.g. from a match result, and
+// marks the instances left without durability as broken. The inventory is written conditionally on the version it was
+// read at, so a concurrent change fails with a conflict to retry. It returns the worn instances.
+func WearItems(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, losses map[string]int) ([]*common.ItemInstance, error) {
+	for instanceID, loss := range losses {
+		if loss <= 0 {
+			logger.Error("Durability loss of item instance %s must be positive, got %d", instanceID, loss)
+			return nil, common.ErrInvalidArgument
+		}
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	worn := make([]*common.ItemInstance, 0, len(losses))
+	for _, instance := range inventory.Items {
+		loss, ok := losses[instance.ID]
+		if !ok {
+			continue
+		}
+		instance.MaxDurability = itemMaxDurability(instance)
+		instance.Durability = max(instance.Durability-loss, 0)
+		if instance.Durability == 0 && !instance.Broken {
+			instance.Broken = true
+			logger.Info("Item instance %s of user %s broke", instance.ID, userID)
+		}
+		worn = append(worn, instance)
+	}
+	if len(worn) < len(losses) {
+		missing := make([]string, 0, len(losses))
+		for instanceID := range losses {
+			if !slices.ContainsFunc(worn, func(instance *common.ItemInstance) bool { return instance.ID == instanceID }) {
+				missing = append(missing, instanceID)
+			}
+		}
+		sort.Strings(missing)
+		logger.Error("Item instances %s of user %s not found", strings.Join(missing, ", "), userID)
+		return nil, common.ErrNotFound
+	}
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err = writePlayerObjects(ctx, logger, nk, write); err != nil {
+		return nil, err
+	}
+
+	return worn, nil
+}
+
+// ApplyDurabilityLoss wears item instances of a player on behalf of a trusted server.
+func ApplyDurabilityLoss(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ApplyDurabilityLoss RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req ApplyDurabilityLossRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString || len(req.Losses) == 0 {
+		logger.Error("Payload did not contain a user ID and durability losses")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	items, err := WearItems(ctx, logger, nk, req.UserID, req.Losses)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, &ApplyDurabilityLossResponse{Items: items})
+}
+
+// RepairItem restores the durability of an item instance of the caller, paid from their wallet.
+func RepairItem(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("RepairItem RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req RepairItemRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.InstanceID == common.EmptyString {
+		logger.Error("Payload did not contain an instance ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if config.Repair == nil {
+		logger.Error("Repairs are not configured")
+		return common.EmptyString, common.ErrRepairUnavailable
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return instance.ID == req.InstanceID
+	})
+	if index < 0 {
+		logger.Error("Item instance %s of user %s not found", req.InstanceID, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	instance := inventory.Items[index]
+	if instance.Durability >= itemMaxDurability(instance) {
+		logger.Error("Item instance %s of user %s is not damaged", instance.ID, userID)
+		return common.EmptyString, common.ErrItemNotDamaged
+	}
+
+	cost := repairCost(config.Repair, instance)
+	instance.Durability = instance.MaxDurability
+	instance.Broken = false
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	var walletUpdates []*runtime.WalletUpdate
+	if cost > 0 {
+		walletUpdates = append(walletUpdates, &runtime.WalletUpdate{
+			UserID:    userID,
+			Changeset: map[string]int64{config.Repair.Currency: -cost},
+			Metadata:  map[string]interface{}{"source": common.WalletSourceRepair, "instance_id": instance.ID},
+		})
+	}
+
+	// The item is only repaired if the wallet can afford it, both change in one transaction
+	if err = updatePlayerData(ctx, logger, nk, []*runtime.StorageWrite{write}, nil, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s repaired item instance %s for %d %s", userID, instance.ID, cost, config.Repair.Currency)
+
+	return marshalResponse(logger, &RepairItemResponse{
+		Instance: instance,
+		Currency: config.Repair.Currency,
+		Cost:     cost,
+	})
+}
+
+// itemMaxDurability returns the maximum durability of the instance. Instances granted before stats were rolled only
+// store their durability, which was then at its maximum.
+func itemMaxDurability(instance *common.ItemInstance) int {
+	if instance.MaxDurability == 0 {
+		return instance.Durability
+	}
+	return instance.MaxDurability
+}
+
+// repairCost returns the price of restoring the full durability of the instance.
+func repairCost(repair *common.RepairSettings, instance *common.ItemInstance) int64 {
+	missing := float64(instance.MaxDurability - instance.Durability)
+	cost := repair.BaseCost + repair.CostPerPoint*missing
+
+	if multiplier, ok := repair.RarityMultipliers[instance.Rarity]; ok {
+		cost *= multiplier
+	}
+	if instance.Broken && repair.BrokenMultiplier != 0 {
+		cost *= repair.BrokenMultiplier
+	}
+
+	return int64(math.Ceil(cost))
+}
+
+// repairViolations returns the semantic violations of the repair settings.
+func repairViolations(config *common.GameConfig) []*common.ConfigViolation {
+	if config.Repair == nil {
+		return nil
+	}
+
+	var violations configViolations
+	nonNegative := func(path string, value float64) {
+		if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			violations.add(path, "must be a non-negative number, got %v", value)
+		}
+	}
+
+	repair := config.Repair
+	if strings.TrimSpace(repair.Currency) == common.EmptyString {
+		violations.add("repair.currency", "must not be empty")
+	}
+	nonNegative("repair.base_cost", repair.BaseCost)
+	nonNegative("repair.cost_per_point", repair.CostPerPoint)
+	nonNegative("repair.broken_multiplier", repair.BrokenMultiplier)
+
+	for _, tier := range sortedKeys(repair.RarityMultipliers) {
+		path := "repair.rarity_multipliers." + tier
+		if !slices.ContainsFunc(rarityTiers(&config.Rarity), func(rarityTier rarityTier) bool { return rarityTier.Name == tier }) {
+			violations.add(path, "references the unknown rarity tier %q", tier)
+		}
+		nonNegative(path, repair.RarityMultipliers[tier])
+	}
+
+	return violations
+}
+
+// combatStats adds up the stats of the item instances. Broken instances add nothing until repaired.
+func combatStats(instances []*common.ItemInstance) *CombatStats {
+	stats := &CombatStats{Stats: make(map[string]int)}
+	for _, instance := range instances {
+		if instance.Broken {
+			continue
+		}
+
+		stats.Damage += instance.Damage
+		stats.Defense += instance.Defense
+		for _, affix := range instance.Affixes {
+			switch affix.Stat {
+			case "damage":
+				stats.Damage += affix.Value
+			case "defense":
+				stats.Defense += affix.Value
+			default:
+				stats.Stats[affix.Stat] += affix.Value
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,266 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func TestWearItems_BreaksItemsAtZero(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Info", "Item instance %s of user %s broke", "b", "user123").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "a", Durability: 100, MaxDurability: 150},
+		&common.ItemInstance{ID: "b", Durability: 10},
+		&common.ItemInstance{ID: "c", Durability: 50, MaxDurability: 50},
+	), nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].Version == "v1"
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	worn, err := WearItems(ctx, mockLogger, nk, "user123", map[string]int{"a": 30, "b": 25})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []*common.ItemInstance{
+		{ID: "a", Durability: 70, MaxDurability: 150},
+		{ID: "b", Durability: 0, MaxDurability: 10, Broken: true},
+	}, worn)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestWearItems_UnknownInstance(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Error", "Item instances %s of user %s not found", "x, y", "user123").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t, &common.ItemInstance{ID: "a", Durability: 10}), nil)
+
+	// Call the function
+	worn, err := WearItems(ctx, mockLogger, nk, "user123", map[string]int{"a": 1, "x": 1, "y": 1})
+
+	// Assertions
+	assert.Nil(t, worn)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestApplyDurabilityLoss_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ApplyDurabilityLoss RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ApplyDurabilityLoss(ctx, mockLogger, nil, nil, `{"user_id":"user123","losses":{"a":1}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestApplyDurabilityLoss_InvalidLoss(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ApplyDurabilityLoss RPC called").Once()
+	mockLogger.On("Error", "Durability loss of item instance %s must be positive, got %d", "a", -5).Once()
+
+	// Call the function
+	result, err := ApplyDurabilityLoss(context.Background(), mockLogger, nil, nil, `{"user_id":"user123","losses":{"a":-5}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestItemMaxDurability(t *testing.T) {
+	tests := []struct {
+		name          string
+		instance      *common.ItemInstance
+		maxDurability int
+	}{
+		{name: "rolled instance", instance: &common.ItemInstance{Durability: 40, MaxDurability: 150}, maxDurability: 150},
+		{name: "legacy instance", instance: &common.ItemInstance{Durability: 100}, maxDurability: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the function and assert
+			assert.Equal(t, tt.maxDurability, itemMaxDurability(tt.instance))
+		})
+	}
+}
+
+func TestRepairCost(t *testing.T) {
+	repair := &common.RepairSettings{
+		Currency:          "gold",
+		BaseCost:          5,
+		CostPerPoint:      0.2,
+		RarityMultipliers: map[string]float64{"rare": 2.5},
+		BrokenMultiplier:  1.5,
+	}
+
+	tests := []struct {
+		name     string
+		instance *common.ItemInstance
+		cost     int64
+	}{
+		{name: "common", instance: &common.ItemInstance{Rarity: "common", Durability: 90, MaxDurability: 100}, cost: 7},
+		{name: "rare", instance: &common.ItemInstance{Rarity: "rare", Durability: 90, MaxDurability: 100}, cost: 18},
+		{name: "broken rare", instance: &common.ItemInstance{Rarity: "rare", MaxDurability: 250, Broken: true}, cost: 207},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the function and assert
+			assert.Equal(t, tt.cost, repairCost(repair, tt.instance))
+		})
+	}
+}
+
+func TestRepairViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Repair = &common.RepairSettings{
+		CostPerPoint:      -1,
+		RarityMultipliers: map[string]float64{"mythic": 2, "rare": -1},
+	}
+
+	// Call the function
+	violations := repairViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "repair.currency", Message: "must not be empty"},
+		{Path: "repair.cost_per_point", Message: "must be a non-negative number, got -1"},
+		{Path: "repair.rarity_multipliers.mythic", Message: `references the unknown rarity tier "mythic"`},
+		{Path: "repair.rarity_multipliers.rare", Message: "must be a non-negative number, got -1"},
+	}, violations)
+}
+
+func TestCombatStats_ExcludesBrokenItems(t *testing.T) {
+	// Call the function
+	stats := combatStats([]*common.ItemInstance{
+		{Damage: 40, Affixes: []common.ItemAffix{{Stat: "damage", Value: 5}, {Stat: "fire_damage", Value: 10}}},
+		{Defense: 30, Affixes: []common.ItemAffix{{Stat: "defense", Value: 3}}},
+		{Damage: 100, Broken: true, Affixes: []common.ItemAffix{{Stat: "fire_damage", Value: 15}}},
+	})
+
+	// Assertions
+	assert.Equal(t, &CombatStats{Damage: 45, Defense: 33, Stats: map[string]int{"fire_damage": 10}}, stats)
+}
+
+func TestRepairItem_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RepairItem RPC called").Once()
+	mockLogger.On("Info", "User %s repaired item instance %s for %d %s", "user123", "a", int64(207), "gold").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t,
+		&common.ItemInstance{ID: "a", Item: "Steel Sword", Rarity: "rare", MaxDurability: 250, Broken: true},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].Version == "v1" &&
+			inventory.Items[0].Durability == 250 && !inventory.Items[0].Broken
+	}), []*runtime.StorageDelete(nil), []*runtime.WalletUpdate{{
+		UserID:    userID,
+		Changeset: map[string]int64{"gold": -207},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceRepair, "instance_id": "a"},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := RepairItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RepairItemResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, int64(207), resp.Cost)
+	assert.Equal(t, "gold", resp.Currency)
+	assert.False(t, resp.Instance.Broken)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRepairItem_InsufficientFunds(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RepairItem RPC called").Once()
+	mockLogger.On("Error", "Wallet of user %s cannot afford %d %s", "user123", int64(7), "gold").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t,
+		&common.ItemInstance{ID: "a", Rarity: "common", Durability: 90, MaxDurability: 100},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.Anything, []*runtime.StorageDelete(nil), mock.Anything, true).
+		Return(nil, nil, &runtime.WalletNegativeError{UserID: userID, Path: "gold", Current: 3, Amount: -7})
+
+	// Call the function
+	result, err := RepairItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInsufficientFunds, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRepairItem_NotDamaged(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RepairItem RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s is not damaged", "a", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t,
+		&common.ItemInstance{ID: "a", Durability: 100, MaxDurability: 100},
+	), nil)
+
+	// Call the function
+	result, err := RepairItem(ctx, mockLogger, nil, nk, `{"instance_id":"a"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrItemNotDamaged, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
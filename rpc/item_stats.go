@@ -0,0 +1,361 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type (
+	// ItemStats are the stats of an item instance rolled from its seed.
+	ItemStats struct {
+		Damage        int                `json:"damage"`
+		Defense       int                `json:"defense"`
+		MaxDurability int                `json:"max_durability"`
+		Affixes       []common.ItemAffix `json:"affixes"`
+	}
+
+	RederiveItemStatsRequest struct {
+		UserID     string `json:"user_id"`
+		InstanceID string `json:"instance_id"`
+	}
+
+	RederiveItemStatsResponse struct {
+		Instance   *common.ItemInstance `json:"instance"`
+		Rederived  *ItemStats           `json:"rederived,omitempty"`
+		Matches    bool                 `json:"matches"`
+		Mismatches []string             `json:"mismatches,omitempty"`
+	}
+)
+
+// newItemSeed returns the seed the stats of a new item instance are rolled from.
+var newItemSeed = newLootSeed
+
+// rollItemStats rolls the stats of an instance of the item from the seed: its damage, defense and durability within
+// their ranges, the number of affixes allowed by its rarity tier, then every affix, picked from the affix pool of the
+// item weighted by the affix weights without repeats, and its value. Every step takes one draw, in that order, so the
+// stats can be derived again from the same seed and configuration.
+func rollItemStats(config *common.GameConfig, item *common.Item, tier string, seed []byte) *ItemStats {
+	random := newSeededLootRandom(seed)
+	stats := &ItemStats{
+		Damage:        rollIntRange(item.Damage, item.DamageRange, random.Float64()),
+		Defense:       rollIntRange(item.Defense, item.DefenseRange, random.Float64()),
+		MaxDurability: rollIntRange(item.Durability, item.DurabilityRange, random.Float64()),
+		Affixes:       []common.ItemAffix{},
+	}
+
+	var affixCount *common.IntRange
+	for _, rarityTier := range rarityTiers(&config.Rarity) {
+		if rarityTier.Name == tier {
+			affixCount = rarityTier.Items.Affixes
+		}
+	}
+	count := rollIntRange(0, affixCount, random.Float64())
+
+	pool := slices.Clone(config.AffixPools[item.AffixPool])
+	for len(stats.Affixes) < count && len(pool) > 0 {
+		weights := make([]float64, len(pool))
+		for i, affix := range pool {
+			weights[i] = affixWeight(&affix)
+		}
+		i := pickWeighted(weights, random.Float64())
+		affix := pool[i]
+		stats.Affixes = append(stats.Affixes, common.ItemAffix{
+			ID:    affix.ID,
+			Stat:  affix.Stat,
+			Value: rollIntRange(0, &common.IntRange{Min: affix.Min, Max: affix.Max}, random.Float64()),
+		})
+		pool = slices.Delete(pool, i, i+1)
+	}
+
+	return stats
+}
+
+// rollIntRange returns the value the draw in [0, 1) falls on within the range, or the fixed value without a range.
+func rollIntRange(fixed int, r *common.IntRange, draw float64) int {
+	if r == nil {
+		return fixed
+	}
+	span := r.Max - r.Min + 1
+	return r.Min + min(int(draw*float64(span)), span-1)
+}
+
+// affixWeight returns the relative weight of the affix within its pool.
+func affixWeight(affix *common.Affix) float64 {
+	if affix.Weight == 0 {
+		return 1
+	}
+	return affix.Weight
+}
+
+// affixViolations returns the semantic violations of the affix pools and of the affix settings of the items and tiers.
+func affixViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+
+	pools := make([]string, 0, len(config.AffixPools))
+	for pool := range config.AffixPools {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+
+	for _, pool := range pools {
+		poolPath := "affix_pools." + pool
+		if len(config.AffixPools[pool]) == 0 {
+			violations.add(poolPath, "must not be empty")
+		}
+
+		ids := make(map[string]bool)
+		for i, affix := range config.AffixPools[pool] {
+			affixPath := fmt.Sprintf("%s[%d]", poolPath, i)
+
+			switch {
+			case strings.TrimSpace(affix.ID) == common.EmptyString:
+				violations.add(affixPath+".id", "must not be empty")
+			case ids[affix.ID]:
+				violations.add(affixPath+".id", "duplicates the affix ID %q", affix.ID)
+			default:
+				ids[affix.ID] = true
+			}
+
+			if strings.TrimSpace(affix.Stat) == common.EmptyString {
+				violations.add(affixPath+".stat", "must not be empty")
+			}
+			if strings.TrimSpace(affix.Text) == common.EmptyString && affix.TextID == common.EmptyString {
+				violations.add(affixPath+".text", "must not be empty")
+			}
+			if affix.Min > affix.Max {
+				violations.add(affixPath+".max", "must not be less than min %d, got %d", affix.Min, affix.Max)
+			}
+			if affix.Weight < 0 || math.IsNaN(affix.Weight) || math.IsInf(affix.Weight, 0) {
+				violations.add(affixPath+".weight", "must be a non-negative number, got %v", affix.Weight)
+			}
+		}
+	}
+
+	for _, tier := range rarityTiers(&config.Rarity) {
+		tierPath := "rarity." + tier.Name
+		if count := tier.Items.Affixes; count != nil && (count.Min < 0 || count.Min > count.Max) {
+			violations.add(tierPath+".affixes", "must be a range of non-negative counts, got %d to %d", count.Min, count.Max)
+		}
+
+		for i, item := range tier.Items.Items {
+			if item.AffixPool == common.EmptyString {
+				continue
+			}
+			if _, ok := config.AffixPools[item.AffixPool]; !ok {
+				violations.add(fmt.Sprintf("%s.items[%d].affix_pool", tierPath, i), "references the unknown affix pool %q", item.AffixPool)
+			}
+		}
+	}
+
+	return violations
+}
+
+// itemStatViolations returns the violations of the stats of an item, each either fixed or rolled within a range.
+func itemStatViolations(itemPath string, item *common.Item) []*common.ConfigViolation {
+	var violations configViolations
+
+	stats := []struct {
+		name     string
+		fixed    int
+		r        *common.IntRange
+		positive bool
+	}{
+		{name: "damage", fixed: item.Damage, r: item.DamageRange},
+		{name: "defense", fixed: item.Defense, r: item.DefenseRange},
+		{name: "durability", fixed: item.Durability, r: item.DurabilityRange, positive: true},
+	}
+	for _, stat := range stats {
+		least := 0
+		if stat.positive {
+			least = 1
+		}
+
+		if stat.r == nil {
+			if stat.positive && stat.fixed < least {
+				violations.add(itemPath+"."+stat.name, "must be positive, got %d", stat.fixed)
+			} else if stat.fixed < least {
+				violations.add(itemPath+"."+stat.name, "must not be negative, got %d", stat.fixed)
+			}
+			continue
+		}
+
+		if stat.fixed != 0 {
+			violations.add(itemPath+"."+stat.name, "must not be set along with %s_range", stat.name)
+		}
+		if stat.r.Min < least || stat.r.Min > stat.r.Max {
+			violations.add(itemPath+"."+stat.name+"_range", "must range from at least %d to at least min, got %d to %d", least, stat.r.Min, stat.r.Max)
+		}
+	}
+
+	return violations
+}
+
+// itemMaxStat returns the highest value a stat of the instances of an item can roll.
+func itemMaxStat(fixed int, r *common.IntRange) int {
+	if r == nil {
+		return fixed
+	}
+	return r.Max
+}
+
+// RederiveItemStats rolls the stats of an item instance of a player again from its seed and the item template of the
+// configuration version it dropped with, and checks they are the stored ones.
+func RederiveItemStats(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("RederiveItemStats RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req RederiveItemStatsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString || req.InstanceID == common.EmptyString {
+		logger.Error("Payload did not contain a user ID and an instance ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	inventory, _, err := readInventory(ctx, logger, nk, req.UserID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return instance.ID == req.InstanceID
+	})
+	if index < 0 {
+		logger.Error("Item instance %s of user %s not found", req.InstanceID, req.UserID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	instance := inventory.Items[index]
+
+	config, missing, err := itemInstanceGameConfig(ctx, logger, nk, instance)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	resp := &RederiveItemStatsResponse{Instance: instance}
+	if len(missing) > 0 {
+		for _, id := range missing {
+			resp.Mismatches = append(resp.Mismatches, fmt.Sprintf("live_ops: override %s no longer exists", id))
+		}
+	} else {
+		resp.Rederived, resp.Mismatches = rederiveItemStats(config, instance)
+	}
+	resp.Matches = len(resp.Mismatches) == 0
+
+	logger.Info("Rederived the stats of item instance %s of user %s, matches: %t", instance.ID, req.UserID, resp.Matches)
+
+	return marshalResponse(logger, resp)
+}
+
+// itemInstanceGameConfig derives again the configuration the stats of the instance were rolled from: its configuration
+// version with the experiment variants and the live-ops overrides recorded on the instance applied. Deleted overrides
+// are read from the archive. It also returns the recorded overrides found in neither, deleted before they were
+// archived, in which case the configuration cannot be derived again.
+func itemInstanceGameConfig(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, instance *common.ItemInstance) (*common.GameConfig, []string, error) {
+	source := &GameConfigSource{Version: instance.ConfigVersion}
+	if instance.ConfigVersion == 0 {
+		source = &GameConfigSource{Embedded: true}
+	}
+	config, _, err := readGameConfigSource(ctx, logger, nk, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err = applyExperimentVariants(config, instance.Variants)
+	if err != nil {
+		logger.Error("Cannot apply experiment variants to game configuration version %d: %+v", instance.ConfigVersion, err)
+		return nil, nil, common.ErrInternalError
+	}
+	if len(instance.LiveOpsOverrides) == 0 {
+		return config, nil, nil
+	}
+
+	overrides, _, err := readLiveOpsOverrides(ctx, logger, nk)
+	if err != nil {
+		return nil, nil, err
+	}
+	var applied []*common.LiveOpsOverride
+	var deleted []string
+	for _, id := range instance.LiveOpsOverrides {
+		if index := findLiveOpsOverride(overrides.Overrides, id); index >= 0 {
+			applied = append(applied, overrides.Overrides[index])
+		} else {
+			deleted = append(deleted, id)
+		}
+	}
+
+	var missing []string
+	if len(deleted) > 0 {
+		archived, err := readArchivedLiveOpsOverrides(ctx, logger, nk, deleted)
+		if err != nil {
+			return nil, nil, err
+		}
+		applied = append(applied, archived...)
+		for _, id := range deleted {
+			if findLiveOpsOverride(archived, id) < 0 {
+				missing = append(missing, id)
+			}
+		}
+	}
+	sortLiveOpsOverrides(applied)
+
+	config, err = mergeLiveOpsOverrides(config, applied)
+	if err != nil {
+		logger.Error("Cannot apply live-ops overrides to game configuration version %d: %+v", instance.ConfigVersion, err)
+		return nil, nil, common.ErrInternalError
+	}
+
+	return config, missing, nil
+}
+
+// rederiveItemStats rolls the stats of the instance again and returns them along with their differences with the
+// stored ones.
+func rederiveItemStats(config *common.GameConfig, instance *common.ItemInstance) (*ItemStats, []string) {
+	seed, err := hex.DecodeString(instance.Seed)
+	if err != nil || len(seed) == 0 {
+		return nil, []string{"seed: instance has no valid seed"}
+	}
+	item, tier := lootItem(&config.Rarity, instance.Item)
+	if item == nil {
+		return nil, []string{fmt.Sprintf("item: %s is not in configuration version %d", instance.Item, instance.ConfigVersion)}
+	}
+
+	var mismatches []string
+	mismatch := func(format string, args ...any) {
+		mismatches = append(mismatches, fmt.Sprintf(format, args...))
+	}
+
+	stats := rollItemStats(config, item, tier, seed)
+	if tier != instance.Rarity {
+		mismatch("rarity: stored %s, rederived %s", instance.Rarity, tier)
+	}
+	// Upgrades add to the rolled stats
+	if stats.Damage+instance.UpgradeDamage != instance.Damage {
+		mismatch("damage: stored %d, rederived %d", instance.Damage, stats.Damage+instance.UpgradeDamage)
+	}
+	if stats.Defense+instance.UpgradeDefense != instance.Defense {
+		mismatch("defense: stored %d, rederived %d", instance.Defense, stats.Defense+instance.UpgradeDefense)
+	}
+	if stats.MaxDurability != instance.MaxDurability {
+		mismatch("max_durability: stored %d, rederived %d", instance.MaxDurability, stats.MaxDurability)
+	}
+	if !slices.Equal(stats.Affixes, instance.Affixes) && (len(stats.Affixes) > 0 || len(instance.Affixes) > 0) {
+		mismatch("affixes: stored %v, rederived %v", instance.Affixes, stats.Affixes)
+	}
+
+	return stats, mismatches
+}
@@ -0,0 +1,381 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// rolledItemInstance creates an instance of the named item of the configuration from a fixed seed.
+func rolledItemInstance(t *testing.T, config *common.GameConfig, name string) *common.ItemInstance {
+	original := newItemSeed
+	t.Cleanup(func() { newItemSeed = original })
+	newItemSeed = func() ([]byte, error) {
+		return []byte("0123456789abcdef0123456789abcdef"), nil
+	}
+
+	item, tier := lootItem(&config.Rarity, name)
+	instance, err := newItemInstance(config, 2, nil, item, tier, common.ItemSourceLootRoll, 100)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return instance
+}
+
+func TestRollItemStats_Deterministic(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	item, tier := lootItem(&config.Rarity, "Steel Sword")
+	seed := []byte("seed")
+
+	// Call the function
+	first := rollItemStats(config, item, tier, seed)
+	second := rollItemStats(config, item, tier, seed)
+
+	// Assertions
+	assert.Equal(t, first, second)
+	assert.Equal(t, 250, first.MaxDurability)
+}
+
+func TestRollItemStats_WithinRangesAndTierAffixCount(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	steelSword, _ := lootItem(&config.Rarity, "Steel Sword")
+	woodenSword, _ := lootItem(&config.Rarity, "Wooden Sword")
+	affixes := make(map[string]common.Affix)
+	for _, affix := range config.AffixPools["weapon"] {
+		affixes[affix.ID] = affix
+	}
+
+	for i := 0; i < 200; i++ {
+		seed := []byte{byte(i), byte(i >> 8)}
+
+		// Call the function
+		stats := rollItemStats(config, steelSword, "rare", seed)
+		legendary := rollItemStats(config, steelSword, "legendary", seed)
+		wooden := rollItemStats(config, woodenSword, "common", seed)
+
+		// Assertions
+		assert.GreaterOrEqual(t, stats.Damage, 35)
+		assert.LessOrEqual(t, stats.Damage, 45)
+		assert.GreaterOrEqual(t, len(stats.Affixes), 1)
+		assert.LessOrEqual(t, len(stats.Affixes), 2)
+		assert.GreaterOrEqual(t, len(legendary.Affixes), 2)
+		assert.LessOrEqual(t, len(legendary.Affixes), 3)
+		assert.Equal(t, 10, wooden.Damage)
+		assert.Empty(t, wooden.Affixes)
+
+		seen := make(map[string]bool)
+		for _, rolled := range legendary.Affixes {
+			affix := affixes[rolled.ID]
+			assert.False(t, seen[rolled.ID], "affix %s repeated", rolled.ID)
+			seen[rolled.ID] = true
+			assert.Equal(t, affix.Stat, rolled.Stat)
+			assert.GreaterOrEqual(t, rolled.Value, affix.Min)
+			assert.LessOrEqual(t, rolled.Value, affix.Max)
+		}
+	}
+}
+
+func TestRollIntRange(t *testing.T) {
+	r := &common.IntRange{Min: 3, Max: 5}
+
+	assert.Equal(t, 7, rollIntRange(7, nil, 0.5))
+	assert.Equal(t, 3, rollIntRange(0, r, 0))
+	assert.Equal(t, 4, rollIntRange(0, r, 0.5))
+	assert.Equal(t, 5, rollIntRange(0, r, 0.9999999))
+}
+
+func TestAffixViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.AffixPools = map[string][]common.Affix{
+		"armor": config.AffixPools["armor"],
+		"empty": {},
+		"weapon": {
+			{ID: "sharp", Text: "+{value} damage", Stat: "damage", Min: 1, Max: 2},
+			{ID: "sharp", Stat: "damage", Min: 3, Max: 1, Weight: -1},
+		},
+	}
+	config.Rarity.Rare.Affixes = &common.IntRange{Min: 2, Max: 1}
+	config.Rarity.Rare.Items[1].AffixPool = "shield"
+
+	// Call the function
+	violations := affixViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "affix_pools.empty", Message: "must not be empty"},
+		{Path: "affix_pools.weapon[1].id", Message: `duplicates the affix ID "sharp"`},
+		{Path: "affix_pools.weapon[1].text", Message: "must not be empty"},
+		{Path: "affix_pools.weapon[1].max", Message: "must not be less than min 3, got 1"},
+		{Path: "affix_pools.weapon[1].weight", Message: "must be a non-negative number, got -1"},
+		{Path: "rarity.rare.affixes", Message: "must be a range of non-negative counts, got 2 to 1"},
+		{Path: "rarity.rare.items[1].affix_pool", Message: `references the unknown affix pool "shield"`},
+	}, violations)
+}
+
+func TestItemStatViolations(t *testing.T) {
+	// Setup
+	item := &common.Item{
+		Name:            "Broken Sword",
+		Damage:          10,
+		DamageRange:     &common.IntRange{Min: 5, Max: 15},
+		Defense:         -1,
+		DurabilityRange: &common.IntRange{Min: 0, Max: 10},
+	}
+
+	// Call the function
+	violations := itemStatViolations("rarity.common.items[0]", item)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "rarity.common.items[0].damage", Message: "must not be set along with damage_range"},
+		{Path: "rarity.common.items[0].defense", Message: "must not be negative, got -1"},
+		{Path: "rarity.common.items[0].durability_range", Message: "must range from at least 1 to at least min, got 0 to 10"},
+	}, violations)
+}
+
+func TestRederiveItemStats_DetectsTamperedInstance(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	instance := rolledItemInstance(t, config, "Excalibur")
+	assert.Equal(t, hex.EncodeToString([]byte("0123456789abcdef0123456789abcdef")), instance.Seed)
+	assert.Equal(t, instance.MaxDurability, instance.Durability)
+
+	// Call the function
+	_, mismatches := rederiveItemStats(config, instance)
+	instance.Damage = 1000
+	_, tampered := rederiveItemStats(config, instance)
+
+	// Assertions
+	assert.Empty(t, mismatches)
+	assert.Equal(t, []string{"damage: stored 1000, rederived 100"}, tampered)
+}
+
+func TestRederiveItemStats_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RederiveItemStats RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := RederiveItemStats(ctx, mockLogger, nil, nil, `{"user_id":"user123","instance_id":"item-1"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestRederiveItemStats_Success(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	config := embeddedGameConfig(t)
+	instance := rolledItemInstance(t, config, "Dragon Shield")
+	inventoryJSON, _ := json.Marshal(&common.Inventory{Items: []*common.ItemInstance{instance}})
+	versionJSON, _ := json.Marshal(&common.GameConfigVersion{SchemaVersion: common.GameConfigSchemaVersion, Version: 2, Config: *config})
+
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RederiveItemStats RPC called").Once()
+	mockLogger.On("Info", "Rederived the stats of item instance %s of user %s, matches: %t", instance.ID, "user123", true).Once()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{
+		{Value: string(inventoryJSON), Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000002",
+	}}).Return([]*api.StorageObject{{Value: string(versionJSON)}}, nil)
+
+	// Call the function
+	result, err := RederiveItemStats(ctx, mockLogger, nil, nk, `{"user_id":"user123","instance_id":"`+instance.ID+`"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RederiveItemStatsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.True(t, resp.Matches)
+	assert.Equal(t, instance.Defense, resp.Rederived.Defense)
+	assert.GreaterOrEqual(t, resp.Rederived.Defense, 25)
+	assert.LessOrEqual(t, resp.Rederived.Defense, 35)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRederiveItemStats_LiveOpsOverride(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	config := embeddedGameConfig(t)
+	override := &common.LiveOpsOverride{ID: "plain_rares", StartAt: 50, EndAt: 150, Overrides: map[string]any{
+		"rarity": map[string]any{"rare": map[string]any{"affixes": map[string]any{"min": 0, "max": 0}}},
+	}}
+	overridden, err := mergeLiveOpsOverrides(config, []*common.LiveOpsOverride{override})
+	assert.NoError(t, err)
+
+	// The instance dropped while the override was active, so its stats differ from the ones of the published version
+	instance := rolledItemInstance(t, overridden, "Dragon Shield")
+	instance.LiveOpsOverrides = []string{"plain_rares"}
+	_, publishedMismatches := rederiveItemStats(config, instance)
+	assert.NotEmpty(t, publishedMismatches)
+
+	inventoryJSON, _ := json.Marshal(&common.Inventory{Items: []*common.ItemInstance{instance}})
+	versionJSON, _ := json.Marshal(&common.GameConfigVersion{SchemaVersion: common.GameConfigSchemaVersion, Version: 2, Config: *config})
+	overridesJSON, _ := json.Marshal(&common.LiveOpsOverrides{Overrides: []*common.LiveOpsOverride{override}})
+
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RederiveItemStats RPC called").Once()
+	mockLogger.On("Info", "Rederived the stats of item instance %s of user %s, matches: %t", instance.ID, "user123", true).Once()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{
+		{Value: string(inventoryJSON), Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000002",
+	}}).Return([]*api.StorageObject{{Value: string(versionJSON)}}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{{Value: string(overridesJSON)}}, nil)
+
+	// Call the function
+	result, err := RederiveItemStats(ctx, mockLogger, nil, nk, `{"user_id":"user123","instance_id":"`+instance.ID+`"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RederiveItemStatsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.True(t, resp.Matches)
+	assert.Empty(t, resp.Rederived.Affixes)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRederiveItemStats_DeletedLiveOpsOverride(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	config := embeddedGameConfig(t)
+	override := &common.LiveOpsOverride{ID: "plain_rares", StartAt: 50, EndAt: 150, Overrides: map[string]any{
+		"rarity": map[string]any{"rare": map[string]any{"affixes": map[string]any{"min": 0, "max": 0}}},
+	}}
+	overridden, err := mergeLiveOpsOverrides(config, []*common.LiveOpsOverride{override})
+	assert.NoError(t, err)
+	instance := rolledItemInstance(t, overridden, "Dragon Shield")
+	instance.LiveOpsOverrides = []string{"plain_rares"}
+
+	// The override is deleted after the instance dropped
+	overridesJSON, _ := json.Marshal(&common.LiveOpsOverrides{Overrides: []*common.LiveOpsOverride{override}})
+	var archived []*api.StorageObject
+	deleteLogger := new(mocks.Logger)
+	deleteLogger.On("Debug", "DeleteLiveOpsOverride RPC called").Once()
+	deleteLogger.On("Info", "Removed live-ops override %s", "plain_rares").Once()
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{{Value: string(overridesJSON), Version: "v1"}}, nil).Once()
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		if len(writes) != 2 || writes[0].Version != "v1" || writes[1].Collection != common.StorageLiveOpsArchive || writes[1].Version != "*" {
+			return false
+		}
+		archived = []*api.StorageObject{{Key: writes[1].Key, Value: writes[1].Value}}
+		return true
+	})).Return([]*api.StorageObjectAck{}, nil)
+	_, err = DeleteLiveOpsOverride(ctx, deleteLogger, nil, nk, `{"id":"plain_rares"}`)
+	assert.NoError(t, err)
+
+	inventoryJSON, _ := json.Marshal(&common.Inventory{Items: []*common.ItemInstance{instance}})
+	versionJSON, _ := json.Marshal(&common.GameConfigVersion{SchemaVersion: common.GameConfigSchemaVersion, Version: 2, Config: *config})
+
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RederiveItemStats RPC called").Once()
+	mockLogger.On("Info", "Rederived the stats of item instance %s of user %s, matches: %t", instance.ID, "user123", true).Once()
+
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{
+		{Value: string(inventoryJSON), Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000002",
+	}}).Return([]*api.StorageObject{{Value: string(versionJSON)}}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{{Value: `{"overrides":[]}`, Version: "v2"}}, nil)
+	nk.On("StorageRead", ctx, liveOpsArchiveRead("plain_rares")).Return(archived, nil)
+
+	// Call the function
+	result, err := RederiveItemStats(ctx, mockLogger, nil, nk, `{"user_id":"user123","instance_id":"`+instance.ID+`"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RederiveItemStatsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.True(t, resp.Matches)
+	assert.Empty(t, resp.Mismatches)
+	deleteLogger.AssertExpectations(t)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRederiveItemStats_UnarchivedLiveOpsOverride(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	config := embeddedGameConfig(t)
+	instance := rolledItemInstance(t, config, "Dragon Shield")
+	instance.LiveOpsOverrides = []string{"plain_rares"}
+	inventoryJSON, _ := json.Marshal(&common.Inventory{Items: []*common.ItemInstance{instance}})
+	versionJSON, _ := json.Marshal(&common.GameConfigVersion{SchemaVersion: common.GameConfigSchemaVersion, Version: 2, Config: *config})
+
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RederiveItemStats RPC called").Once()
+	mockLogger.On("Info", "Rederived the stats of item instance %s of user %s, matches: %t", instance.ID, "user123", false).Once()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{
+		{Value: string(inventoryJSON), Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000002",
+	}}).Return([]*api.StorageObject{{Value: string(versionJSON)}}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	// Deleted before deleted overrides were archived
+	nk.On("StorageRead", ctx, liveOpsArchiveRead("plain_rares")).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := RederiveItemStats(ctx, mockLogger, nil, nk, `{"user_id":"user123","instance_id":"`+instance.ID+`"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RederiveItemStatsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.False(t, resp.Matches)
+	assert.Equal(t, []string{"live_ops: override plain_rares no longer exists"}, resp.Mismatches)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRederiveItemStats_NotFound(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RederiveItemStats RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s not found", "missing", "user123").Once()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := RederiveItemStats(ctx, mockLogger, nil, nk, `{"user_id":"user123","instance_id":"missing"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,413 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"sort"
+	"time"
+)
+
+type (
+	CreateLiveOpsOverrideResponse struct {
+		Status common.Status `json:"status"`
+		ID     string        `json:"id"`
+	}
+
+	DeleteLiveOpsOverrideRequest struct {
+		ID string `json:"id"`
+	}
+
+	DeleteLiveOpsOverrideResponse struct {
+		Status common.Status `json:"status"`
+	}
+
+	ListLiveOpsOverridesResponse struct {
+		Overrides []*common.LiveOpsOverride `json:"overrides"`
+		Status    *common.LiveOpsStatus     `json:"status"`
+	}
+)
+
+// CreateLiveOpsOverride schedules a live-ops override after validating the configurations it results in.
+func CreateLiveOpsOverride(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("CreateLiveOpsOverride RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var override common.LiveOpsOverride
+	if err := json.Unmarshal([]byte(payload), &override); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	switch {
+	case override.ID == common.EmptyString:
+		logger.Error("Live-ops override ID must not be empty")
+		return common.EmptyString, common.ErrInvalidArgument
+	case override.StartAt <= 0 || override.EndAt <= override.StartAt:
+		logger.Error("Live-ops override %s must end after it starts", override.ID)
+		return common.EmptyString, common.ErrInvalidArgument
+	case len(override.Overrides) == 0:
+		logger.Error("Live-ops override %s does not override anything", override.ID)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	for _, field := range []string{"experiments", "live_ops"} {
+		if _, ok := override.Overrides[field]; ok {
+			logger.Error("Live-ops override %s must not override %s", override.ID, field)
+			return common.EmptyString, common.ErrInvalidArgument
+		}
+	}
+
+	overrides, storageVersion, err := readLiveOpsOverrides(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if findLiveOpsOverride(overrides.Overrides, override.ID) >= 0 {
+		logger.Error("Live-ops override %s already exists", override.ID)
+		return common.EmptyString, common.ErrAlreadyExists
+	}
+	// Item instances name the overrides they were rolled with, so the ID of a deleted override is not reused
+	archived, err := readArchivedLiveOpsOverrides(ctx, logger, nk, []string{override.ID})
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if len(archived) > 0 {
+		logger.Error("Live-ops override %s was deleted, its ID cannot be reused", override.ID)
+		return common.EmptyString, common.ErrAlreadyExists
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	override.CreatedAt = time.Now().Unix()
+	overrides.Overrides = append(overrides.Overrides, &override)
+
+	if err = validateLiveOpsOverride(logger, &active.Config, overrides.Overrides, &override); err != nil {
+		return common.EmptyString, err
+	}
+
+	if err = writeLiveOpsOverrides(ctx, logger, nk, overrides, storageVersion); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("Scheduled live-ops override %s from %d to %d", override.ID, override.StartAt, override.EndAt)
+
+	return marshalResponse(logger, &CreateLiveOpsOverrideResponse{
+		Status: common.StatusSuccess,
+		ID:     override.ID,
+	})
+}
+
+// DeleteLiveOpsOverride removes a live-ops override, ending it immediately if it is active. The override is archived
+// in the same write, so that the stats of the item instances rolled with it can still be derived again.
+func DeleteLiveOpsOverride(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("DeleteLiveOpsOverride RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req DeleteLiveOpsOverrideRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	overrides, storageVersion, err := readLiveOpsOverrides(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	index := findLiveOpsOverride(overrides.Overrides, req.ID)
+	if index < 0 {
+		logger.Error("Live-ops override %s not found", req.ID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	writeArchive, err := archivedLiveOpsOverrideWrite(logger, overrides.Overrides[index])
+	if err != nil {
+		return common.EmptyString, err
+	}
+	overrides.Overrides = slices.Delete(overrides.Overrides, index, index+1)
+
+	writeOverrides, err := liveOpsOverridesWrite(logger, overrides, storageVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if err = writeGameConfigObjects(ctx, logger, nk, writeOverrides, writeArchive); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("Removed live-ops override %s", req.ID)
+
+	return marshalResponse(logger, &DeleteLiveOpsOverrideResponse{
+		Status: common.StatusSuccess,
+	})
+}
+
+// ListLiveOpsOverrides lists the scheduled live-ops overrides along with the ones currently active.
+func ListLiveOpsOverrides(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, _ string) (string, error) {
+	logger.Debug("ListLiveOpsOverrides RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	overrides, _, err := readLiveOpsOverrides(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, &ListLiveOpsOverridesResponse{
+		Overrides: overrides.Overrides,
+		Status:    liveOpsStatus(overrides.Overrides, time.Now().Unix()),
+	})
+}
+
+// applyLiveOpsOverrides returns a copy of the configuration with the overrides active at the given time merged in
+// priority order.
+func applyLiveOpsOverrides(config *common.GameConfig, overrides []*common.LiveOpsOverride, now int64) (*common.GameConfig, error) {
+	return mergeLiveOpsOverrides(config, activeLiveOpsOverrides(overrides, now))
+}
+
+// mergeLiveOpsOverrides returns a copy of the configuration with the overrides merged in the order given.
+func mergeLiveOpsOverrides(config *common.GameConfig, overrides []*common.LiveOpsOverride) (*common.GameConfig, error) {
+	result := *config
+
+	for _, override := range overrides {
+		patched, err := applyGameConfigPatch(&result, override.Overrides)
+		if err != nil {
+			return nil, err
+		}
+		result = *patched
+	}
+
+	return &result, nil
+}
+
+// activeLiveOpsOverrides returns the overrides active at the given time, in the order they are applied.
+func activeLiveOpsOverrides(overrides []*common.LiveOpsOverride, now int64) []*common.LiveOpsOverride {
+	var active []*common.LiveOpsOverride
+	for _, override := range overrides {
+		if override.StartAt <= now && now < override.EndAt {
+			active = append(active, override)
+		}
+	}
+
+	sortLiveOpsOverrides(active)
+	return active
+}
+
+// sortLiveOpsOverrides sorts the overrides in the order they are applied: the highest priority last, then the latest
+// started last.
+func sortLiveOpsOverrides(overrides []*common.LiveOpsOverride) {
+	sort.SliceStable(overrides, func(i, j int) bool {
+		if overrides[i].Priority != overrides[j].Priority {
+			return overrides[i].Priority < overrides[j].Priority
+		}
+		if overrides[i].StartAt != overrides[j].StartAt {
+			return overrides[i].StartAt < overrides[j].StartAt
+		}
+		return overrides[i].ID < overrides[j].ID
+	})
+}
+
+// liveOpsStatus reports the overrides active at the given time and when an override next starts or ends,
+// nil when no override is active or upcoming.
+func liveOpsStatus(overrides []*common.LiveOpsOverride, now int64) *common.LiveOpsStatus {
+	status := &common.LiveOpsStatus{ActiveOverrides: []string{}}
+	for _, override := range activeLiveOpsOverrides(overrides, now) {
+		status.ActiveOverrides = append(status.ActiveOverrides, override.ID)
+	}
+
+	for _, override := range overrides {
+		for _, change := range []int64{override.StartAt, override.EndAt} {
+			if change > now && (status.NextChangeAt == 0 || change < status.NextChangeAt) {
+				status.NextChangeAt = change
+			}
+		}
+	}
+
+	if len(status.ActiveOverrides) == 0 && status.NextChangeAt == 0 {
+		return nil
+	}
+
+	return status
+}
+
+// validateLiveOpsOverride validates every configuration the candidate override takes part in, for the players outside
+// of every experiment as well as for each experiment variant. As the set of active overrides only changes when one
+// starts or ends, checking the configuration at each of these instants within the candidate's window covers the whole
+// window.
+func validateLiveOpsOverride(logger runtime.Logger, base *common.GameConfig, overrides []*common.LiveOpsOverride, candidate *common.LiveOpsOverride) error {
+	instants := []int64{candidate.StartAt}
+	for _, override := range overrides {
+		for _, instant := range []int64{override.StartAt, override.EndAt} {
+			if instant > candidate.StartAt && instant < candidate.EndAt {
+				instants = append(instants, instant)
+			}
+		}
+	}
+
+	configs, err := experimentVariantConfigs(base)
+	if err != nil {
+		logger.Error("Cannot apply experiment variants: %+v", err)
+		return common.ErrInvalidArgument
+	}
+
+	for _, config := range configs {
+		for _, instant := range instants {
+			patched, err := applyLiveOpsOverrides(config, overrides, instant)
+			if err != nil {
+				logger.Error("Cannot apply live-ops overrides at %d: %+v", instant, err)
+				return common.ErrInvalidArgument
+			}
+
+			if err = ValidateGameConfig(logger, patched); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateLiveOpsOverrides validates the overrides which have not ended yet against a new base configuration, so that
+// publishing or rolling back to it does not make any of them serve an invalid configuration.
+func validateLiveOpsOverrides(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, base *common.GameConfig) error {
+	overrides, _, err := readLiveOpsOverrides(ctx, logger, nk)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, override := range overrides.Overrides {
+		if override.EndAt <= now {
+			continue
+		}
+		if err = validateLiveOpsOverride(logger, base, overrides.Overrides, override); err != nil {
+			logger.Error("Live-ops override %s is not valid against the game configuration", override.ID)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readLiveOpsOverrides reads the scheduled overrides along with their storage version.
+func readLiveOpsOverrides(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) (*common.LiveOpsOverrides, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageConfiguration,
+		Key:        common.StorageLiveOpsOverridesKey,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+
+	overrides := &common.LiveOpsOverrides{Overrides: []*common.LiveOpsOverride{}}
+	if len(objects) == 0 {
+		return overrides, common.EmptyString, nil
+	}
+
+	if err = json.Unmarshal([]byte(objects[0].GetValue()), overrides); err != nil {
+		logger.Error("Cannot unmarshal live-ops overrides: %+v", err)
+		return nil, common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	return overrides, objects[0].GetVersion(), nil
+}
+
+// writeLiveOpsOverrides writes the scheduled overrides, guarded by the storage version they were read at.
+func writeLiveOpsOverrides(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, overrides *common.LiveOpsOverrides, storageVersion string) error {
+	write, err := liveOpsOverridesWrite(logger, overrides, storageVersion)
+	if err != nil {
+		return err
+	}
+	return writeGameConfigObjects(ctx, logger, nk, write)
+}
+
+// liveOpsOverridesWrite builds the write of the scheduled overrides, guarded by the storage version they were read at.
+func liveOpsOverridesWrite(logger runtime.Logger, overrides *common.LiveOpsOverrides, storageVersion string) (*runtime.StorageWrite, error) {
+	overridesJSON, err := json.Marshal(overrides)
+	if err != nil {
+		logger.Error("Cannot marshal live-ops overrides: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	if storageVersion == common.EmptyString {
+		storageVersion = "*"
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageConfiguration,
+		Key:             common.StorageLiveOpsOverridesKey,
+		Value:           string(overridesJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// archivedLiveOpsOverrideWrite builds the create-only write archiving a deleted override under its ID.
+func archivedLiveOpsOverrideWrite(logger runtime.Logger, override *common.LiveOpsOverride) (*runtime.StorageWrite, error) {
+	overrideJSON, err := json.Marshal(override)
+	if err != nil {
+		logger.Error("Cannot marshal live-ops override: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageLiveOpsArchive,
+		Key:             override.ID,
+		Value:           string(overrideJSON),
+		Version:         "*",
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// readArchivedLiveOpsOverrides reads the deleted overrides with the given IDs. The IDs not archived are skipped.
+func readArchivedLiveOpsOverrides(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, ids []string) ([]*common.LiveOpsOverride, error) {
+	reads := make([]*runtime.StorageRead, 0, len(ids))
+	for _, id := range ids {
+		reads = append(reads, &runtime.StorageRead{Collection: common.StorageLiveOpsArchive, Key: id})
+	}
+
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.ErrInternalError
+	}
+
+	archived := make([]*common.LiveOpsOverride, 0, len(objects))
+	for _, obj := range objects {
+		override := &common.LiveOpsOverride{}
+		if err = json.Unmarshal([]byte(obj.GetValue()), override); err != nil {
+			logger.Error("Cannot unmarshal archived live-ops override %s: %+v", obj.GetKey(), err)
+			return nil, common.ErrUnMarshallingError
+		}
+		archived = append(archived, override)
+	}
+
+	return archived, nil
+}
+
+// findLiveOpsOverride returns the index of the override with the given ID, -1 if there is none.
+func findLiveOpsOverride(overrides []*common.LiveOpsOverride, id string) int {
+	return slices.IndexFunc(overrides, func(override *common.LiveOpsOverride) bool {
+		return override.ID == id
+	})
+}
@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+var liveOpsOverridesRead = []*runtime.StorageRead{{
+	Collection: common.StorageConfiguration,
+	Key:        common.StorageLiveOpsOverridesKey,
+}}
+
+func liveOpsArchiveRead(ids ...string) []*runtime.StorageRead {
+	reads := make([]*runtime.StorageRead, 0, len(ids))
+	for _, id := range ids {
+		reads = append(reads, &runtime.StorageRead{Collection: common.StorageLiveOpsArchive, Key: id})
+	}
+	return reads
+}
+
+func TestApplyLiveOpsOverrides_MergesActiveOverridesByPriority(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	overrides := []*common.LiveOpsOverride{
+		{ID: "triple_xp", Priority: 10, StartAt: 100, EndAt: 200, Overrides: map[string]any{"xp_rate": 4.5}},
+		{ID: "double_xp", Priority: 1, StartAt: 50, EndAt: 300, Overrides: map[string]any{"xp_rate": 3.0}},
+		{ID: "legendary_week", Priority: 5, StartAt: 400, EndAt: 500, Overrides: map[string]any{"welcome_message": "Legends"}},
+	}
+
+	// Call the function
+	during, err := applyLiveOpsOverrides(config, overrides, 150)
+	after, afterErr := applyLiveOpsOverrides(config, overrides, 250)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.NoError(t, afterErr)
+	assert.Equal(t, 4.5, during.XpRate)
+	assert.Equal(t, &common.LiveOpsStatus{ActiveOverrides: []string{"double_xp", "triple_xp"}, NextChangeAt: 200}, liveOpsStatus(overrides, 150))
+	assert.Equal(t, 3.0, after.XpRate)
+	assert.Equal(t, &common.LiveOpsStatus{ActiveOverrides: []string{"double_xp"}, NextChangeAt: 300}, liveOpsStatus(overrides, 250))
+	assert.Equal(t, 1.5, config.XpRate)
+}
+
+func TestLiveOpsStatus_NothingScheduled(t *testing.T) {
+	// Setup
+	overrides := []*common.LiveOpsOverride{
+		{ID: "double_xp", StartAt: 50, EndAt: 100},
+	}
+
+	// Call the function
+	status := liveOpsStatus(overrides, 100)
+
+	// Assertions
+	assert.Nil(t, status)
+}
+
+func TestCreateLiveOpsOverride_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CreateLiveOpsOverride RPC called").Once()
+	mockLogger.On("Info", "Scheduled live-ops override %s from %d to %d", "double_xp", int64(100), int64(200)).Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 1, Config: *embeddedGameConfig(t)})
+
+	var written common.LiveOpsOverrides
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, liveOpsArchiveRead("double_xp")).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageWrite", ctx, mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].Version == "*" &&
+			json.Unmarshal([]byte(writes[0].Value), &written) == nil
+	})).Return([]*api.StorageObjectAck{}, nil)
+
+	// Call the function
+	result, err := CreateLiveOpsOverride(ctx, mockLogger, nil, nk, `{"id":"double_xp","start_at":100,"end_at":200,"overrides":{"xp_rate":3.0}}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":"success","id":"double_xp"}`, result)
+	assert.Len(t, written.Overrides, 1)
+	assert.Equal(t, "double_xp", written.Overrides[0].ID)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestCreateLiveOpsOverride_InvalidWhenOverlapping(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CreateLiveOpsOverride RPC called").Once()
+	mockLogger.On("Error", "Invalid game configuration at %s: %s", "rarity", "chances must sum to 1.0, got 0.95").Once()
+
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 1, Config: *embeddedGameConfig(t)})
+
+	// Valid on its own, but not while the new override is active as well
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{
+		{Value: `{"overrides":[{"id":"common_nerf","priority":1,"start_at":150,"end_at":300,"overrides":{"rarity":{"common":{"chance":0.45},"legendary":{"chance":0.1}}}}]}`, Version: "v1"},
+	}, nil)
+	nk.On("StorageRead", ctx, liveOpsArchiveRead("legendary_week")).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := CreateLiveOpsOverride(ctx, mockLogger, nil, nk, `{"id":"legendary_week","start_at":100,"end_at":200,"overrides":{"rarity":{"uncommon":{"chance":0.25},"legendary":{"chance":0.1}}}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.EqualError(t, err, "invalid game configuration: rarity: chances must sum to 1.0, got 0.95")
+	mockLogger.AssertExpectations(t)
+	nk.AssertNotCalled(t, "StorageWrite", mock.Anything, mock.Anything)
+	nk.AssertExpectations(t)
+}
+
+func TestCreateLiveOpsOverride_DeletedID(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "CreateLiveOpsOverride RPC called").Once()
+	mockLogger.On("Error", "Live-ops override %s was deleted, its ID cannot be reused", "double_xp").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, liveOpsArchiveRead("double_xp")).Return([]*api.StorageObject{
+		{Key: "double_xp", Value: `{"id":"double_xp","start_at":10,"end_at":20,"overrides":{"xp_rate":2.0}}`},
+	}, nil)
+
+	// Call the function
+	result, err := CreateLiveOpsOverride(ctx, mockLogger, nil, nk, `{"id":"double_xp","start_at":100,"end_at":200,"overrides":{"xp_rate":3.0}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrAlreadyExists, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDeleteLiveOpsOverride_NotFound(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DeleteLiveOpsOverride RPC called").Once()
+	mockLogger.On("Error", "Live-ops override %s not found", "double_xp").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := DeleteLiveOpsOverride(ctx, mockLogger, nil, nk, `{"id":"double_xp"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
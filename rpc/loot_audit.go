@@ -0,0 +1,365 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"maps"
+	"oak/common"
+	"slices"
+)
+
+const (
+	// Maximum number of loot rolls returned by a single history page
+	maxLootRollHistoryLimit = 100
+	// lootAuditIndex is the storage index the audit records are searched across players with
+	lootAuditIndex = "loot_audit_index"
+	// maxLootAuditEntries is the number of audit records the storage index holds at most, the oldest being evicted first
+	maxLootAuditEntries = 5000000
+)
+
+var (
+	lootAuditIndexFields         = []string{"user_id", "timestamp"}
+	lootAuditIndexSortableFields = []string{"timestamp"}
+)
+
+type (
+	ListLootRollsRequest struct {
+		UserID string `json:"user_id"`
+		Limit  int    `json:"limit,omitempty"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+
+	ListLootRollsResponse struct {
+		Rolls  []*common.LootRollRecord `json:"rolls"`
+		Cursor string                   `json:"cursor,omitempty"`
+	}
+
+	ReplayLootRollRequest struct {
+		UserID string `json:"user_id"`
+		RollID string `json:"roll_id"`
+	}
+
+	// LootRollReplay is the outcome of a logged roll replayed from its seed.
+	LootRollReplay struct {
+		Chances  map[string]float64 `json:"chances"`
+		Draws    []float64          `json:"draws"`
+		Tier     string             `json:"tier,omitempty"`
+		Item     string             `json:"item,omitempty"`
+		Currency string             `json:"currency,omitempty"`
+		Amount   int64              `json:"amount,omitempty"`
+	}
+
+	ReplayLootRollResponse struct {
+		Roll       *common.LootRollRecord `json:"roll"`
+		Replay     *LootRollReplay        `json:"replay"`
+		Reproduced bool                   `json:"reproduced"`
+		Mismatches []string               `json:"mismatches,omitempty"`
+		// The drop table of the roll is the one of its published configuration version, unchanged by experiment
+		// variants or live-ops overrides
+		PublishedDropTable bool `json:"published_drop_table"`
+	}
+)
+
+// newLootRollRecord builds the audit record of a roll decided with the tiers drawn from the recorded random source.
+func newLootRollRecord(userID string, now int64, configVersion int, rarity *common.Rarity, pity map[string]int, tiers []lootTier, random *recordingLootRandom, roll *lootRoll, instance *common.ItemInstance) *common.LootRollRecord {
+	return &common.LootRollRecord{
+		ID:            lootRollKey(now, instance.ID),
+		UserID:        userID,
+		Timestamp:     now,
+		ConfigVersion: configVersion,
+		Rarity:        *rarity,
+		Pity:          pity,
+		Chances:       lootTierChances(tiers),
+		Seed:          hex.EncodeToString(random.Seed()),
+		Draws:         random.draws,
+		Tier:          roll.Tier,
+		Item:          &roll.Item,
+		InstanceID:    instance.ID,
+	}
+}
+
+// newLootTableRollRecord builds the audit record of a roll of the named loot table drawn from the recorded random
+// source. The instance is nil unless the roll dropped an item.
+func newLootTableRollRecord(rollID, userID string, now int64, configVersion int, config *common.GameConfig, table string, random *recordingLootRandom, drop *lootDrop, instance *common.ItemInstance) *common.LootRollRecord {
+	record := &common.LootRollRecord{
+		ID:            rollID,
+		UserID:        userID,
+		Timestamp:     now,
+		ConfigVersion: configVersion,
+		Table:         table,
+		Rarity:        config.Rarity,
+		LootTables:    reachableLootTables(config.LootTables, table),
+		Chances:       lootTableTierChances(config.LootTables[table].Tiers),
+		Seed:          hex.EncodeToString(random.Seed()),
+		Draws:         random.draws,
+		Tier:          drop.Tier,
+		Item:          drop.Item,
+		Currency:      drop.Currency,
+		Amount:        drop.Amount,
+	}
+	if instance != nil {
+		record.InstanceID = instance.ID
+	}
+	return record
+}
+
+// lootRollKey zero pads the timestamp so storage listings are ordered by roll time.
+func lootRollKey(now int64, instanceID string) string {
+	return fmt.Sprintf("%019d-%s", now, instanceID)
+}
+
+// RegisterLootAuditIndex registers the storage index the audit records are searched across players with, e.g. by
+// timestamp. Nakama keeps it up to date as records are written. The history of a player is listed from storage, so
+// records the index evicts are still listed.
+func RegisterLootAuditIndex(initializer runtime.Initializer) error {
+	return initializer.RegisterStorageIndex(lootAuditIndex, common.StorageLootAudit, common.EmptyString,
+		lootAuditIndexFields, lootAuditIndexSortableFields, maxLootAuditEntries, false)
+}
+
+// lootTierChances returns the chances the tiers are rolled with, keyed by tier.
+func lootTierChances(tiers []lootTier) map[string]float64 {
+	chances := make(map[string]float64, len(tiers))
+	for _, tier := range tiers {
+		chances[tier.Name] = tier.Chance
+	}
+	return chances
+}
+
+// lootTableTierChances returns the chances the tiers of a loot table are rolled with, keyed by tier.
+func lootTableTierChances(tiers []common.LootTableTier) map[string]float64 {
+	chances := make(map[string]float64, len(tiers))
+	for _, tier := range tiers {
+		chances[tier.Name] = tier.Chance
+	}
+	return chances
+}
+
+// lootRollRecordWrite builds the create-only write of the audit record. The record is owned by the player who rolled,
+// under its roll ID, so that the history of the player is listed in roll order. Players can neither read nor write it,
+// and as it can only be created the audit log is append-only.
+func lootRollRecordWrite(logger runtime.Logger, record *common.LootRollRecord) (*runtime.StorageWrite, error) {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		logger.Error("Cannot marshal loot roll record: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageLootAudit,
+		Key:             record.ID,
+		UserID:          record.UserID,
+		Value:           string(recordJSON),
+		Version:         "*",
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// ListLootRolls lists the audit records of the loot rolls of a player, oldest first.
+func ListLootRolls(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ListLootRolls RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req ListLootRollsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString {
+		logger.Error("Payload did not contain a user ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if req.Limit <= 0 || req.Limit > maxLootRollHistoryLimit {
+		req.Limit = maxLootRollHistoryLimit
+	}
+
+	// Roll IDs start with the zero padded roll time, so the records of the player are listed in roll order
+	objects, cursor, err := nk.StorageList(ctx, common.EmptyString, req.UserID, common.StorageLootAudit, req.Limit, req.Cursor)
+	if err != nil {
+		logger.Error("StorageList error: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	resp := &ListLootRollsResponse{
+		Rolls:  make([]*common.LootRollRecord, 0, len(objects)),
+		Cursor: cursor,
+	}
+	for _, obj := range objects {
+		record := &common.LootRollRecord{}
+		if err := json.Unmarshal([]byte(obj.GetValue()), record); err != nil {
+			logger.Error("Cannot unmarshal loot roll record %s: %+v", obj.GetKey(), err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+		resp.Rolls = append(resp.Rolls, record)
+	}
+
+	return marshalResponse(logger, resp)
+}
+
+// ReplayLootRoll replays a logged loot roll from its seed and checks it reproduces the logged outcome.
+func ReplayLootRoll(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ReplayLootRoll RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req ReplayLootRollRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString || req.RollID == common.EmptyString {
+		logger.Error("Payload did not contain a user ID and a roll ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageLootAudit,
+		Key:        req.RollID,
+		UserID:     req.UserID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+	if len(objects) == 0 {
+		logger.Error("Loot roll %s of user %s not found", req.RollID, req.UserID)
+		return common.EmptyString, common.ErrNotFound
+	}
+
+	record := &common.LootRollRecord{}
+	if err = json.Unmarshal([]byte(objects[0].GetValue()), record); err != nil {
+		logger.Error("Cannot unmarshal loot roll record %s: %+v", req.RollID, err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	source := &GameConfigSource{Version: record.ConfigVersion}
+	if record.ConfigVersion == 0 {
+		// Rolled before any version was published
+		source = &GameConfigSource{Embedded: true}
+	}
+	published, _, err := readGameConfigSource(ctx, logger, nk, source)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	publishedDropTable, err := sameDropTable(&published.Rarity, &record.Rarity)
+	if err == nil && publishedDropTable && record.Table != common.EmptyString {
+		publishedDropTable, err = sameDropTable(reachableLootTables(published.LootTables, record.Table), record.LootTables)
+	}
+	if err != nil {
+		logger.Error("Cannot marshal drop table: %+v", err)
+		return common.EmptyString, common.ErrMarshallingError
+	}
+
+	replay, mismatches := replayLootRoll(record)
+
+	logger.Info("Replayed loot roll %s of user %s, reproduced: %t", record.ID, record.UserID, len(mismatches) == 0)
+
+	return marshalResponse(logger, &ReplayLootRollResponse{
+		Roll:               record,
+		Replay:             replay,
+		Reproduced:         len(mismatches) == 0,
+		Mismatches:         mismatches,
+		PublishedDropTable: publishedDropTable,
+	})
+}
+
+// replayLootRoll rolls the logged drop table again with the logged pity counters and seed, through the same code path
+// as live rolls, and returns the replayed outcome along with its differences with the logged one.
+func replayLootRoll(record *common.LootRollRecord) (*LootRollReplay, []string) {
+	var mismatches []string
+	mismatch := func(format string, args ...any) {
+		mismatches = append(mismatches, fmt.Sprintf(format, args...))
+	}
+
+	var tiers []lootTier
+	replay := &LootRollReplay{Draws: []float64{}}
+	if record.Table == common.EmptyString {
+		tiers = applyPity(lootTiers(&record.Rarity), record.Pity)
+		replay.Chances = lootTierChances(tiers)
+	} else {
+		replay.Chances = lootTableTierChances(record.LootTables[record.Table].Tiers)
+	}
+	if !maps.Equal(replay.Chances, record.Chances) {
+		mismatch("chances: logged %v, replayed %v", record.Chances, replay.Chances)
+	}
+
+	seed, err := hex.DecodeString(record.Seed)
+	if err != nil || len(seed) == 0 {
+		mismatch("seed: roll has no valid seed")
+		return replay, mismatches
+	}
+
+	random := newRecordingLootRandom(newSeededLootRandom(seed))
+	if record.Table == common.EmptyString {
+		if len(tiers) == 0 {
+			mismatch("rarity: no tier can drop")
+			return replay, mismatches
+		}
+		roll := rollLoot(tiers, random)
+		replay.Tier = roll.Tier
+		replay.Item = roll.Item.Name
+	} else {
+		if violations := lootTableViolations(&record.Rarity, record.LootTables); len(violations) > 0 {
+			mismatch("loot_tables: %s: %s", violations[0].Path, violations[0].Message)
+			return replay, mismatches
+		}
+		if _, ok := record.LootTables[record.Table]; !ok {
+			mismatch("table: loot table %s is not logged", record.Table)
+			return replay, mismatches
+		}
+		drop := rollLootTable(&record.Rarity, record.LootTables, record.Table, random)
+		replay.Tier = drop.Tier
+		if drop.Item != nil {
+			replay.Item = drop.Item.Name
+		}
+		replay.Currency = drop.Currency
+		replay.Amount = drop.Amount
+	}
+	replay.Draws = random.draws
+
+	loggedItem := common.EmptyString
+	if record.Item != nil {
+		loggedItem = record.Item.Name
+	}
+	if !slices.Equal(replay.Draws, record.Draws) {
+		mismatch("draws: logged %v, replayed %v", record.Draws, replay.Draws)
+	}
+	if replay.Tier != record.Tier {
+		mismatch("tier: logged %s, replayed %s", record.Tier, replay.Tier)
+	}
+	if replay.Item != loggedItem {
+		mismatch("item: logged %s, replayed %s", loggedItem, replay.Item)
+	}
+	if replay.Currency != record.Currency || replay.Amount != record.Amount {
+		mismatch("currency: logged %d %s, replayed %d %s", record.Amount, record.Currency, replay.Amount, replay.Currency)
+	}
+
+	return replay, mismatches
+}
+
+// sameDropTable reports whether the drop tables are identical once serialized, as they are stored.
+func sameDropTable(a, b any) (bool, error) {
+	aJSON, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	bJSON, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aJSON, bJSON), nil
+}
@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// auditedLootRoll rolls the drop table from a fixed seed and returns the audit record of the roll.
+func auditedLootRoll(rarity *common.Rarity, pity map[string]int) *common.LootRollRecord {
+	random := newRecordingLootRandom(newSeededLootRandom([]byte("0123456789abcdef0123456789abcdef")))
+	tiers := applyPity(lootTiers(rarity), pity)
+	roll := rollLoot(tiers, random)
+	return newLootRollRecord("user123", 100, 2, rarity, pity, tiers, random, roll, &common.ItemInstance{ID: "item-1"})
+}
+
+func lootRollRecordRead(userID, rollID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageLootAudit,
+		Key:        rollID,
+		UserID:     userID,
+	}}
+}
+
+func TestReplayLootRoll_ReproducesLoggedRoll(t *testing.T) {
+	// Setup
+	record := auditedLootRoll(&embeddedGameConfig(t).Rarity, map[string]int{"legendary": 12})
+
+	// Call the function
+	replay, mismatches := replayLootRoll(record)
+
+	// Assertions
+	assert.Empty(t, mismatches)
+	assert.Equal(t, "0000000000000000100-item-1", record.ID)
+	assert.Equal(t, record.Tier, replay.Tier)
+	assert.Equal(t, record.Item.Name, replay.Item)
+	assert.Len(t, replay.Draws, 2)
+	assert.Equal(t, record.Draws, replay.Draws)
+}
+
+func TestReplayLootRoll_DetectsTamperedRecord(t *testing.T) {
+	// Setup
+	record := auditedLootRoll(&embeddedGameConfig(t).Rarity, nil)
+	record.Chances["common"] = 0.9
+	record.Tier = "tampered"
+
+	// Call the function
+	replay, mismatches := replayLootRoll(record)
+
+	// Assertions
+	assert.Len(t, mismatches, 2)
+	assert.Regexp(t, `^chances: logged `, mismatches[0])
+	assert.Equal(t, "tier: logged tampered, replayed "+replay.Tier, mismatches[1])
+}
+
+func TestReplayLootRoll_WithoutSeed(t *testing.T) {
+	// Setup
+	record := auditedLootRoll(&embeddedGameConfig(t).Rarity, nil)
+	record.Seed = common.EmptyString
+
+	// Call the function
+	replay, mismatches := replayLootRoll(record)
+
+	// Assertions
+	assert.Equal(t, []string{"seed: roll has no valid seed"}, mismatches)
+	assert.Empty(t, replay.Draws)
+}
+
+func TestListLootRolls_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ListLootRolls RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ListLootRolls(ctx, mockLogger, nil, nil, `{"user_id":"user123"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestListLootRolls_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ListLootRolls RPC called").Once()
+
+	ctx := context.Background()
+	record := auditedLootRoll(&embeddedGameConfig(t).Rarity, nil)
+	recordJSON, _ := json.Marshal(record)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageList", ctx, "", "user123", common.StorageLootAudit, 100, "").Return([]*api.StorageObject{
+		{Key: record.ID, UserId: "user123", Value: string(recordJSON)},
+	}, "next", nil)
+
+	// Call the function
+	result, err := ListLootRolls(ctx, mockLogger, nil, nk, `{"user_id":"user123","limit":500}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp ListLootRollsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, []*common.LootRollRecord{record}, resp.Rolls)
+	assert.Equal(t, "next", resp.Cursor)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReplayLootRoll_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReplayLootRoll RPC called").Once()
+	mockLogger.On("Info", "Replayed loot roll %s of user %s, reproduced: %t", "0000000000000000100-item-1", "user123", true).Once()
+
+	ctx := context.Background()
+	config := embeddedGameConfig(t)
+	record := auditedLootRoll(&config.Rarity, nil)
+	recordJSON, _ := json.Marshal(record)
+	versionJSON, _ := json.Marshal(&common.GameConfigVersion{SchemaVersion: common.GameConfigSchemaVersion, Version: 2, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, lootRollRecordRead("user123", record.ID)).Return([]*api.StorageObject{
+		{Value: string(recordJSON)},
+	}, nil)
+	nk.On("StorageRead", ctx, []*runtime.StorageRead{{
+		Collection: common.StorageGameConfigVersions,
+		Key:        "0000000002",
+	}}).Return([]*api.StorageObject{{Value: string(versionJSON)}}, nil)
+
+	// Call the function
+	result, err := ReplayLootRoll(ctx, mockLogger, nil, nk, `{"user_id":"user123","roll_id":"0000000000000000100-item-1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp ReplayLootRollResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.True(t, resp.Reproduced)
+	assert.True(t, resp.PublishedDropTable)
+	assert.Empty(t, resp.Mismatches)
+	assert.Equal(t, record.Tier, resp.Replay.Tier)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReplayLootRoll_NotFound(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReplayLootRoll RPC called").Once()
+	mockLogger.On("Error", "Loot roll %s of user %s not found", "missing", "user123").Once()
+
+	ctx := context.Background()
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, lootRollRecordRead("user123", "missing")).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := ReplayLootRoll(ctx, mockLogger, nil, nk, `{"user_id":"user123","roll_id":"missing"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,222 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+	"strconv"
+	"time"
+)
+
+type (
+	ReadDropRatesRequest struct {
+		Table string `json:"table,omitempty"`
+	}
+
+	DropRateItem struct {
+		Item        string  `json:"item"`
+		Name        string  `json:"name"`
+		Weight      float64 `json:"weight"`
+		Probability float64 `json:"probability"`
+		Display     string  `json:"display"`
+	}
+
+	DropRateTier struct {
+		Tier        string           `json:"tier"`
+		Probability float64          `json:"probability"`
+		Display     string           `json:"display"`
+		Items       []*DropRateItem  `json:"items"`
+		Pity        *common.PityRule `json:"pity,omitempty"`
+	}
+
+	// DropRate is the probability of a loot table roll to drop an item, an amount of currency, or nothing.
+	DropRate struct {
+		Item        string  `json:"item,omitempty"`
+		Name        string  `json:"name,omitempty"`
+		Tier        string  `json:"tier,omitempty"`
+		Currency    string  `json:"currency,omitempty"`
+		Amount      int64   `json:"amount,omitempty"`
+		Nothing     bool    `json:"nothing,omitempty"`
+		Probability float64 `json:"probability"`
+		Display     string  `json:"display"`
+	}
+
+	ReadDropRatesResponse struct {
+		ConfigVersion int             `json:"config_version"`
+		Language      string          `json:"language,omitempty"`
+		Table         string          `json:"table,omitempty"`
+		Drops         []*DropRate     `json:"drops,omitempty"`
+		Base          []*DropRateTier `json:"base,omitempty"`
+		Current       []*DropRateTier `json:"current,omitempty"`
+		Pity          map[string]int  `json:"pity,omitempty"`
+	}
+)
+
+// ReadDropRates discloses the probability of every item of the caller's drop table, with their experiment variants and
+// the live-ops overrides in effect. The base rates are those of a player without pity progress, the current rates
+// those of the caller's next roll given their pity counters. When the payload names a loot table, the probability of
+// every drop of the table is disclosed instead.
+func ReadDropRates(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ReadDropRates RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req ReadDropRatesRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	if violations := rarityViolations(&config.Rarity); len(violations) > 0 {
+		for _, violation := range violations {
+			logger.Error("Cannot disclose drop rates, invalid drop table at %s: %s", violation.Path, violation.Message)
+		}
+		return common.EmptyString, common.ErrInvalidLootTable
+	}
+
+	if req.Table != common.EmptyString {
+		if _, ok := config.LootTables[req.Table]; !ok {
+			logger.Error("Loot table %s not found", req.Table)
+			return common.EmptyString, common.ErrNotFound
+		}
+		if violations := lootTableViolations(&config.Rarity, config.LootTables); len(violations) > 0 {
+			for _, violation := range violations {
+				logger.Error("Cannot disclose drop rates, invalid loot table at %s: %s", violation.Path, violation.Message)
+			}
+			return common.EmptyString, common.ErrInvalidLootTable
+		}
+	}
+
+	// The rates are computed on the drop table as rolled, the localized copy only provides the display names
+	localized, language, err := localizeUserGameConfig(ctx, logger, nk, userID, config)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	names := lootItemNames(&config.Rarity, &localized.Rarity)
+
+	if req.Table != common.EmptyString {
+		return marshalResponse(logger, &ReadDropRatesResponse{
+			ConfigVersion: active.Version,
+			Language:      language,
+			Table:         req.Table,
+			Drops:         lootTableDisclosure(lootTableDropRates(&config.Rarity, config.LootTables, req.Table), names),
+		})
+	}
+
+	pity, _, err := readPityCounters(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	tiers := lootTiers(&config.Rarity)
+	return marshalResponse(logger, &ReadDropRatesResponse{
+		ConfigVersion: active.Version,
+		Language:      language,
+		Base:          dropRates(applyPity(tiers, nil), names),
+		Current:       dropRates(applyPity(tiers, pity.Counters), names),
+		Pity:          pity.Counters,
+	})
+}
+
+// dropRates returns the probability rollLoot drops every item of the tiers with: the probability of its tier times
+// its weight within the tier.
+func dropRates(tiers []lootTier, names map[string]string) []*DropRateTier {
+	tierProbabilities := weightedProbabilities(lootTierWeights(tiers))
+
+	rates := make([]*DropRateTier, 0, len(tiers))
+	for i, tier := range tiers {
+		rate := &DropRateTier{
+			Tier:        tier.Name,
+			Probability: tierProbabilities[i],
+			Display:     formatDropRate(tierProbabilities[i]),
+			Items:       make([]*DropRateItem, 0, len(tier.Items)),
+			Pity:        tier.Pity,
+		}
+
+		weights := lootItemWeights(tier.Items)
+		for j, itemProbability := range weightedProbabilities(weights) {
+			item := &tier.Items[j]
+			name, ok := names[item.Name]
+			if !ok {
+				name = item.Name
+			}
+			probability := tierProbabilities[i] * itemProbability
+			rate.Items = append(rate.Items, &DropRateItem{
+				Item:        item.Name,
+				Name:        name,
+				Weight:      weights[j],
+				Probability: probability,
+				Display:     formatDropRate(probability),
+			})
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates
+}
+
+// lootTableDisclosure formats the drop rates of a loot table for display.
+func lootTableDisclosure(rates []*lootTableDropRate, names map[string]string) []*DropRate {
+	drops := make([]*DropRate, 0, len(rates))
+	for _, rate := range rates {
+		drop := &DropRate{
+			Tier:        rate.Tier,
+			Currency:    rate.Currency,
+			Amount:      rate.Amount,
+			Nothing:     rate.Item == nil && rate.Currency == common.EmptyString,
+			Probability: rate.Probability,
+			Display:     formatDropRate(rate.Probability),
+		}
+		if rate.Item != nil {
+			drop.Item = rate.Item.Name
+			drop.Name = names[rate.Item.Name]
+		}
+		drops = append(drops, drop)
+	}
+	return drops
+}
+
+// lootItemNames maps the item names of the drop table to their names in its localized copy.
+func lootItemNames(rarity, localized *common.Rarity) map[string]string {
+	names := make(map[string]string)
+	localizedTiers := rarityTiers(localized)
+	for i, tier := range rarityTiers(rarity) {
+		for j, item := range tier.Items.Items {
+			names[item.Name] = localizedTiers[i].Items.Items[j].Name
+		}
+	}
+	return names
+}
+
+// formatDropRate formats a probability as a percentage with at least two decimals and four significant digits, e.g.
+// 12.50% or 0.01667%.
+func formatDropRate(probability float64) string {
+	percent := probability * 100
+	if percent <= 0 {
+		return "0%"
+	}
+
+	decimals := max(2, 3-int(math.Floor(math.Log10(percent))))
+	return strconv.FormatFloat(percent, 'f', decimals, 64) + "%"
+}
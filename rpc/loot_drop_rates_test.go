@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func TestDropRates_AgreeWithRollLoot(t *testing.T) {
+	// Setup
+	rarity := &common.Rarity{
+		Common: common.RarityItems{Chance: 0.7, Items: []common.Item{
+			{Name: "Wooden Sword", Durability: 1, Weight: 3},
+			{Name: "Leather Armor", Durability: 1},
+		}},
+		Rare: common.RarityItems{Chance: 0.3, Items: []common.Item{
+			{Name: "Dragon Shield", Durability: 1},
+		}, Pity: &common.PityRule{SoftPity: 2, SoftPityStep: 0.1}},
+	}
+	tiers := applyPity(lootTiers(rarity), map[string]int{"rare": 2})
+
+	// Call the function
+	rates := dropRates(tiers, nil)
+
+	// Assertions, every pair of draws on a regular grid is rolled and the frequencies compared with the rates
+	const steps = 400
+	frequencies := make(map[string]float64)
+	for i := 0; i < steps; i++ {
+		for j := 0; j < steps; j++ {
+			draws := []float64{(float64(i) + 0.5) / steps, (float64(j) + 0.5) / steps}
+			roll := rollLoot(tiers, &sequenceLootRandom{draws: draws})
+			frequencies[roll.Item.Name] += 1.0 / (steps * steps)
+		}
+	}
+
+	total := 0.0
+	for _, tier := range rates {
+		for _, item := range tier.Items {
+			assert.InDelta(t, frequencies[item.Item], item.Probability, 1.0/steps, item.Item)
+			total += item.Probability
+		}
+	}
+	assert.InDelta(t, 1.0, total, 1e-9)
+	assert.InDelta(t, 0.5, rates[1].Probability, 1e-9)
+	assert.Equal(t, "Dragon Shield", rates[1].Items[0].Name)
+}
+
+func TestFormatDropRate(t *testing.T) {
+	tests := []struct {
+		probability float64
+		display     string
+	}{
+		{probability: 1, display: "100.00%"},
+		{probability: 0.125, display: "12.50%"},
+		{probability: 0.05, display: "5.000%"},
+		{probability: 1.0 / 6000, display: "0.01667%"},
+		{probability: 0, display: "0%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.display, func(t *testing.T) {
+			// Call the function and assert
+			assert.Equal(t, tt.display, formatDropRate(tt.probability))
+		})
+	}
+}
+
+func TestReadDropRates_Success(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadDropRates RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	config := embeddedGameConfig(t)
+	config.Rarity.Legendary.Pity = &common.PityRule{SoftPity: 10, SoftPityStep: 0.05, HardPity: 20}
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, pityCountersRead(userID)).Return([]*api.StorageObject{
+		{Value: `{"counters":{"legendary":10}}`},
+	}, nil)
+	nk.On("AccountGetId", ctx, userID).Return(&api.Account{User: &api.User{Id: userID, LangTag: "es"}}, nil)
+
+	// Call the function
+	result, err := ReadDropRates(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp ReadDropRatesResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, 4, resp.ConfigVersion)
+	assert.Equal(t, "es", resp.Language)
+
+	base := resp.Base[3]
+	assert.Equal(t, "legendary", base.Tier)
+	assert.Equal(t, "5.000%", base.Display)
+	assert.Equal(t, config.Rarity.Legendary.Pity, base.Pity)
+	assert.Equal(t, &DropRateItem{Item: "Excalibur", Name: "Excalibur", Weight: 1, Probability: 0.025, Display: "2.500%"}, base.Items[0])
+	assert.Equal(t, "Armadura del fénix", base.Items[1].Name)
+	assert.Equal(t, "25.00%", resp.Base[0].Items[0].Display)
+
+	// The 11th roll since the last legendary drop is the second one past soft pity
+	assert.Equal(t, "15.00%", resp.Current[3].Display)
+	assert.Equal(t, "7.500%", resp.Current[3].Items[0].Display)
+	assert.Equal(t, "44.74%", resp.Current[0].Display)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+)
+
+// applyPity returns the tiers with the chances they are rolled with given the player's pity counters. Tiers past
+// their soft pity keep their raised chance while the others share the rest in proportion to their chance, and once
+// a tier reaches its hard pity only it and the rarer tiers can drop.
+func applyPity(tiers []lootTier, counters map[string]int) []lootTier {
+	result := make([]lootTier, len(tiers))
+	copy(result, tiers)
+
+	guaranteed := -1
+	raised := make([]bool, len(result))
+	raisedSum, othersSum := 0.0, 0.0
+	for i := range result {
+		tier := &result[i]
+		rule := tier.Pity
+		roll := counters[tier.Name] + 1
+
+		if rule != nil && rule.HardPity > 0 && roll >= rule.HardPity {
+			guaranteed = i
+		}
+		if rule != nil && rule.SoftPity > 0 && roll >= rule.SoftPity {
+			tier.Chance = math.Min(1, tier.Chance+rule.SoftPityStep*float64(roll-rule.SoftPity+1))
+			raised[i] = true
+			raisedSum += tier.Chance
+		} else {
+			othersSum += tier.Chance
+		}
+	}
+
+	if raisedSum > 0 && othersSum > 0 {
+		scale := math.Max(0, 1-raisedSum) / othersSum
+		for i := range result {
+			if !raised[i] {
+				result[i].Chance *= scale
+			}
+		}
+	}
+
+	// The tiers less rare than the rarest guaranteed one cannot drop
+	if guaranteed >= 0 {
+		remaining := 0.0
+		for i := range result {
+			if i < guaranteed {
+				result[i].Chance = 0
+			}
+			remaining += result[i].Chance
+		}
+		if remaining == 0 {
+			// The raised tiers have crowded out the guaranteed tier
+			result[guaranteed].Chance = 1
+		}
+	}
+
+	return result
+}
+
+// advancePityCounters counts the roll for every tier, resetting the counters of the dropped tier and of the less rare
+// tiers, which the drop satisfies as well.
+func advancePityCounters(counters map[string]int, rarity *common.Rarity, dropped string) map[string]int {
+	advanced := make(map[string]int)
+
+	tiers := rarityTiers(rarity)
+	droppedRank := len(tiers)
+	for i, tier := range tiers {
+		if tier.Name == dropped {
+			droppedRank = i
+		}
+	}
+
+	for i, tier := range tiers {
+		if i <= droppedRank {
+			advanced[tier.Name] = 0
+		} else {
+			advanced[tier.Name] = counters[tier.Name] + 1
+		}
+	}
+
+	return advanced
+}
+
+// pityViolations returns the semantic violations of the pity rule of a tier.
+func pityViolations(tierPath string, tier *common.RarityItems) []*common.ConfigViolation {
+	var violations configViolations
+
+	rule := tier.Pity
+	if rule == nil {
+		return nil
+	}
+	pityPath := tierPath + ".pity"
+
+	if rule.SoftPity == 0 && rule.HardPity == 0 {
+		violations.add(pityPath, "must set soft_pity or hard_pity")
+	}
+	if tier.Chance <= 0 {
+		violations.add(pityPath, "tier that cannot drop must not have pity")
+	}
+	if rule.SoftPity < 0 {
+		violations.add(pityPath+".soft_pity", "must be positive, got %d", rule.SoftPity)
+	}
+	if rule.HardPity < 0 {
+		violations.add(pityPath+".hard_pity", "must be positive, got %d", rule.HardPity)
+	}
+	if rule.SoftPity > 0 && (rule.SoftPityStep <= 0 || rule.SoftPityStep > 1 || math.IsNaN(rule.SoftPityStep)) {
+		violations.add(pityPath+".soft_pity_step", "must be between 0 and 1 when soft_pity is set, got %v", rule.SoftPityStep)
+	}
+	if rule.SoftPity == 0 && rule.SoftPityStep != 0 {
+		violations.add(pityPath+".soft_pity_step", "must not be set without soft_pity")
+	}
+	if rule.SoftPity > 0 && rule.HardPity > 0 && rule.HardPity <= rule.SoftPity {
+		violations.add(pityPath+".hard_pity", "must be greater than soft_pity %d, got %d", rule.SoftPity, rule.HardPity)
+	}
+
+	return violations
+}
+
+// readPityCounters reads the pity counters of the user along with their storage version.
+func readPityCounters(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string) (*common.PityCounters, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageLoot,
+		Key:        common.StorageLootPityKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+
+	counters := &common.PityCounters{}
+	storageVersion := common.EmptyString
+	if len(objects) > 0 {
+		if err = json.Unmarshal([]byte(objects[0].GetValue()), counters); err != nil {
+			logger.Error("Cannot unmarshal pity counters: %+v", err)
+			return nil, common.EmptyString, common.ErrUnMarshallingError
+		}
+		storageVersion = objects[0].GetVersion()
+	}
+	if counters.Counters == nil {
+		counters.Counters = make(map[string]int)
+	}
+
+	return counters, storageVersion, nil
+}
+
+// pityCountersWrite builds the conditional write of the user's pity counters, guarded by the storage version they
+// were read at.
+func pityCountersWrite(logger runtime.Logger, userID string, counters *common.PityCounters, storageVersion string) (*runtime.StorageWrite, error) {
+	countersJSON, err := json.Marshal(counters)
+	if err != nil {
+		logger.Error("Cannot marshal pity counters: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	if storageVersion == common.EmptyString {
+		storageVersion = "*"
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageLoot,
+		Key:             common.StorageLootPityKey,
+		UserID:          userID,
+		Value:           string(countersJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_OWNER_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
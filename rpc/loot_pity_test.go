@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"testing"
+)
+
+func pityRarity() *common.Rarity {
+	item := []common.Item{{Name: "Item", Damage: 1, Durability: 1}}
+	return &common.Rarity{
+		Common:    common.RarityItems{Chance: 0.6, Items: item},
+		Uncommon:  common.RarityItems{Chance: 0.3, Items: item},
+		Rare:      common.RarityItems{Chance: 0.08, Items: item, Pity: &common.PityRule{HardPity: 10}},
+		Legendary: common.RarityItems{Chance: 0.02, Items: item, Pity: &common.PityRule{SoftPity: 50, SoftPityStep: 0.1, HardPity: 60}},
+	}
+}
+
+func tierChances(tiers []lootTier) map[string]float64 {
+	chances := make(map[string]float64, len(tiers))
+	for _, tier := range tiers {
+		chances[tier.Name] = tier.Chance
+	}
+	return chances
+}
+
+func TestApplyPity_BaseChancesBelowThresholds(t *testing.T) {
+	// Call the function
+	tiers := applyPity(lootTiers(pityRarity()), map[string]int{"rare": 8, "legendary": 48})
+
+	// Assertions
+	assert.Equal(t, map[string]float64{"common": 0.6, "uncommon": 0.3, "rare": 0.08, "legendary": 0.02}, tierChances(tiers))
+}
+
+func TestApplyPity_SoftPityRaisesChanceProgressively(t *testing.T) {
+	// Call the function
+	tiers := applyPity(lootTiers(pityRarity()), map[string]int{"legendary": 50})
+
+	// Assertions, the 51st roll is the second one past soft pity
+	chances := tierChances(tiers)
+	assert.InDelta(t, 0.22, chances["legendary"], 1e-9)
+	assert.InDelta(t, 0.6*0.78/0.98, chances["common"], 1e-9)
+	assert.InDelta(t, 1.0, chances["common"]+chances["uncommon"]+chances["rare"]+chances["legendary"], 1e-9)
+}
+
+func TestApplyPity_HardPityGuaranteesTierOrRarer(t *testing.T) {
+	// Call the function
+	tiers := applyPity(lootTiers(pityRarity()), map[string]int{"rare": 9})
+
+	// Assertions
+	chances := tierChances(tiers)
+	assert.Equal(t, 0.0, chances["common"])
+	assert.Equal(t, 0.0, chances["uncommon"])
+	assert.Equal(t, 0.08, chances["rare"])
+	assert.Equal(t, 0.02, chances["legendary"])
+
+	// Whatever the draw, the roll cannot be below rare
+	roll := rollLoot(tiers, &sequenceLootRandom{draws: []float64{0, 0}})
+	assert.Equal(t, "rare", roll.Tier)
+}
+
+func TestApplyPity_HardPityOfRarestTierWins(t *testing.T) {
+	// Call the function
+	tiers := applyPity(lootTiers(pityRarity()), map[string]int{"rare": 20, "legendary": 59})
+
+	// Assertions
+	roll := rollLoot(tiers, &sequenceLootRandom{draws: []float64{0, 0}})
+	assert.Equal(t, "legendary", roll.Tier)
+}
+
+func TestAdvancePityCounters_ResetsDroppedAndLessRareTiers(t *testing.T) {
+	// Setup
+	counters := map[string]int{"common": 0, "uncommon": 2, "rare": 5, "legendary": 40}
+
+	// Call the function
+	advanced := advancePityCounters(counters, pityRarity(), "rare")
+
+	// Assertions
+	assert.Equal(t, map[string]int{"common": 0, "uncommon": 0, "rare": 0, "legendary": 41}, advanced)
+	assert.Equal(t, 5, counters["rare"], "the source counters must not be modified")
+}
+
+func TestValidateGameConfig_PityRules(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Rarity.Rare.Pity = &common.PityRule{SoftPity: 20, HardPity: 10}
+	config.Rarity.Legendary.Pity = &common.PityRule{}
+
+	// Call the function
+	violations := gameConfigViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "rarity.rare.pity.soft_pity_step", Message: "must be between 0 and 1 when soft_pity is set, got 0"},
+		{Path: "rarity.rare.pity.hard_pity", Message: "must be greater than soft_pity 20, got 10"},
+		{Path: "rarity.legendary.pity", Message: "must set soft_pity or hard_pity"},
+	}, violations)
+}
@@ -0,0 +1,90 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const (
+	// Size in bytes of the seeds loot rolls are drawn from
+	lootSeedSize = 32
+)
+
+type (
+	// LootRandom draws the uniform random numbers loot rolls are decided with.
+	LootRandom interface {
+		// Float64 returns the next draw in [0, 1).
+		Float64() float64
+	}
+
+	// seededLootRandom derives the draws of a roll from its seed: draw n is the first 53 bits of SHA-256(seed || n)
+	// as a fraction of 2^53, so a roll can be replayed from its seed alone.
+	seededLootRandom struct {
+		seed  []byte
+		draws int
+	}
+
+	// recordingLootRandom records the draws of a random source for the audit log of the roll.
+	recordingLootRandom struct {
+		random LootRandom
+		draws  []float64
+	}
+)
+
+// NewLootRandom returns the random source of a loot roll, seeded from the cryptographic random number generator.
+var NewLootRandom = func() (LootRandom, error) {
+	seed, err := newLootSeed()
+	if err != nil {
+		return nil, err
+	}
+	return newSeededLootRandom(seed), nil
+}
+
+// newLootSeed reads a seed from the cryptographic random number generator.
+func newLootSeed() ([]byte, error) {
+	seed := make([]byte, lootSeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// newSeededLootRandom returns the deterministic random source of the seed.
+func newSeededLootRandom(seed []byte) *seededLootRandom {
+	return &seededLootRandom{seed: seed}
+}
+
+func (r *seededLootRandom) Float64() float64 {
+	block := make([]byte, len(r.seed)+8)
+	copy(block, r.seed)
+	binary.BigEndian.PutUint64(block[len(r.seed):], uint64(r.draws))
+	r.draws++
+
+	sum := sha256.Sum256(block)
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// Seed returns the seed the draws are derived from.
+func (r *seededLootRandom) Seed() []byte {
+	return r.seed
+}
+
+// newRecordingLootRandom records the draws of the random source.
+func newRecordingLootRandom(random LootRandom) *recordingLootRandom {
+	return &recordingLootRandom{random: random, draws: []float64{}}
+}
+
+func (r *recordingLootRandom) Float64() float64 {
+	draw := r.random.Float64()
+	r.draws = append(r.draws, draw)
+	return draw
+}
+
+// Seed returns the seed of the recorded random source, nil if its draws cannot be derived from a seed.
+func (r *recordingLootRandom) Seed() []byte {
+	if seeded, ok := r.random.(interface{ Seed() []byte }); ok {
+		return seeded.Seed()
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"time"
+)
+
+type (
+	RollLootRequest struct {
+		Table string `json:"table,omitempty"`
+	}
+
+	RollLootTableRequest struct {
+		UserID string `json:"user_id"`
+		Table  string `json:"table"`
+	}
+
+	RollLootResponse struct {
+		Table         string               `json:"table,omitempty"`
+		Tier          string               `json:"tier,omitempty"`
+		Item          *common.Item         `json:"item,omitempty"`
+		Instance      *common.ItemInstance `json:"instance,omitempty"`
+		Currency      string               `json:"currency,omitempty"`
+		Amount        int64                `json:"amount,omitempty"`
+		ConfigVersion int                  `json:"config_version"`
+		Pity          map[string]int       `json:"pity,omitempty"`
+		RollID        string               `json:"roll_id"`
+	}
+
+	// lootTier is a rarity tier that can drop, weighted by its chance.
+	lootTier struct {
+		Name   string
+		Chance float64
+		Items  []common.Item
+		Pity   *common.PityRule
+	}
+
+	// lootRoll is the outcome of a loot roll.
+	lootRoll struct {
+		Tier string
+		Item common.Item
+	}
+)
+
+// RollLoot rolls the caller's effective drop table, or the named loot table if the payload names one, on the server
+// and grants what it drops. Players pay the cost of the drop table or of the loot table, and cannot roll the tables
+// without one.
+func RollLoot(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("RollLoot RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req RollLootRequest
+	if payload != common.EmptyString {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			logger.Error("Cannot unmarshal payload: %+v", err)
+			return common.EmptyString, common.ErrUnMarshallingError
+		}
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	// Roll the drop table the player sees, with its experiment variants and the live-ops overrides in effect
+	now := time.Now().Unix()
+	config, derivation, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	if violations := rarityViolations(&config.Rarity); len(violations) > 0 {
+		for _, violation := range violations {
+			logger.Error("Cannot roll loot, invalid drop table at %s: %s", violation.Path, violation.Message)
+		}
+		return common.EmptyString, common.ErrInvalidLootTable
+	}
+
+	if req.Table != common.EmptyString {
+		table, ok := config.LootTables[req.Table]
+		if ok && len(table.Cost) == 0 {
+			logger.Error("Loot table %s can only be rolled by the server", req.Table)
+			return common.EmptyString, common.ErrS2SPermissionDenied
+		}
+		return rollUserLootTable(ctx, logger, nk, userID, active.Version, config, derivation, req.Table, table.Cost, now)
+	}
+	if len(config.Rarity.Cost) == 0 {
+		logger.Error("The drop table can only be rolled by the server")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	pity, pityVersion, err := readPityCounters(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	seeded, err := NewLootRandom()
+	if err != nil {
+		logger.Error("Cannot seed loot roll: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+	random := newRecordingLootRandom(seeded)
+	tiers := applyPity(lootTiers(&config.Rarity), pity.Counters)
+	roll := rollLoot(tiers, random)
+
+	instance, err := newItemInstance(config, active.Version, derivation, &roll.Item, roll.Tier, common.ItemSourceLootRoll, now)
+	if err != nil {
+		logger.Error("Cannot create item instance: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+	if err = addInventoryItem(logger, config, userID, inventory, instance); err != nil {
+		return common.EmptyString, err
+	}
+	record := newLootRollRecord(userID, now, active.Version, &config.Rarity, pity.Counters, tiers, random, roll, instance)
+	pity.Counters = advancePityCounters(pity.Counters, &config.Rarity, roll.Tier)
+
+	// The objects are written and the cost is charged in one transaction guarded by the versions the objects were read
+	// at, so concurrent rolls cannot grant items against the same pity counters and no roll is granted unpaid or
+	// without its audit record
+	writeInventory, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	writePity, err := pityCountersWrite(logger, userID, pity, pityVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	writeRecord, err := lootRollRecordWrite(logger, record)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	walletUpdate := &runtime.WalletUpdate{
+		UserID:    userID,
+		Changeset: negateCurrencies(config.Rarity.Cost),
+		Metadata:  map[string]interface{}{"source": common.ItemSourceLootRoll, "roll_id": record.ID},
+	}
+	writes := []*runtime.StorageWrite{writeInventory, writePity, writeRecord}
+	if err = updatePlayerData(ctx, logger, nk, writes, nil, []*runtime.WalletUpdate{walletUpdate}); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s rolled %s item %s", userID, roll.Tier, roll.Item.Name)
+
+	return marshalResponse(logger, &RollLootResponse{
+		Tier:          roll.Tier,
+		Item:          &roll.Item,
+		Instance:      instance,
+		ConfigVersion: active.Version,
+		Pity:          pity.Counters,
+		RollID:        record.ID,
+	})
+}
+
+// S2SRollLootTable rolls a named loot table for a player free of charge, e.g. the reward of a boss the game server
+// saw them defeat, and grants what it drops.
+func S2SRollLootTable(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("S2SRollLootTable RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	var req RollLootTableRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.UserID == common.EmptyString || req.Table == common.EmptyString {
+		logger.Error("Payload did not contain a user ID and a loot table")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	if err := checkUserExists(ctx, logger, nk, req.UserID); err != nil {
+		return common.EmptyString, err
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	// Roll the loot table the player sees, with its experiment variants and the live-ops overrides in effect
+	now := time.Now().Unix()
+	config, derivation, err := deriveUserGameConfig(ctx, logger, nk, req.UserID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	return rollUserLootTable(ctx, logger, nk, req.UserID, active.Version, config, derivation, req.Table, nil, now)
+}
+
+// rollUserLootTable rolls the named loot table of the user's effective configuration, charging the user the cost if
+// any, and grants what it drops: an item instance added to the inventory, or currency added to the wallet.
+func rollUserLootTable(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, configVersion int, config *common.GameConfig, derivation *gameConfigDerivation, table string, cost map[string]int64, now int64) (string, error) {
+	if _, ok := config.LootTables[table]; !ok {
+		logger.Error("Loot table %s not found", table)
+		return common.EmptyString, common.ErrNotFound
+	}
+	if violations := lootTableViolations(&config.Rarity, config.LootTables); len(violations) > 0 {
+		for _, violation := range violations {
+			logger.Error("Cannot roll loot, invalid loot table at %s: %s", violation.Path, violation.Message)
+		}
+		return common.EmptyString, common.ErrInvalidLootTable
+	}
+
+	seeded, err := NewLootRandom()
+	if err != nil {
+		logger.Error("Cannot seed loot roll: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+	random := newRecordingLootRandom(seeded)
+	drop := rollLootTable(&config.Rarity, config.LootTables, table, random)
+
+	rollID, err := newRandomID()
+	if err != nil {
+		logger.Error("Cannot create loot roll ID: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+
+	var writes []*runtime.StorageWrite
+	var walletUpdates []*runtime.WalletUpdate
+	if len(cost) > 0 {
+		walletUpdates = append(walletUpdates, &runtime.WalletUpdate{
+			UserID:    userID,
+			Changeset: negateCurrencies(cost),
+			Metadata:  map[string]interface{}{"source": common.ItemSourceLootRoll, "table": table, "roll_id": rollID},
+		})
+	}
+	var instance *common.ItemInstance
+	switch {
+	case drop.Item != nil:
+		inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+		if err != nil {
+			return common.EmptyString, err
+		}
+		if instance, err = newItemInstance(config, configVersion, derivation, drop.Item, drop.Tier, common.ItemSourceLootRoll, now); err != nil {
+			logger.Error("Cannot create item instance: %+v", err)
+			return common.EmptyString, common.ErrInternalError
+		}
+		if err = addInventoryItem(logger, config, userID, inventory, instance); err != nil {
+			return common.EmptyString, err
+		}
+
+		writeInventory, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+		if err != nil {
+			return common.EmptyString, err
+		}
+		writes = append(writes, writeInventory)
+
+	case drop.Currency != common.EmptyString:
+		walletUpdates = append(walletUpdates, &runtime.WalletUpdate{
+			UserID:    userID,
+			Changeset: map[string]int64{drop.Currency: drop.Amount},
+			Metadata:  map[string]interface{}{"source": common.ItemSourceLootRoll, "table": table, "roll_id": rollID},
+		})
+	}
+
+	record := newLootTableRollRecord(lootRollKey(now, rollID), userID, now, configVersion, config, table, random, drop, instance)
+	writeRecord, err := lootRollRecordWrite(logger, record)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	writes = append(writes, writeRecord)
+
+	// The cost, the grant and its audit record are written in one transaction
+	if err = updatePlayerData(ctx, logger, nk, writes, nil, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s rolled loot table %s: %s", userID, table, drop.key())
+
+	return marshalResponse(logger, &RollLootResponse{
+		Table:         table,
+		Tier:          drop.Tier,
+		Item:          drop.Item,
+		Instance:      instance,
+		Currency:      drop.Currency,
+		Amount:        drop.Amount,
+		ConfigVersion: configVersion,
+		RollID:        record.ID,
+	})
+}
+
+// rollLoot picks a tier weighted by the chances of the tiers, then an item of the tier weighted by the item weights,
+// using one draw each.
+func rollLoot(tiers []lootTier, random LootRandom) *lootRoll {
+	tier := tiers[pickWeighted(lootTierWeights(tiers), random.Float64())]
+	item := tier.Items[pickWeighted(lootItemWeights(tier.Items), random.Float64())]
+
+	return &lootRoll{Tier: tier.Name, Item: item}
+}
+
+// lootTierWeights returns the weights the tiers are picked with.
+func lootTierWeights(tiers []lootTier) []float64 {
+	weights := make([]float64, len(tiers))
+	for i, tier := range tiers {
+		weights[i] = tier.Chance
+	}
+	return weights
+}
+
+// lootItemWeights returns the weights the items of a tier are picked with.
+func lootItemWeights(items []common.Item) []float64 {
+	weights := make([]float64, len(items))
+	for i := range items {
+		weights[i] = lootItemWeight(&items[i])
+	}
+	return weights
+}
+
+// lootTiers returns the tiers of a valid drop table that can drop, from the most to the least common.
+func lootTiers(rarity *common.Rarity) []lootTier {
+	var tiers []lootTier
+	for _, tier := range rarityTiers(rarity) {
+		if tier.Items.Chance > 0 && len(tier.Items.Items) > 0 {
+			tiers = append(tiers, lootTier{Name: tier.Name, Chance: tier.Items.Chance, Items: tier.Items.Items, Pity: tier.Items.Pity})
+		}
+	}
+	return tiers
+}
+
+// lootItemWeight returns the relative weight of the item within its tier.
+func lootItemWeight(item *common.Item) float64 {
+	if item.Weight == 0 {
+		return 1
+	}
+	return item.Weight
+}
+
+// pickWeighted returns the index of the weight the draw in [0, 1) falls on when the weights are laid end to end and
+// scaled to sum to 1, so a drop table whose chances sum to 1 within the validation tolerance is rolled exactly.
+func pickWeighted(weights []float64, draw float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	target := draw * total
+	cumulative := 0.0
+	for i, weight := range weights {
+		cumulative += weight
+		if target < cumulative {
+			return i
+		}
+	}
+
+	// Only reached through rounding when the draw is close to 1
+	return len(weights) - 1
+}
+
+// weightedProbabilities returns the probability pickWeighted picks each weight with given a uniform draw.
+func weightedProbabilities(weights []float64) []float64 {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	probabilities := make([]float64, len(weights))
+	for i, weight := range weights {
+		probabilities[i] = weight / total
+	}
+	return probabilities
+}
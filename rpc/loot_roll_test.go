@@ -0,0 +1,261 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+// sequenceLootRandom replays a fixed sequence of draws.
+type sequenceLootRandom struct {
+	draws []float64
+}
+
+func (r *sequenceLootRandom) Float64() float64 {
+	draw := r.draws[0]
+	r.draws = r.draws[1:]
+	return draw
+}
+
+func mockLootRandom(t *testing.T, draws ...float64) {
+	original := NewLootRandom
+	NewLootRandom = func() (LootRandom, error) {
+		return &sequenceLootRandom{draws: draws}, nil
+	}
+	t.Cleanup(func() { NewLootRandom = original })
+}
+
+func pityCountersRead(userID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageLoot,
+		Key:        common.StorageLootPityKey,
+		UserID:     userID,
+	}}
+}
+
+func inventoryRead(userID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageInventory,
+		Key:        common.StorageInventoryItemsKey,
+		UserID:     userID,
+	}}
+}
+
+func TestRollLoot_PicksTierThenItem(t *testing.T) {
+	// Setup
+	rarity := &embeddedGameConfig(t).Rarity
+
+	tests := []struct {
+		name  string
+		draws []float64
+		tier  string
+		item  string
+	}{
+		{name: "first common item", draws: []float64{0, 0}, tier: "common", item: "Wooden Sword"},
+		{name: "second common item", draws: []float64{0.49, 0.5}, tier: "common", item: "Leather Armor"},
+		{name: "uncommon", draws: []float64{0.5, 0.2}, tier: "uncommon", item: "Iron Sword"},
+		{name: "rare", draws: []float64{0.8, 0.99}, tier: "rare", item: "Dragon Shield"},
+		{name: "legendary", draws: []float64{0.96, 0}, tier: "legendary", item: "Excalibur"},
+		{name: "highest draw", draws: []float64{0.9999999999, 0.9999999999}, tier: "legendary", item: "Phoenix Armor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the function
+			roll := rollLoot(lootTiers(rarity), &sequenceLootRandom{draws: tt.draws})
+
+			// Assertions
+			assert.Equal(t, tt.tier, roll.Tier)
+			assert.Equal(t, tt.item, roll.Item.Name)
+		})
+	}
+}
+
+func TestRollLoot_SkipsTiersThatCannotDropAndWeighsItems(t *testing.T) {
+	// Setup
+	rarity := &common.Rarity{
+		Common: common.RarityItems{Chance: 0},
+		Rare: common.RarityItems{Chance: 1, Items: []common.Item{
+			{Name: "Steel Sword", Durability: 1, Weight: 3},
+			{Name: "Dragon Shield", Durability: 1},
+		}},
+	}
+
+	// Call the function
+	heavy := rollLoot(lootTiers(rarity), &sequenceLootRandom{draws: []float64{0, 0.74}})
+	light := rollLoot(lootTiers(rarity), &sequenceLootRandom{draws: []float64{0, 0.75}})
+
+	// Assertions
+	assert.Equal(t, "rare", heavy.Tier)
+	assert.Equal(t, "Steel Sword", heavy.Item.Name)
+	assert.Equal(t, "Dragon Shield", light.Item.Name)
+}
+
+func TestSeededLootRandom_IsDeterministic(t *testing.T) {
+	// Setup
+	seed := []byte("0123456789abcdef0123456789abcdef")
+	first := newSeededLootRandom(seed)
+	second := newSeededLootRandom(seed)
+
+	// Call the function
+	draws := []float64{first.Float64(), first.Float64()}
+
+	// Assertions
+	assert.Equal(t, draws, []float64{second.Float64(), second.Float64()})
+	assert.NotEqual(t, draws[0], draws[1])
+	for _, draw := range draws {
+		assert.GreaterOrEqual(t, draw, 0.0)
+		assert.Less(t, draw, 1.0)
+	}
+}
+
+func TestRollLootRPC_GrantsItem(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Info", "User %s rolled %s item %s", "user123", "legendary", "Excalibur").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+	mockLootRandom(t, 0.97, 0.1)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, pityCountersRead(userID)).Return([]*api.StorageObject{
+		{Value: `{"counters":{"common":0,"uncommon":3,"rare":7,"legendary":20}}`, Version: "p1"},
+	}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return([]*api.StorageObject{
+		{Value: `{"items":[{"id":"a","item":"Wooden Sword","rarity":"common","durability":100,"source":"loot_roll","acquired_at":1}]}`, Version: "v1"},
+	}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		var record common.LootRollRecord
+		_ = json.Unmarshal([]byte(writes[2].Value), &record)
+		return len(writes) == 3 &&
+			writes[0].Collection == common.StorageInventory &&
+			writes[0].UserID == userID &&
+			writes[0].Version == "v1" &&
+			len(inventory.Items) == 2 &&
+			inventory.Items[1].Item == "Excalibur" &&
+			inventory.Items[1].Durability == 500 &&
+			writes[1].Collection == common.StorageLoot &&
+			writes[1].Version == "p1" &&
+			writes[1].Value == `{"counters":{"common":0,"legendary":0,"rare":0,"uncommon":0}}` &&
+			writes[2].Collection == common.StorageLootAudit &&
+			writes[2].Version == "*" &&
+			writes[2].Key == record.ID &&
+			writes[2].UserID == userID &&
+			record.InstanceID == inventory.Items[1].ID &&
+			record.ConfigVersion == 4 &&
+			record.Pity["legendary"] == 20 &&
+			record.Tier == "legendary" &&
+			len(record.Draws) == 2 && record.Draws[0] == 0.97
+	}), []*runtime.StorageDelete(nil), mock.MatchedBy(func(updates []*runtime.WalletUpdate) bool {
+		// The player pays the cost of the drop table
+		return len(updates) == 1 && updates[0].UserID == userID && updates[0].Changeset["gold"] == -10
+	}), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RollLootResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "legendary", resp.Tier)
+	assert.Equal(t, "Excalibur", resp.Item.Name)
+	assert.Equal(t, 4, resp.ConfigVersion)
+	assert.Equal(t, map[string]int{"common": 0, "uncommon": 0, "rare": 0, "legendary": 0}, resp.Pity)
+	assert.Equal(t, lootRollKey(resp.Instance.AcquiredAt, resp.Instance.ID), resp.RollID)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, resp.Instance.ID)
+	assert.Equal(t, common.ItemSourceLootRoll, resp.Instance.Source)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollLootRPC_InvalidDropTable(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Error", "Cannot roll loot, invalid drop table at %s: %s", "rarity", "chances must sum to 1.0, got 0.9").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	config := embeddedGameConfig(t)
+	config.Rarity.Common.Chance = 0.4
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidLootTable, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollLootRPC_DropTableWithoutCost(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Error", "The drop table can only be rolled by the server").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	config := embeddedGameConfig(t)
+	config.Rarity.Cost = nil
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *config})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollLootRPC_ConcurrentRoll(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Error", "Player data was modified concurrently: %+v", runtime.ErrStorageRejectedVersion).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+	mockLootRandom(t, 0, 0)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, pityCountersRead(userID)).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 3 && writes[0].Version == "*" && writes[1].Version == "*"
+	}), []*runtime.StorageDelete(nil), mock.Anything, true).Return(nil, nil, runtime.ErrStorageRejectedVersion)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, "")
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrPlayerDataConflict, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"fmt"
+	"oak/common"
+	"strings"
+)
+
+type (
+	// LootSimulation rolls a drop table offline through the code roll_loot decides rolls with, keeping the pity
+	// counters of a simulated player from roll to roll.
+	LootSimulation struct {
+		rarity   *common.Rarity
+		tiers    []lootTier
+		pity     bool
+		counters map[string]int
+		random   LootRandom
+	}
+
+	// LootDropRate is the declared probability of an item of a drop table.
+	LootDropRate struct {
+		Tier        string
+		Item        string
+		Probability float64
+	}
+)
+
+// NewLootSimulation returns a simulation of the drop table drawing from the seed, with or without its pity rules.
+func NewLootSimulation(rarity *common.Rarity, seed []byte, pity bool) (*LootSimulation, error) {
+	if violations := rarityViolations(rarity); len(violations) > 0 {
+		messages := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			messages = append(messages, violation.Path+": "+violation.Message)
+		}
+		return nil, fmt.Errorf("invalid drop table: %s", strings.Join(messages, "; "))
+	}
+
+	tiers := lootTiers(rarity)
+	if !pity {
+		for i := range tiers {
+			tiers[i].Pity = nil
+		}
+	}
+
+	return &LootSimulation{
+		rarity:   rarity,
+		tiers:    tiers,
+		pity:     pity,
+		counters: make(map[string]int),
+		random:   newSeededLootRandom(seed),
+	}, nil
+}
+
+// Reset starts over as a new player without pity progress.
+func (s *LootSimulation) Reset() {
+	s.counters = make(map[string]int)
+}
+
+// Roll rolls the drop table once and returns the tier and the name of the dropped item.
+func (s *LootSimulation) Roll() (string, string) {
+	roll := rollLoot(applyPity(s.tiers, s.counters), s.random)
+	if s.pity {
+		s.counters = advancePityCounters(s.counters, s.rarity, roll.Tier)
+	}
+	return roll.Tier, roll.Item.Name
+}
+
+// DropRates returns the declared probability of every item, that of a player without pity progress.
+func (s *LootSimulation) DropRates() []LootDropRate {
+	var rates []LootDropRate
+	for _, tier := range dropRates(applyPity(s.tiers, nil), nil) {
+		for _, item := range tier.Items {
+			rates = append(rates, LootDropRate{Tier: tier.Tier, Item: item.Item, Probability: item.Probability})
+		}
+	}
+	return rates
+}
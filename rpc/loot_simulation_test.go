@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"oak/common"
+	"testing"
+)
+
+func TestLootSimulation_AppliesPityOnlyWhenEnabled(t *testing.T) {
+	// Setup
+	rarity := &common.Rarity{
+		Common:    common.RarityItems{Chance: 0.999, Items: []common.Item{{Name: "Wooden Sword", Durability: 1}}},
+		Legendary: common.RarityItems{Chance: 0.001, Items: []common.Item{{Name: "Excalibur", Damage: 1, Durability: 1}}, Pity: &common.PityRule{HardPity: 3}},
+	}
+	seed := []byte("seed")
+
+	for _, pity := range []bool{true, false} {
+		simulation, err := NewLootSimulation(rarity, seed, pity)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// Call the function
+		var items []string
+		for i := 0; i < 3; i++ {
+			_, item := simulation.Roll()
+			items = append(items, item)
+		}
+
+		// Assertions
+		assert.Equal(t, pity, items[2] == "Excalibur")
+		assert.Equal(t, []LootDropRate{
+			{Tier: "common", Item: "Wooden Sword", Probability: 0.999},
+			{Tier: "legendary", Item: "Excalibur", Probability: 0.001},
+		}, simulation.DropRates())
+	}
+}
+
+func TestNewLootSimulation_InvalidDropTable(t *testing.T) {
+	// Call the function
+	_, err := NewLootSimulation(&common.Rarity{}, []byte("seed"), true)
+
+	// Assertions
+	assert.ErrorContains(t, err, "invalid drop table: ")
+}
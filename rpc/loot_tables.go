@@ -0,0 +1,308 @@
+package rpc
+
+import (
+	"fmt"
+	"math"
+	"oak/common"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type (
+	// lootDrop is the outcome of a loot table roll: an item along with its rarity tier, an amount of a currency, or
+	// nothing when neither is set.
+	lootDrop struct {
+		Item     *common.Item
+		Tier     string
+		Currency string
+		Amount   int64
+	}
+
+	// lootTableDropRate is the probability of a loot table roll to end on a drop.
+	lootTableDropRate struct {
+		lootDrop
+		Probability float64
+	}
+)
+
+// rollLootTable rolls the named loot table, picking a tier weighted by the chances of the tiers then an entry of the
+// tier weighted by the entry weights, with one draw each. An entry referencing another table is replaced by a roll
+// of that table. The tables must have been checked by lootTableViolations.
+func rollLootTable(rarity *common.Rarity, tables map[string]common.LootTable, name string, random LootRandom) *lootDrop {
+	for {
+		tiers := tables[name].Tiers
+		tier := tiers[pickWeighted(lootTableTierWeights(tiers), random.Float64())]
+		entry := tier.Entries[pickWeighted(lootEntryWeights(tier.Entries), random.Float64())]
+
+		if entry.Table == common.EmptyString {
+			return lootEntryDrop(rarity, &entry)
+		}
+		name = entry.Table
+	}
+}
+
+// lootEntryDrop returns what an entry not referencing a table drops.
+func lootEntryDrop(rarity *common.Rarity, entry *common.LootEntry) *lootDrop {
+	switch {
+	case entry.Item != common.EmptyString:
+		item, tier := lootItem(rarity, entry.Item)
+		return &lootDrop{Item: item, Tier: tier}
+	case entry.Currency != common.EmptyString:
+		return &lootDrop{Currency: entry.Currency, Amount: entry.Amount}
+	default:
+		return &lootDrop{}
+	}
+}
+
+// key identifies the drop among the drops of a loot table.
+func (d *lootDrop) key() string {
+	switch {
+	case d.Item != nil:
+		return "item:" + d.Item.Name
+	case d.Currency != common.EmptyString:
+		return fmt.Sprintf("currency:%s:%d", d.Currency, d.Amount)
+	default:
+		return "nothing"
+	}
+}
+
+// lootItem looks an item of the rarity tiers up by name, returning it along with its tier, or nil if there is none.
+func lootItem(rarity *common.Rarity, name string) (*common.Item, string) {
+	for _, tier := range rarityTiers(rarity) {
+		for i := range tier.Items.Items {
+			if tier.Items.Items[i].Name == name {
+				item := tier.Items.Items[i]
+				return &item, tier.Name
+			}
+		}
+	}
+	return nil, common.EmptyString
+}
+
+// lootTableTierWeights returns the weights the tiers of a loot table are picked with.
+func lootTableTierWeights(tiers []common.LootTableTier) []float64 {
+	weights := make([]float64, len(tiers))
+	for i, tier := range tiers {
+		weights[i] = tier.Chance
+	}
+	return weights
+}
+
+// lootEntryWeights returns the weights the entries of a loot table tier are picked with.
+func lootEntryWeights(entries []common.LootEntry) []float64 {
+	weights := make([]float64, len(entries))
+	for i, entry := range entries {
+		weights[i] = entry.Weight
+		if weights[i] == 0 {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
+
+// lootTableDropRates returns the probability rollLootTable ends on every drop of the named loot table, in the order
+// the drops are first reached. The same drop reached through several entries is listed once.
+func lootTableDropRates(rarity *common.Rarity, tables map[string]common.LootTable, name string) []*lootTableDropRate {
+	var rates []*lootTableDropRate
+	index := make(map[string]int)
+
+	var walk func(name string, probability float64)
+	walk = func(name string, probability float64) {
+		tiers := tables[name].Tiers
+		for i, tierProbability := range weightedProbabilities(lootTableTierWeights(tiers)) {
+			entries := tiers[i].Entries
+			for j, entryProbability := range weightedProbabilities(lootEntryWeights(entries)) {
+				entryProbability *= probability * tierProbability
+				if entries[j].Table != common.EmptyString {
+					walk(entries[j].Table, entryProbability)
+					continue
+				}
+
+				drop := lootEntryDrop(rarity, &entries[j])
+				if k, ok := index[drop.key()]; ok {
+					rates[k].Probability += entryProbability
+					continue
+				}
+				index[drop.key()] = len(rates)
+				rates = append(rates, &lootTableDropRate{lootDrop: *drop, Probability: entryProbability})
+			}
+		}
+	}
+	walk(name, 1)
+
+	return rates
+}
+
+// reachableLootTables returns the named loot table along with every table its rolls can reach.
+func reachableLootTables(tables map[string]common.LootTable, name string) map[string]common.LootTable {
+	reachable := make(map[string]common.LootTable)
+	pending := []string{name}
+	for len(pending) > 0 {
+		name, pending = pending[0], pending[1:]
+		table, ok := tables[name]
+		if _, seen := reachable[name]; seen || !ok {
+			continue
+		}
+		reachable[name] = table
+		for _, tier := range table.Tiers {
+			for _, entry := range tier.Entries {
+				if entry.Table != common.EmptyString {
+					pending = append(pending, entry.Table)
+				}
+			}
+		}
+	}
+	return reachable
+}
+
+// lootTableViolations returns the semantic violations of the loot tables, which loot table rolls are checked against
+// as well: malformed tiers and entries, references to unknown items or tables, reference cycles and entries that can
+// never drop.
+func lootTableViolations(rarity *common.Rarity, tables map[string]common.LootTable) []*common.ConfigViolation {
+	var violations configViolations
+
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tablePath := "loot_tables." + name
+		if strings.TrimSpace(name) == common.EmptyString {
+			violations.add(tablePath, "name must not be empty")
+		}
+
+		table := tables[name]
+		for _, currency := range sortedKeys(table.Cost) {
+			if amount := table.Cost[currency]; amount <= 0 {
+				violations.add(tablePath+".cost."+currency, "must be positive, got %d", amount)
+			}
+		}
+		if len(table.Tiers) == 0 {
+			violations.add(tablePath+".tiers", "must not be empty")
+			continue
+		}
+
+		chanceSum := 0.0
+		tierNames := make(map[string]bool, len(table.Tiers))
+		for i, tier := range table.Tiers {
+			tierPath := fmt.Sprintf("%s.tiers[%d]", tablePath, i)
+
+			switch {
+			case strings.TrimSpace(tier.Name) == common.EmptyString:
+				violations.add(tierPath+".name", "must not be empty")
+			case tierNames[tier.Name]:
+				violations.add(tierPath+".name", "duplicates the tier name %q", tier.Name)
+			default:
+				tierNames[tier.Name] = true
+			}
+
+			if tier.Chance < 0 || tier.Chance > 1 || math.IsNaN(tier.Chance) {
+				violations.add(tierPath+".chance", "must be between 0 and 1, got %v", tier.Chance)
+			} else {
+				chanceSum += tier.Chance
+			}
+
+			switch {
+			case len(tier.Entries) == 0 && tier.Chance > 0:
+				violations.add(tierPath+".entries", "must not be empty when the tier can drop")
+			case len(tier.Entries) > 0 && tier.Chance == 0:
+				violations.add(tierPath+".entries", "are unreachable as the tier cannot drop")
+			}
+
+			for j, entry := range tier.Entries {
+				violations = append(violations, lootEntryViolations(fmt.Sprintf("%s.entries[%d]", tierPath, j), rarity, tables, &entry)...)
+			}
+		}
+
+		if math.Abs(chanceSum-1) > rarityChanceEpsilon {
+			violations.add(tablePath+".tiers", "chances must sum to 1.0, got %.6g", chanceSum)
+		}
+	}
+
+	return append(violations, lootTableCycleViolations(tables, names)...)
+}
+
+// lootEntryViolations returns the semantic violations of a loot table entry.
+func lootEntryViolations(entryPath string, rarity *common.Rarity, tables map[string]common.LootTable, entry *common.LootEntry) []*common.ConfigViolation {
+	var violations configViolations
+
+	kinds := 0
+	for _, set := range []bool{entry.Item != common.EmptyString, entry.Table != common.EmptyString, entry.Currency != common.EmptyString, entry.Nothing} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		violations.add(entryPath, "must set exactly one of item, table, currency or nothing")
+	}
+
+	if entry.Item != common.EmptyString {
+		if item, _ := lootItem(rarity, entry.Item); item == nil {
+			violations.add(entryPath+".item", "references the unknown item %q", entry.Item)
+		}
+	}
+	if entry.Table != common.EmptyString {
+		if _, ok := tables[entry.Table]; !ok {
+			violations.add(entryPath+".table", "references the unknown loot table %q", entry.Table)
+		}
+	}
+	if entry.Currency != common.EmptyString && entry.Amount <= 0 {
+		violations.add(entryPath+".amount", "must be positive, got %d", entry.Amount)
+	}
+	if entry.Currency == common.EmptyString && entry.Amount != 0 {
+		violations.add(entryPath+".amount", "must not be set without currency")
+	}
+	if entry.Weight < 0 || math.IsNaN(entry.Weight) || math.IsInf(entry.Weight, 0) {
+		violations.add(entryPath+".weight", "must be a non-negative number, got %v", entry.Weight)
+	}
+
+	return violations
+}
+
+// lootTableCycleViolations reports every table reference closing a cycle, which would let rolls recurse forever.
+func lootTableCycleViolations(tables map[string]common.LootTable, names []string) []*common.ConfigViolation {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var violations configViolations
+	state := make(map[string]int, len(tables))
+	var chain []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		chain = append(chain, name)
+
+		for i, tier := range tables[name].Tiers {
+			for j, entry := range tier.Entries {
+				if _, ok := tables[entry.Table]; !ok {
+					continue
+				}
+				switch state[entry.Table] {
+				case visiting:
+					cycle := append(slices.Clone(chain[slices.Index(chain, entry.Table):]), entry.Table)
+					violations.add(fmt.Sprintf("loot_tables.%s.tiers[%d].entries[%d].table", name, i, j), "reference cycle: %s", strings.Join(cycle, " -> "))
+				case unvisited:
+					visit(entry.Table)
+				}
+			}
+		}
+
+		chain = chain[:len(chain)-1]
+		state[name] = visited
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,309 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func TestRollLootTable_FollowsNestedTables(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	random := newRecordingLootRandom(&sequenceLootRandom{draws: []float64{0, 0.9, 0.7, 0.9}})
+
+	// Call the function
+	drop := rollLootTable(&config.Rarity, config.LootTables, "boss_dragon", random)
+
+	// Assertions, the hoard tier of the dragon rolls the wooden chest, whose gear tier drops the iron sword
+	assert.Equal(t, "Iron Sword", drop.Item.Name)
+	assert.Equal(t, "uncommon", drop.Tier)
+	assert.Len(t, random.draws, 4)
+}
+
+func TestRollLootTable_CurrencyAndNothing(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+
+	// Call the function
+	gold := rollLootTable(&config.Rarity, config.LootTables, "wooden_chest", &sequenceLootRandom{draws: []float64{0.1, 0.9}})
+	nothing := rollLootTable(&config.Rarity, config.LootTables, "wooden_chest", &sequenceLootRandom{draws: []float64{0.1, 0.5}})
+
+	// Assertions
+	assert.Equal(t, &lootDrop{Currency: "gold", Amount: 10}, gold)
+	assert.Equal(t, &lootDrop{}, nothing)
+}
+
+func TestLootTableDropRates_FlattenNestedTables(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+
+	// Call the function
+	rates := lootTableDropRates(&config.Rarity, config.LootTables, "daily_reward")
+
+	// Assertions
+	probabilities := make(map[string]float64)
+	total := 0.0
+	for _, rate := range rates {
+		probabilities[rate.key()] = rate.Probability
+		total += rate.Probability
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+	assert.InDelta(t, 0.75, probabilities["currency:gold:50"], 1e-9)
+	assert.InDelta(t, 0.1, probabilities["nothing"], 1e-9)
+	assert.InDelta(t, 0.05, probabilities["currency:gold:10"], 1e-9)
+	assert.InDelta(t, 0.04, probabilities["item:Wooden Sword"], 1e-9)
+	assert.InDelta(t, 0.02, probabilities["item:Iron Sword"], 1e-9)
+}
+
+func TestLootTableViolations(t *testing.T) {
+	// Setup
+	rarity := &embeddedGameConfig(t).Rarity
+	tables := map[string]common.LootTable{
+		"a": {Tiers: []common.LootTableTier{
+			{Name: "main", Chance: 1, Entries: []common.LootEntry{{Table: "b"}, {Item: "Wooden Sword", Nothing: true}}},
+			{Name: "never", Entries: []common.LootEntry{{Nothing: true}}},
+		}},
+		"b": {Cost: map[string]int64{"gold": 0}, Tiers: []common.LootTableTier{
+			{Name: "main", Chance: 1, Entries: []common.LootEntry{{Table: "a"}, {Table: "missing"}, {Item: "Mjolnir"}, {Currency: "gold"}}},
+		}},
+	}
+
+	// Call the function
+	violations := lootTableViolations(rarity, tables)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "loot_tables.a.tiers[0].entries[1]", Message: "must set exactly one of item, table, currency or nothing"},
+		{Path: "loot_tables.a.tiers[1].entries", Message: "are unreachable as the tier cannot drop"},
+		{Path: "loot_tables.b.cost.gold", Message: "must be positive, got 0"},
+		{Path: "loot_tables.b.tiers[0].entries[1].table", Message: `references the unknown loot table "missing"`},
+		{Path: "loot_tables.b.tiers[0].entries[2].item", Message: `references the unknown item "Mjolnir"`},
+		{Path: "loot_tables.b.tiers[0].entries[3].amount", Message: "must be positive, got 0"},
+		{Path: "loot_tables.b.tiers[0].entries[0].table", Message: "reference cycle: a -> b -> a"},
+	}, violations)
+}
+
+func TestLootTableViolations_WeightBoundary(t *testing.T) {
+	// Setup
+	rarity := &embeddedGameConfig(t).Rarity
+	tables := map[string]common.LootTable{
+		"a": {Tiers: []common.LootTableTier{
+			// A zero weight stands for the default weight of 1
+			{Name: "main", Chance: 1, Entries: []common.LootEntry{{Nothing: true, Weight: 0}, {Nothing: true, Weight: -1}}},
+		}},
+	}
+
+	// Call the function
+	violations := lootTableViolations(rarity, tables)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "loot_tables.a.tiers[0].entries[1].weight", Message: "must be a non-negative number, got -1"},
+	}, violations)
+	assert.Equal(t, []float64{1, -1}, lootEntryWeights(tables["a"].Tiers[0].Entries))
+}
+
+func TestS2SRollLootTable_GrantsCurrency(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SRollLootTable RPC called").Once()
+	mockLogger.On("Info", "User %s rolled loot table %s: %s", "user123", "daily_reward", "currency:gold:50").Once()
+
+	userID := "user123"
+	ctx := context.Background()
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+	mockLootRandom(t, 0.5, 0.1)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("UsersGetId", ctx, []string{userID}, []string(nil)).Return([]*api.User{{Id: userID}}, nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var record common.LootRollRecord
+		_ = json.Unmarshal([]byte(writes[0].Value), &record)
+		return len(writes) == 1 &&
+			writes[0].Collection == common.StorageLootAudit &&
+			record.Table == "daily_reward" &&
+			record.Currency == "gold" && record.Amount == 50 &&
+			len(record.LootTables) == 2
+	}), []*runtime.StorageDelete(nil), mock.MatchedBy(func(updates []*runtime.WalletUpdate) bool {
+		// The server rolls the table free of charge
+		return len(updates) == 1 && updates[0].UserID == userID && updates[0].Changeset["gold"] == 50
+	}), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := S2SRollLootTable(ctx, mockLogger, nil, nk, `{"user_id":"user123","table":"daily_reward"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RollLootResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "daily_reward", resp.Table)
+	assert.Equal(t, "gold", resp.Currency)
+	assert.Equal(t, int64(50), resp.Amount)
+	assert.Nil(t, resp.Item)
+	assert.Nil(t, resp.Pity)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestS2SRollLootTable_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "S2SRollLootTable RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := S2SRollLootTable(ctx, mockLogger, nil, nil, `{"user_id":"user123","table":"daily_reward"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestRollLootRPC_LootTableChargesCost(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Info", "User %s rolled loot table %s: %s", "user123", "wooden_chest", "item:Iron Sword").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+	mockLootRandom(t, 0.8, 0.9)
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 2 &&
+			writes[0].Collection == common.StorageInventory &&
+			writes[1].Collection == common.StorageLootAudit
+	}), []*runtime.StorageDelete(nil), mock.MatchedBy(func(updates []*runtime.WalletUpdate) bool {
+		return len(updates) == 1 && updates[0].UserID == userID && updates[0].Changeset["gold"] == -20
+	}), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, `{"table":"wooden_chest"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp RollLootResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "Iron Sword", resp.Instance.Item)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollLootRPC_LootTableWithoutCost(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Error", "Loot table %s can only be rolled by the server", "boss_dragon").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, `{"table":"boss_dragon"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestRollLootRPC_UnknownLootTable(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "RollLoot RPC called").Once()
+	mockLogger.On("Error", "Loot table %s not found", "treasure").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+
+	// Call the function
+	result, err := RollLoot(ctx, mockLogger, nil, nk, `{"table":"treasure"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestReplayLootRoll_LootTableRoll(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	random := newRecordingLootRandom(newSeededLootRandom([]byte("seed")))
+	drop := rollLootTable(&config.Rarity, config.LootTables, "boss_dragon", random)
+	record := newLootTableRollRecord("roll-1", "user123", 100, 2, config, "boss_dragon", random, drop, nil)
+
+	// Call the function
+	replay, mismatches := replayLootRoll(record)
+
+	// Assertions
+	assert.Empty(t, mismatches)
+	assert.Equal(t, record.Draws, replay.Draws)
+	assert.Equal(t, map[string]float64{"hoard": 0.7, "relic": 0.3}, replay.Chances)
+
+	// A record claiming another drop is caught
+	record.Currency, record.Amount = "gems", 1000
+	_, mismatches = replayLootRoll(record)
+	assert.Len(t, mismatches, 1)
+}
+
+func TestReadDropRates_LootTable(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ReadDropRates RPC called").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("AccountGetId", ctx, userID).Return(&api.Account{User: &api.User{Id: userID, LangTag: "es"}}, nil)
+
+	// Call the function
+	result, err := ReadDropRates(ctx, mockLogger, nil, nk, `{"table":"wooden_chest"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp ReadDropRatesResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, "wooden_chest", resp.Table)
+	assert.Nil(t, resp.Base)
+	probabilities := []float64{0.4, 0.2, 0.16, 0.16, 0.08}
+	for i, drop := range resp.Drops {
+		assert.InDelta(t, probabilities[i], drop.Probability, 1e-9)
+		drop.Probability = 0
+	}
+	assert.Equal(t, []*DropRate{
+		{Nothing: true, Display: "40.00%"},
+		{Currency: "gold", Amount: 10, Display: "20.00%"},
+		{Item: "Wooden Sword", Name: "Espada de madera", Tier: "common", Display: "16.00%"},
+		{Item: "Leather Armor", Name: "Armadura de cuero", Tier: "common", Display: "16.00%"},
+		{Item: "Iron Sword", Name: "Espada de hierro", Tier: "uncommon", Display: "8.000%"},
+	}, resp.Drops)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
@@ -0,0 +1,18 @@
+package rpc
+
+import (
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+)
+
+// marshalResponse marshals an RPC response struct to its JSON payload.
+func marshalResponse(logger runtime.Logger, resp any) (string, error) {
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		logger.Error("Cannot marshal response %+v", err)
+		return common.EmptyString, common.ErrMarshallingError
+	}
+
+	return string(respJSON), nil
+}
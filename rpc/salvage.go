@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"math"
+	"oak/common"
+	"slices"
+	"strings"
+	"time"
+)
+
+type (
+	SalvageItemsRequest struct {
+		InstanceIDs []string `json:"instance_ids"`
+	}
+
+	SalvageItemsResponse struct {
+		Salvaged []*SalvagedItem `json:"salvaged"`
+		Skipped  []*SkippedItem  `json:"skipped,omitempty"`
+		// Yields of every salvaged instance added up, by wallet currency or crafting material
+		Yields map[string]int64 `json:"yields"`
+	}
+
+	// SalvagedItem is an item instance broken down by a salvage, along with what it yielded.
+	SalvagedItem struct {
+		Instance *common.ItemInstance `json:"instance"`
+		Yields   map[string]int64     `json:"yields"`
+	}
+
+	// SkippedItem is an item instance a salvage left in the inventory, along with the reason: equipped, locked or
+	// unsalvageable when its rarity tier yields nothing.
+	SkippedItem struct {
+		InstanceID string `json:"instance_id"`
+		Reason     string `json:"reason"`
+	}
+)
+
+const maxSalvageBatch = 100
+
+// SalvageItems breaks item instances of the caller down into the wallet currencies and crafting materials their
+// rarity tiers yield. Equipped and locked instances, and the instances of tiers yielding nothing, are skipped. The
+// inventory and the wallet change in one transaction.
+func SalvageItems(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("SalvageItems RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req SalvageItemsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if len(req.InstanceIDs) == 0 {
+		logger.Error("Payload did not contain instance IDs")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if len(req.InstanceIDs) > maxSalvageBatch {
+		logger.Error("Cannot salvage %d item instances at once, limit %d", len(req.InstanceIDs), maxSalvageBatch)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, time.Now().Unix())
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	equipped := equippedInstanceIDs(inventory)
+
+	resp := &SalvageItemsResponse{Salvaged: []*SalvagedItem{}, Yields: make(map[string]int64)}
+	salvaged := make(map[string]bool, len(req.InstanceIDs))
+	for i, instanceID := range req.InstanceIDs {
+		if slices.Contains(req.InstanceIDs[:i], instanceID) {
+			logger.Error("Item instance %s of user %s is listed more than once", instanceID, userID)
+			return common.EmptyString, common.ErrInvalidArgument
+		}
+		index := slices.IndexFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+			return instance.ID == instanceID
+		})
+		if index < 0 {
+			logger.Error("Item instance %s of user %s not found", instanceID, userID)
+			return common.EmptyString, common.ErrNotFound
+		}
+		instance := inventory.Items[index]
+
+		yield := tierSalvageYield(&config.Rarity, instance.Rarity)
+		switch {
+		case equipped[instance.ID]:
+			resp.Skipped = append(resp.Skipped, &SkippedItem{InstanceID: instance.ID, Reason: "equipped"})
+		case instance.Locked:
+			resp.Skipped = append(resp.Skipped, &SkippedItem{InstanceID: instance.ID, Reason: "locked"})
+		case yield == nil:
+			resp.Skipped = append(resp.Skipped, &SkippedItem{InstanceID: instance.ID, Reason: "unsalvageable"})
+		default:
+			yields := salvageYields(yield, instance)
+			for name, amount := range yields {
+				resp.Yields[name] += amount
+			}
+			resp.Salvaged = append(resp.Salvaged, &SalvagedItem{Instance: instance, Yields: yields})
+			salvaged[instance.ID] = true
+		}
+	}
+	if len(resp.Salvaged) == 0 {
+		logger.Info("User %s salvaged no item instance, %d skipped", userID, len(resp.Skipped))
+		return marshalResponse(logger, resp)
+	}
+	inventory.Items = slices.DeleteFunc(inventory.Items, func(instance *common.ItemInstance) bool {
+		return salvaged[instance.ID]
+	})
+
+	write, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	var walletUpdates []*runtime.WalletUpdate
+	if len(resp.Yields) > 0 {
+		walletUpdates = append(walletUpdates, &runtime.WalletUpdate{
+			UserID:    userID,
+			Changeset: resp.Yields,
+			Metadata:  map[string]interface{}{"source": common.WalletSourceSalvage, "count": len(resp.Salvaged)},
+		})
+	}
+
+	// The instances are removed and their yields granted in one transaction guarded by the version the inventory was
+	// read at
+	if err = updatePlayerData(ctx, logger, nk, []*runtime.StorageWrite{write}, nil, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s salvaged %d item instances, %d skipped", userID, len(resp.Salvaged), len(resp.Skipped))
+
+	return marshalResponse(logger, resp)
+}
+
+// tierSalvageYield returns the salvage yield of the named rarity tier, nil if the tier yields nothing.
+func tierSalvageYield(rarity *common.Rarity, tier string) *common.SalvageYield {
+	for _, rarityTier := range rarityTiers(rarity) {
+		if rarityTier.Name == tier {
+			return rarityTier.Items.Salvage
+		}
+	}
+	return nil
+}
+
+// salvageYields returns what salvaging the instance yields: the amounts of the yield scaled from the minimum factor
+// when broken up to the full amounts at full durability, rounded down. Amounts rounded down to nothing are left out.
+func salvageYields(yield *common.SalvageYield, instance *common.ItemInstance) map[string]int64 {
+	condition := 1.0
+	if maxDurability := itemMaxDurability(instance); maxDurability > 0 {
+		condition = float64(instance.Durability) / float64(maxDurability)
+	}
+	factor := yield.MinFactor + (1-yield.MinFactor)*min(max(condition, 0), 1)
+
+	yields := make(map[string]int64, len(yield.Amounts))
+	for name, amount := range yield.Amounts {
+		if scaled := int64(math.Floor(float64(amount) * factor)); scaled > 0 {
+			yields[name] = scaled
+		}
+	}
+	return yields
+}
+
+// salvageViolations returns the semantic violations of the salvage yields of the rarity tiers.
+func salvageViolations(config *common.GameConfig) []*common.ConfigViolation {
+	var violations configViolations
+
+	for _, tier := range rarityTiers(&config.Rarity) {
+		yield := tier.Items.Salvage
+		if yield == nil {
+			continue
+		}
+		salvagePath := "rarity." + tier.Name + ".salvage"
+
+		if len(yield.Amounts) == 0 {
+			violations.add(salvagePath+".amounts", "must not be empty")
+		}
+		for _, name := range sortedKeys(yield.Amounts) {
+			switch {
+			case strings.TrimSpace(name) == common.EmptyString:
+				violations.add(salvagePath+".amounts", "must not have an empty currency or material name")
+			case yield.Amounts[name] <= 0:
+				violations.add(salvagePath+".amounts."+name, "must be positive, got %d", yield.Amounts[name])
+			}
+		}
+		if yield.MinFactor < 0 || yield.MinFactor > 1 || math.IsNaN(yield.MinFactor) {
+			violations.add(salvagePath+".min_factor", "must be between 0 and 1, got %v", yield.MinFactor)
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+)
+
+func TestSalvageYields(t *testing.T) {
+	yield := &common.SalvageYield{Amounts: map[string]int64{"scrap": 10, "gold": 3}, MinFactor: 0.5}
+
+	tests := []struct {
+		name     string
+		instance *common.ItemInstance
+		yields   map[string]int64
+	}{
+		{name: "full durability", instance: &common.ItemInstance{Durability: 100, MaxDurability: 100}, yields: map[string]int64{"scrap": 10, "gold": 3}},
+		{name: "half durability", instance: &common.ItemInstance{Durability: 50, MaxDurability: 100}, yields: map[string]int64{"scrap": 7, "gold": 2}},
+		{name: "broken", instance: &common.ItemInstance{MaxDurability: 100, Broken: true}, yields: map[string]int64{"scrap": 5, "gold": 1}},
+		{name: "legacy instance", instance: &common.ItemInstance{Durability: 100}, yields: map[string]int64{"scrap": 10, "gold": 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Call the function and assert
+			assert.Equal(t, tt.yields, salvageYields(yield, tt.instance))
+		})
+	}
+}
+
+func TestSalvageViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Rarity.Common.Salvage = &common.SalvageYield{}
+	config.Rarity.Rare.Salvage = &common.SalvageYield{Amounts: map[string]int64{"scrap": 0}, MinFactor: 1.5}
+
+	// Call the function
+	violations := salvageViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "rarity.common.salvage.amounts", Message: "must not be empty"},
+		{Path: "rarity.rare.salvage.amounts.scrap", Message: "must be positive, got 0"},
+		{Path: "rarity.rare.salvage.min_factor", Message: "must be between 0 and 1, got 1.5"},
+	}, violations)
+}
+
+func TestSalvageItems_SkipsProtectedItems(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SalvageItems RPC called").Once()
+	mockLogger.On("Info", "User %s salvaged %d item instances, %d skipped", "user123", 3, 2).Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	inventoryJSON, _ := json.Marshal(&common.Inventory{
+		Items: []*common.ItemInstance{
+			{ID: "junk1", Item: "Wooden Sword", Rarity: "common", Durability: 100, MaxDurability: 100},
+			{ID: "junk2", Item: "Wooden Sword", Rarity: "common", Durability: 50, MaxDurability: 100},
+			{ID: "iron", Item: "Iron Sword", Rarity: "uncommon", MaxDurability: 150, Broken: true},
+			{ID: "sword", Item: "Steel Sword", Rarity: "rare", Durability: 250, MaxDurability: 250},
+			{ID: "shield", Item: "Dragon Shield", Rarity: "rare", Durability: 250, MaxDurability: 250, Locked: true},
+		},
+		Loadouts: map[string]common.Loadout{"default": {Slots: map[string]string{"weapon": "sword"}}},
+	})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return([]*api.StorageObject{{Value: string(inventoryJSON), Version: "v1"}}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].Version == "v1" && len(inventory.Items) == 2 &&
+			inventory.Items[0].ID == "sword" && inventory.Items[1].ID == "shield"
+	}), []*runtime.StorageDelete(nil), []*runtime.WalletUpdate{{
+		UserID:    userID,
+		Changeset: map[string]int64{"scrap": 5, "gold": 1},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceSalvage, "count": 3},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+
+	// Call the function
+	result, err := SalvageItems(ctx, mockLogger, nil, nk, `{"instance_ids":["junk1","junk2","iron","sword","shield"]}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp SalvageItemsResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Len(t, resp.Salvaged, 3)
+	assert.Equal(t, map[string]int64{"scrap": 1}, resp.Salvaged[1].Yields)
+	assert.Equal(t, []*SkippedItem{{InstanceID: "sword", Reason: "equipped"}, {InstanceID: "shield", Reason: "locked"}}, resp.Skipped)
+	assert.Equal(t, map[string]int64{"scrap": 5, "gold": 1}, resp.Yields)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSalvageItems_NotFound(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SalvageItems RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s not found", "x", "user123").Once()
+
+	userID := "user123"
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead(userID)).Return(storedInventory(t,
+		&common.ItemInstance{ID: "a", Item: "Wooden Sword", Rarity: "common", Durability: 100},
+	), nil)
+
+	// Call the function
+	result, err := SalvageItems(ctx, mockLogger, nil, nk, `{"instance_ids":["a","x"]}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestSalvageItems_BatchLimit(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "SalvageItems RPC called").Once()
+	mockLogger.On("Error", "Cannot salvage %d item instances at once, limit %d", 101, maxSalvageBatch).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	ids := make([]string, maxSalvageBatch+1)
+	for i := range ids {
+		ids[i] = string(rune('a' + i%26))
+	}
+	payload, _ := json.Marshal(&SalvageItemsRequest{InstanceIDs: ids})
+
+	// Call the function
+	result, err := SalvageItems(ctx, mockLogger, nil, nil, string(payload))
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+}
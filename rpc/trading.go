@@ -0,0 +1,477 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"oak/common"
+	"slices"
+	"time"
+)
+
+type (
+	ProposeTradeRequest struct {
+		RecipientID string `json:"recipient_id"`
+		// IDs of the item instances of the caller to offer
+		Offered         []string         `json:"offered,omitempty"`
+		OfferedCurrency map[string]int64 `json:"offered_currency,omitempty"`
+		// IDs of the item instances of the recipient to request
+		Requested         []string         `json:"requested,omitempty"`
+		RequestedCurrency map[string]int64 `json:"requested_currency,omitempty"`
+	}
+
+	TradeRequest struct {
+		TradeID string `json:"trade_id"`
+	}
+
+	TradeResponse struct {
+		Trade *common.Trade `json:"trade"`
+	}
+)
+
+// ProposeTrade offers item instances and currency of the caller to another player in exchange for instances and
+// currency of theirs. The offered instances and currency move from the caller's inventory and wallet into escrow, so
+// they cannot be used, discarded or offered again while the offer is pending.
+func ProposeTrade(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ProposeTrade RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req ProposeTradeRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.RecipientID == common.EmptyString || req.RecipientID == userID {
+		logger.Error("Payload did not contain the ID of another user to trade with")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	if len(req.Offered)+len(req.OfferedCurrency)+len(req.Requested)+len(req.RequestedCurrency) == 0 {
+		logger.Error("Payload did not contain anything to trade")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+	for _, currencies := range []map[string]int64{req.OfferedCurrency, req.RequestedCurrency} {
+		for currency, amount := range currencies {
+			if amount <= 0 {
+				logger.Error("Traded amount of %s must be positive, got %d", currency, amount)
+				return common.EmptyString, common.ErrInvalidArgument
+			}
+		}
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	now := time.Now().Unix()
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if config.Trading == nil {
+		logger.Error("Trading is not configured")
+		return common.EmptyString, common.ErrTradingUnavailable
+	}
+	if maxItems := config.Trading.MaxItems; maxItems > 0 && max(len(req.Offered), len(req.Requested)) > maxItems {
+		logger.Error("Cannot trade more than %d item instances on each side", maxItems)
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	if err = checkUserExists(ctx, logger, nk, req.RecipientID); err != nil {
+		return common.EmptyString, err
+	}
+
+	// The requested instances are checked again when the offer is accepted, the recipient may unequip or unlock them
+	if len(req.Requested) > 0 {
+		recipientInventory, _, err := readInventory(ctx, logger, nk, req.RecipientID)
+		if err != nil {
+			return common.EmptyString, err
+		}
+		for _, instanceID := range req.Requested {
+			index := slices.IndexFunc(recipientInventory.Items, func(instance *common.ItemInstance) bool {
+				return instance.ID == instanceID
+			})
+			if index < 0 {
+				logger.Error("Item instance %s of user %s not found", instanceID, req.RecipientID)
+				return common.EmptyString, common.ErrNotFound
+			}
+			if !itemTradable(config, recipientInventory.Items[index]) {
+				logger.Error("Item instance %s of user %s cannot be traded", instanceID, req.RecipientID)
+				return common.EmptyString, common.ErrItemUntradable
+			}
+		}
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	offered, err := takeEscrowedItems(logger, config, userID, inventory, req.Offered)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	tradeID, err := newRandomID()
+	if err != nil {
+		logger.Error("Cannot create trade ID: %+v", err)
+		return common.EmptyString, common.ErrInternalError
+	}
+	trade := &common.Trade{
+		ID:                tradeID,
+		ProposerID:        userID,
+		RecipientID:       req.RecipientID,
+		Offered:           offered,
+		OfferedCurrency:   req.OfferedCurrency,
+		Requested:         append([]string{}, req.Requested...),
+		RequestedCurrency: req.RequestedCurrency,
+		Status:            common.TradeProposed,
+		CreatedAt:         now,
+		ExpiresAt:         now + config.Trading.OfferTTL,
+	}
+
+	writeTrade, err := tradeWrite(logger, trade, "*")
+	if err != nil {
+		return common.EmptyString, err
+	}
+	var walletUpdates []*runtime.WalletUpdate
+	if len(trade.OfferedCurrency) > 0 {
+		walletUpdates = append(walletUpdates, tradeWalletUpdate(userID, trade, negateCurrencies(trade.OfferedCurrency)))
+	}
+	if err = holdEscrow(ctx, logger, nk, userID, inventory, inventoryVersion, writeTrade, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	logger.Info("User %s proposed trade %s to user %s", userID, trade.ID, trade.RecipientID)
+	notifyTrade(ctx, logger, nk, trade)
+
+	return marshalResponse(logger, &TradeResponse{Trade: trade})
+}
+
+// AcceptTrade completes a trade offered to the caller: the escrowed instances and currency go to the caller, and
+// the requested ones to the proposer, in one transaction across both inventories and wallets.
+func AcceptTrade(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("AcceptTrade RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req TradeRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.TradeID == common.EmptyString {
+		logger.Error("Payload did not contain a trade ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	trade, tradeVersion, err := readTrade(ctx, logger, nk, req.TradeID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	if trade.RecipientID != userID {
+		logger.Error("Trade %s was not offered to user %s", trade.ID, userID)
+		return common.EmptyString, common.ErrNotFound
+	}
+	now := time.Now().Unix()
+	if now >= trade.ExpiresAt {
+		if err = closeTrade(ctx, logger, nk, trade, tradeVersion, common.TradeExpired); err != nil {
+			return common.EmptyString, err
+		}
+		return common.EmptyString, common.ErrTradeExpired
+	}
+
+	active, err := LoadActiveGameConfig(ctx, logger, nk)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	config, _, err := deriveUserGameConfig(ctx, logger, nk, userID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	proposerConfig, _, err := deriveUserGameConfig(ctx, logger, nk, trade.ProposerID, active, now)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	inventory, inventoryVersion, err := readInventory(ctx, logger, nk, userID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	requested, err := takeEscrowedItems(logger, config, userID, inventory, trade.Requested)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	proposerInventory, proposerInventoryVersion, err := readInventory(ctx, logger, nk, trade.ProposerID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	for _, instance := range trade.Offered {
+		instance.AcquiredAt = now
+		if err = addInventoryItem(logger, config, userID, inventory, instance); err != nil {
+			return common.EmptyString, err
+		}
+	}
+	for _, instance := range requested {
+		instance.AcquiredAt = now
+		if err = addInventoryItem(logger, proposerConfig, trade.ProposerID, proposerInventory, instance); err != nil {
+			return common.EmptyString, err
+		}
+	}
+
+	writeInventory, err := inventoryWrite(logger, userID, inventory, inventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	writeProposerInventory, err := inventoryWrite(logger, trade.ProposerID, proposerInventory, proposerInventoryVersion)
+	if err != nil {
+		return common.EmptyString, err
+	}
+	var walletUpdates []*runtime.WalletUpdate
+	if changeset := mergeCurrencies(trade.OfferedCurrency, negateCurrencies(trade.RequestedCurrency)); len(changeset) > 0 {
+		walletUpdates = append(walletUpdates, tradeWalletUpdate(userID, trade, changeset))
+	}
+	if len(trade.RequestedCurrency) > 0 {
+		walletUpdates = append(walletUpdates, tradeWalletUpdate(trade.ProposerID, trade, trade.RequestedCurrency))
+	}
+
+	// Both inventories, both wallets and the offer change in one transaction guarded by the versions they were read
+	// at, so the trade completes entirely or not at all
+	writes := []*runtime.StorageWrite{writeInventory, writeProposerInventory}
+	deletes := []*runtime.StorageDelete{tradeDelete(trade, tradeVersion)}
+	if err = updatePlayerData(ctx, logger, nk, writes, deletes, walletUpdates); err != nil {
+		return common.EmptyString, err
+	}
+
+	trade.Status = common.TradeAccepted
+	logger.Info("User %s accepted trade %s of user %s", userID, trade.ID, trade.ProposerID)
+	notifyTrade(ctx, logger, nk, trade)
+
+	return marshalResponse(logger, &TradeResponse{Trade: trade})
+}
+
+// DeclineTrade closes a pending trade of the caller, declined when offered to them or cancelled when proposed by
+// them, and returns the escrowed instances and currency to the proposer.
+func DeclineTrade(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("DeclineTrade RPC called")
+
+	// Get the user ID from the context
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		logger.Error("Context did not contain user ID.")
+		return common.EmptyString, common.ErrUserNotFound
+	}
+
+	var req TradeRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Cannot unmarshal payload: %+v", err)
+		return common.EmptyString, common.ErrUnMarshallingError
+	}
+	if req.TradeID == common.EmptyString {
+		logger.Error("Payload did not contain a trade ID")
+		return common.EmptyString, common.ErrInvalidArgument
+	}
+
+	trade, tradeVersion, err := readTrade(ctx, logger, nk, req.TradeID)
+	if err != nil {
+		return common.EmptyString, err
+	}
+
+	var status common.TradeStatus
+	switch {
+	case userID != trade.RecipientID && userID != trade.ProposerID:
+		logger.Error("Trade %s is not a trade of user %s", trade.ID, userID)
+		return common.EmptyString, common.ErrNotFound
+	case time.Now().Unix() >= trade.ExpiresAt:
+		status = common.TradeExpired
+	case userID == trade.RecipientID:
+		status = common.TradeDeclined
+	default:
+		status = common.TradeCancelled
+	}
+	if err = closeTrade(ctx, logger, nk, trade, tradeVersion, status); err != nil {
+		return common.EmptyString, err
+	}
+
+	return marshalResponse(logger, &TradeResponse{Trade: trade})
+}
+
+// ExpireTrades closes the expired trades of a page of pending trades, returning the escrowed instances and currency
+// to their proposers.
+func ExpireTrades(ctx context.Context, logger runtime.Logger, _ *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	logger.Debug("ExpireTrades RPC called")
+
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if ok && userID != "" {
+		logger.Error("Rpc was called by a user")
+		return common.EmptyString, common.ErrS2SPermissionDenied
+	}
+
+	return expireEscrows(ctx, logger, nk, payload, common.StorageTrades, "trade",
+		func(trade *common.Trade) int64 { return trade.ExpiresAt },
+		func(trade *common.Trade, storageVersion string) error {
+			return closeTrade(ctx, logger, nk, trade, storageVersion, common.TradeExpired)
+		})
+}
+
+// closeTrade closes a pending trade without completing it, releasing the escrowed instances and currency back to the
+// proposer. Both parties are notified.
+func closeTrade(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, trade *common.Trade, tradeVersion string, status common.TradeStatus) error {
+	var walletUpdates []*runtime.WalletUpdate
+	if len(trade.OfferedCurrency) > 0 {
+		walletUpdates = append(walletUpdates, tradeWalletUpdate(trade.ProposerID, trade, trade.OfferedCurrency))
+	}
+	if err := releaseEscrow(ctx, logger, nk, trade.ProposerID, trade.Offered, tradeDelete(trade, tradeVersion), walletUpdates); err != nil {
+		return err
+	}
+
+	trade.Status = status
+	logger.Info("Trade %s of user %s to user %s %s", trade.ID, trade.ProposerID, trade.RecipientID, status)
+	notifyTrade(ctx, logger, nk, trade)
+
+	return nil
+}
+
+// readTrade reads a pending trade along with its storage version.
+func readTrade(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, tradeID string) (*common.Trade, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: common.StorageTrades,
+		Key:        tradeID,
+	}})
+	if err != nil {
+		logger.Error("StorageRead error: %+v", err)
+		return nil, common.EmptyString, common.ErrInternalError
+	}
+	if len(objects) == 0 {
+		logger.Error("Trade %s not found", tradeID)
+		return nil, common.EmptyString, common.ErrNotFound
+	}
+
+	trade := &common.Trade{}
+	if err = json.Unmarshal([]byte(objects[0].GetValue()), trade); err != nil {
+		logger.Error("Cannot unmarshal trade: %+v", err)
+		return nil, common.EmptyString, common.ErrUnMarshallingError
+	}
+
+	return trade, objects[0].GetVersion(), nil
+}
+
+// tradeWrite builds the conditional write of a pending trade. Trades are owned by the server and hidden from the
+// players, who are told about them by notifications.
+func tradeWrite(logger runtime.Logger, trade *common.Trade, storageVersion string) (*runtime.StorageWrite, error) {
+	tradeJSON, err := json.Marshal(trade)
+	if err != nil {
+		logger.Error("Cannot marshal trade: %+v", err)
+		return nil, common.ErrMarshallingError
+	}
+
+	return &runtime.StorageWrite{
+		Collection:      common.StorageTrades,
+		Key:             trade.ID,
+		Value:           string(tradeJSON),
+		Version:         storageVersion,
+		PermissionRead:  runtime.STORAGE_PERMISSION_NO_READ,
+		PermissionWrite: runtime.STORAGE_PERMISSION_NO_WRITE,
+	}, nil
+}
+
+// tradeDelete builds the deletion of a pending trade, guarded by the storage version it was read at so that a trade
+// is closed only once.
+func tradeDelete(trade *common.Trade, storageVersion string) *runtime.StorageDelete {
+	return &runtime.StorageDelete{
+		Collection: common.StorageTrades,
+		Key:        trade.ID,
+		Version:    storageVersion,
+	}
+}
+
+// tradeWalletUpdate builds the wallet update of a party of the trade, recorded in the ledger with the trade ID.
+func tradeWalletUpdate(userID string, trade *common.Trade, changeset map[string]int64) *runtime.WalletUpdate {
+	return &runtime.WalletUpdate{
+		UserID:    userID,
+		Changeset: changeset,
+		Metadata:  map[string]interface{}{"source": common.WalletSourceTrade, "trade_id": trade.ID},
+	}
+}
+
+// notifyTrade sends the state of the trade to both parties. The trade has already changed, so a failure to notify is
+// only logged.
+func notifyTrade(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, trade *common.Trade) {
+	content := map[string]interface{}{
+		"trade_id":     trade.ID,
+		"status":       string(trade.Status),
+		"proposer_id":  trade.ProposerID,
+		"recipient_id": trade.RecipientID,
+		"expires_at":   trade.ExpiresAt,
+	}
+	subject := fmt.Sprintf("Trade %s", trade.Status)
+
+	notifications := make([]*runtime.NotificationSend, 0, 2)
+	for _, userID := range []string{trade.ProposerID, trade.RecipientID} {
+		notifications = append(notifications, &runtime.NotificationSend{
+			UserID:     userID,
+			Subject:    subject,
+			Content:    content,
+			Code:       common.NotificationCodeTrade,
+			Persistent: true,
+		})
+	}
+	if err := nk.NotificationsSend(ctx, notifications); err != nil {
+		logger.Error("Cannot notify the parties of trade %s: %+v", trade.ID, err)
+	}
+}
+
+// mergeCurrencies adds the amounts of both sets up by currency, leaving out the currencies adding up to nothing.
+func mergeCurrencies(a, b map[string]int64) map[string]int64 {
+	merged := make(map[string]int64, len(a)+len(b))
+	for _, currencies := range []map[string]int64{a, b} {
+		for currency, amount := range currencies {
+			merged[currency] += amount
+		}
+	}
+	for currency, amount := range merged {
+		if amount == 0 {
+			delete(merged, currency)
+		}
+	}
+	return merged
+}
+
+// negateCurrencies returns the amounts of the currencies negated.
+func negateCurrencies(currencies map[string]int64) map[string]int64 {
+	negated := make(map[string]int64, len(currencies))
+	for currency, amount := range currencies {
+		negated[currency] = -amount
+	}
+	return negated
+}
+
+// tradingViolations returns the semantic violations of the trading settings.
+func tradingViolations(config *common.GameConfig) []*common.ConfigViolation {
+	if config.Trading == nil {
+		return nil
+	}
+
+	var violations configViolations
+
+	if config.Trading.OfferTTL <= 0 {
+		violations.add("trading.offer_ttl", "must be positive, got %d", config.Trading.OfferTTL)
+	}
+	if config.Trading.MaxItems < 0 {
+		violations.add("trading.max_items", "must not be negative, got %d", config.Trading.MaxItems)
+	}
+
+	return violations
+}
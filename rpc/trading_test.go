@@ -0,0 +1,356 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"oak/common"
+	"oak/mocks"
+	"testing"
+	"time"
+)
+
+func tradeRead(tradeID string) []*runtime.StorageRead {
+	return []*runtime.StorageRead{{
+		Collection: common.StorageTrades,
+		Key:        tradeID,
+	}}
+}
+
+// storedTrade returns a stored trade of user123 offering an Iron Sword and 10 gold to user456 for their Steel Sword,
+// expiring at the given time.
+func storedTrade(t *testing.T, expiresAt int64) []*api.StorageObject {
+	return []*api.StorageObject{storedEscrow(t, "trade1", "t1", &common.Trade{
+		ID:              "trade1",
+		ProposerID:      "user123",
+		RecipientID:     "user456",
+		Offered:         []*common.ItemInstance{escrowedIronSword()},
+		OfferedCurrency: map[string]int64{"gold": 10},
+		Requested:       []string{"steel"},
+		Status:          common.TradeProposed,
+		CreatedAt:       1,
+		ExpiresAt:       expiresAt,
+	})}
+}
+
+// tradeNotified matches the notifications of the trade status sent to both parties.
+func tradeNotified(status common.TradeStatus) interface{} {
+	return mock.MatchedBy(func(notifications []*runtime.NotificationSend) bool {
+		return len(notifications) == 2 &&
+			notifications[0].UserID == "user123" && notifications[1].UserID == "user456" &&
+			notifications[0].Content["status"] == string(status) &&
+			notifications[0].Code == common.NotificationCodeTrade && notifications[0].Persistent
+	})
+}
+
+func TestProposeTrade_EscrowsOfferedItemsAndCurrency(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ProposeTrade RPC called").Once()
+	mockLogger.On("Info", "User %s proposed trade %s to user %s", "user123", mock.Anything, "user456").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("UsersGetId", ctx, []string{"user456"}, []string(nil)).Return([]*api.User{{Id: "user456"}}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user456")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "steel", Item: "Steel Sword", Rarity: "rare"},
+	), nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "iron", Item: "Iron Sword", Rarity: "uncommon"},
+		&common.ItemInstance{ID: "wood", Item: "Wooden Sword", Rarity: "common"},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		var trade common.Trade
+		_ = json.Unmarshal([]byte(writes[1].Value), &trade)
+		return len(writes) == 2 && writes[0].Version == "v1" && len(inventory.Items) == 1 && inventory.Items[0].ID == "wood" &&
+			writes[1].Collection == common.StorageTrades && writes[1].UserID == common.EmptyString && writes[1].Version == "*" &&
+			len(trade.Offered) == 1 && trade.Offered[0].ID == "iron" && trade.ExpiresAt == trade.CreatedAt+86400
+	}), []*runtime.StorageDelete(nil), mock.MatchedBy(func(updates []*runtime.WalletUpdate) bool {
+		return len(updates) == 1 && updates[0].UserID == "user123" && updates[0].Changeset["gold"] == -10 &&
+			updates[0].Metadata["source"] == common.WalletSourceTrade
+	}), true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+	nk.On("NotificationsSend", ctx, tradeNotified(common.TradeProposed)).Return(nil)
+
+	// Call the function
+	result, err := ProposeTrade(ctx, mockLogger, nil, nk,
+		`{"recipient_id":"user456","offered":["iron"],"offered_currency":{"gold":10},"requested":["steel"]}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp TradeResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, common.TradeProposed, resp.Trade.Status)
+	assert.Equal(t, []string{"steel"}, resp.Trade.Requested)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestProposeTrade_UntradableItem(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ProposeTrade RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s cannot be traded", "excalibur", "user123").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("UsersGetId", ctx, []string{"user456"}, []string(nil)).Return([]*api.User{{Id: "user456"}}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "excalibur", Item: "Excalibur", Rarity: "legendary"},
+	), nil)
+
+	// Call the function
+	result, err := ProposeTrade(ctx, mockLogger, nil, nk, `{"recipient_id":"user456","offered":["excalibur"]}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrItemUntradable, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestProposeTrade_TradingWithSelf(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ProposeTrade RPC called").Once()
+	mockLogger.On("Error", "Payload did not contain the ID of another user to trade with").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ProposeTrade(ctx, mockLogger, nil, nil, `{"recipient_id":"user123","offered_currency":{"gold":10}}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrInvalidArgument, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestAcceptTrade_ExchangesBothSidesAtomically(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "AcceptTrade RPC called").Once()
+	mockLogger.On("Info", "User %s accepted trade %s of user %s", "user456", "trade1", "user123").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, tradeRead("trade1")).Return(storedTrade(t, time.Now().Add(time.Hour).Unix()), nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user456")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "steel", Item: "Steel Sword", Rarity: "rare"},
+	), nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "wood", Item: "Wooden Sword", Rarity: "common"},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var recipient, proposer common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &recipient)
+		_ = json.Unmarshal([]byte(writes[1].Value), &proposer)
+		return len(writes) == 2 &&
+			writes[0].UserID == "user456" && len(recipient.Items) == 1 && recipient.Items[0].ID == "iron" &&
+			writes[1].UserID == "user123" && len(proposer.Items) == 2 && proposer.Items[1].ID == "steel"
+	}), []*runtime.StorageDelete{{Collection: common.StorageTrades, Key: "trade1", Version: "t1"}}, []*runtime.WalletUpdate{{
+		UserID:    "user456",
+		Changeset: map[string]int64{"gold": 10},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceTrade, "trade_id": "trade1"},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+	nk.On("NotificationsSend", ctx, tradeNotified(common.TradeAccepted)).Return(nil)
+
+	// Call the function
+	result, err := AcceptTrade(ctx, mockLogger, nil, nk, `{"trade_id":"trade1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp TradeResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, common.TradeAccepted, resp.Trade.Status)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestAcceptTrade_RequestedItemEquipped(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "AcceptTrade RPC called").Once()
+	mockLogger.On("Error", "Item instance %s of user %s is equipped", "steel", "user456").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+	mockActiveGameConfig(t, &common.GameConfigVersion{Version: 4, Config: *embeddedGameConfig(t)})
+
+	inventoryJSON, _ := json.Marshal(&common.Inventory{
+		Items:    []*common.ItemInstance{{ID: "steel", Item: "Steel Sword", Rarity: "rare"}},
+		Loadouts: map[string]common.Loadout{"default": {Slots: map[string]string{"weapon": "steel"}}},
+	})
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, tradeRead("trade1")).Return(storedTrade(t, time.Now().Add(time.Hour).Unix()), nil)
+	nk.On("StorageRead", ctx, liveOpsOverridesRead).Return([]*api.StorageObject{}, nil)
+	nk.On("StorageRead", ctx, inventoryRead("user456")).Return([]*api.StorageObject{{Value: string(inventoryJSON), Version: "v1"}}, nil)
+
+	// Call the function
+	result, err := AcceptTrade(ctx, mockLogger, nil, nk, `{"trade_id":"trade1"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrItemEquipped, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestAcceptTrade_ExpiredOfferReturnsEscrow(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "AcceptTrade RPC called").Once()
+	mockLogger.On("Info", "Trade %s of user %s to user %s %s", "trade1", "user123", "user456", common.TradeExpired).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user456")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, tradeRead("trade1")).Return(storedTrade(t, time.Now().Add(-time.Minute).Unix()), nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return(storedInventory(t,
+		&common.ItemInstance{ID: "wood", Item: "Wooden Sword", Rarity: "common"},
+	), nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		var inventory common.Inventory
+		_ = json.Unmarshal([]byte(writes[0].Value), &inventory)
+		return len(writes) == 1 && writes[0].UserID == "user123" && len(inventory.Items) == 2 &&
+			inventory.Items[1].ID == "iron" && inventory.Items[1].AcquiredAt == 10
+	}), []*runtime.StorageDelete{{Collection: common.StorageTrades, Key: "trade1", Version: "t1"}}, []*runtime.WalletUpdate{{
+		UserID:    "user123",
+		Changeset: map[string]int64{"gold": 10},
+		Metadata:  map[string]interface{}{"source": common.WalletSourceTrade, "trade_id": "trade1"},
+	}}, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+	nk.On("NotificationsSend", ctx, tradeNotified(common.TradeExpired)).Return(nil)
+
+	// Call the function
+	result, err := AcceptTrade(ctx, mockLogger, nil, nk, `{"trade_id":"trade1"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrTradeExpired, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDeclineTrade_CancelledByProposer(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DeclineTrade RPC called").Once()
+	mockLogger.On("Info", "Trade %s of user %s to user %s %s", "trade1", "user123", "user456", common.TradeCancelled).Once()
+	mockLogger.On("Error", "Cannot notify the parties of trade %s: %+v", "trade1", assert.AnError).Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, tradeRead("trade1")).Return(storedTrade(t, time.Now().Add(time.Hour).Unix()), nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.MatchedBy(func(writes []*runtime.StorageWrite) bool {
+		return len(writes) == 1 && writes[0].Version == "*"
+	}), mock.Anything, mock.Anything, true).Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil)
+	nk.On("NotificationsSend", ctx, tradeNotified(common.TradeCancelled)).Return(assert.AnError)
+
+	// Call the function
+	result, err := DeclineTrade(ctx, mockLogger, nil, nk, `{"trade_id":"trade1"}`)
+
+	// Assertions
+	assert.NoError(t, err)
+	var resp TradeResponse
+	assert.NoError(t, json.Unmarshal([]byte(result), &resp))
+	assert.Equal(t, common.TradeCancelled, resp.Trade.Status)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestDeclineTrade_NotAParty(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "DeclineTrade RPC called").Once()
+	mockLogger.On("Error", "Trade %s is not a trade of user %s", "trade1", "user789").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user789")
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageRead", ctx, tradeRead("trade1")).Return(storedTrade(t, time.Now().Add(time.Hour).Unix()), nil)
+
+	// Call the function
+	result, err := DeclineTrade(ctx, mockLogger, nil, nk, `{"trade_id":"trade1"}`)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrNotFound, err)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestExpireTrades_ClosesExpiredTrades(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ExpireTrades RPC called").Once()
+	mockLogger.On("Info", "Trade %s of user %s to user %s %s", "trade1", "user123", "user456", common.TradeExpired).Once()
+
+	ctx := context.Background()
+	pending := storedTrade(t, time.Now().Add(time.Hour).Unix())[0]
+	pending.Key = "trade2"
+
+	nk := new(mocks.NakamaModule)
+	nk.On("StorageList", ctx, common.EmptyString, common.EmptyString, common.StorageTrades, maxExpireEscrowsLimit, common.EmptyString).
+		Return([]*api.StorageObject{storedTrade(t, time.Now().Add(-time.Minute).Unix())[0], pending}, "next", nil)
+	nk.On("StorageRead", ctx, inventoryRead("user123")).Return([]*api.StorageObject{}, nil)
+	nk.On("MultiUpdate", ctx, []*runtime.AccountUpdate(nil), mock.Anything,
+		[]*runtime.StorageDelete{{Collection: common.StorageTrades, Key: "trade1", Version: "t1"}}, mock.Anything, true).
+		Return([]*api.StorageObjectAck{}, []*runtime.WalletUpdateResult{}, nil).Once()
+	nk.On("NotificationsSend", ctx, tradeNotified(common.TradeExpired)).Return(nil)
+
+	// Call the function
+	result, err := ExpireTrades(ctx, mockLogger, nil, nk, common.EmptyString)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"expired":["trade1"],"cursor":"next"}`, result)
+	mockLogger.AssertExpectations(t)
+	nk.AssertExpectations(t)
+}
+
+func TestExpireTrades_CalledByUser(t *testing.T) {
+	// Setup
+	mockLogger := new(mocks.Logger)
+	mockLogger.On("Debug", "ExpireTrades RPC called").Once()
+	mockLogger.On("Error", "Rpc was called by a user").Once()
+
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, "user123")
+
+	// Call the function
+	result, err := ExpireTrades(ctx, mockLogger, nil, nil, common.EmptyString)
+
+	// Assertions
+	assert.Equal(t, common.EmptyString, result)
+	assert.Equal(t, common.ErrS2SPermissionDenied, err)
+	mockLogger.AssertExpectations(t)
+}
+
+func TestTradingViolations(t *testing.T) {
+	// Setup
+	config := embeddedGameConfig(t)
+	config.Trading = &common.TradingSettings{MaxItems: -1}
+
+	// Call the function
+	violations := tradingViolations(config)
+
+	// Assertions
+	assert.Equal(t, []*common.ConfigViolation{
+		{Path: "trading.offer_ttl", Message: "must be positive, got 0"},
+		{Path: "trading.max_items", Message: "must not be negative, got -1"},
+	}, violations)
+}